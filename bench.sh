@@ -0,0 +1,4 @@
+#!/bin/sh
+
+go test ./cmd/server/ -run='^$' -bench=IdleStreams -benchtime=100000x
+go test ./cmd/server/ -run='^$' -bench=MemoryStorage -benchtime=1000000x
@@ -0,0 +1,247 @@
+// Package checksum calculates the checksum of a sequence of numbers, with the algorithms a client can negotiate in
+// a NumbersRequest. The server and the client both use it, so that they always encode a sequence the same way.
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// ENCODING_VERSION is hashed to start the hash chain of every algorithm but MD5_LEGACY. Each number is then chained
+// on as 4 bytes in big-endian order, so that every sequence has exactly one encoding: H_0 = H(ENCODING_VERSION) and
+// H_i = H(H_{i-1} || n_i).
+//
+// A sequence of INT64 or FLOAT64 values starts from H_0 = H(ENCODING_VERSION || t), with the value type t as a
+// byte, so that it can't have the checksum of a sequence of another type, and chains each value on as 8 bytes in
+// big-endian order.
+const ENCODING_VERSION byte = 2
+
+// names maps the name of each supported algorithm, as given on the command line, to the algorithm.
+var names = map[string]protocol.ChecksumAlgorithm{
+	"md5":     protocol.ChecksumAlgorithm_MD5_LEGACY,
+	"sha256":  protocol.ChecksumAlgorithm_SHA256,
+	"blake2b": protocol.ChecksumAlgorithm_BLAKE2B_256,
+	"crc32c":  protocol.ChecksumAlgorithm_CRC32C,
+	"xxhash":  protocol.ChecksumAlgorithm_XXHASH64,
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Hash is the running checksum of a sequence. Every algorithm but MD5_LEGACY chains the numbers, so the checksum of
+// each prefix of the sequence (its link) can be checked as the sequence arrives. MD5_LEGACY hashes the sequence
+// flat, as it always has, and has no links. The state of a Hash can be saved with MarshalBinary and restored into a
+// Hash of the same algorithm and value type with UnmarshalBinary.
+//
+// Values are given to a Hash as 64 bits: a UINT32 number zero extended, an INT64 value in two's complement and a
+// FLOAT64 value as its IEEE 754 bits.
+type Hash struct {
+	algorithm protocol.ChecksumAlgorithm
+	valueType protocol.ValueType
+	h         hash.Hash
+	// link is H_i, the chain value of the sequence so far. It is nil for MD5_LEGACY.
+	link []byte
+	buf  [8]byte
+}
+
+// New returns the Hash of an empty sequence of valueType values for algorithm. MD5_LEGACY only hashes UINT32
+// values.
+func New(algorithm protocol.ChecksumAlgorithm, valueType protocol.ValueType) (*Hash, error) {
+	if _, ok := protocol.ValueType_name[int32(valueType)]; !ok {
+		return nil, fmt.Errorf("unsupported value type %s", valueType)
+	}
+	if algorithm == protocol.ChecksumAlgorithm_MD5_LEGACY && valueType != protocol.ValueType_UINT32 {
+		return nil, fmt.Errorf("%s can't checksum %s values", algorithm, valueType)
+	}
+
+	var h hash.Hash
+	switch algorithm {
+	case protocol.ChecksumAlgorithm_MD5_LEGACY:
+		h = md5.New()
+	case protocol.ChecksumAlgorithm_SHA256:
+		h = sha256.New()
+	case protocol.ChecksumAlgorithm_BLAKE2B_256:
+		h, _ = blake2b.New256(nil)
+	case protocol.ChecksumAlgorithm_CRC32C:
+		h = crc32.New(castagnoli)
+	case protocol.ChecksumAlgorithm_XXHASH64:
+		h = xxhash.New()
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %s", algorithm)
+	}
+
+	c := &Hash{algorithm: algorithm, valueType: valueType, h: h}
+	if algorithm != protocol.ChecksumAlgorithm_MD5_LEGACY {
+		h.Write([]byte{ENCODING_VERSION})
+		if valueType != protocol.ValueType_UINT32 {
+			h.Write([]byte{byte(valueType)})
+		}
+		c.link = h.Sum(nil)
+	}
+
+	return c, nil
+}
+
+func (h *Hash) Algorithm() protocol.ChecksumAlgorithm {
+	return h.algorithm
+}
+
+// ValueType returns the type of the values h hashes.
+func (h *Hash) ValueType() protocol.ValueType {
+	return h.valueType
+}
+
+// Add appends value to the sequence.
+func (h *Hash) Add(value uint64) {
+	if h.algorithm == protocol.ChecksumAlgorithm_MD5_LEGACY {
+		h.h.Write(strconv.AppendUint(h.buf[:0], uint64(uint32(value)), 10))
+		return
+	}
+
+	encoded := h.buf[:]
+	if h.valueType == protocol.ValueType_UINT32 {
+		encoded = h.buf[:4]
+		binary.BigEndian.PutUint32(encoded, uint32(value))
+	} else {
+		binary.BigEndian.PutUint64(encoded, value)
+	}
+	h.h.Reset()
+	h.h.Write(h.link)
+	h.h.Write(encoded)
+	h.link = h.h.Sum(h.link[:0])
+}
+
+// Sum returns the hex encoded checksum of the sequence so far.
+func (h *Hash) Sum() string {
+	if h.link != nil {
+		return hex.EncodeToString(h.link)
+	}
+
+	return hex.EncodeToString(h.h.Sum(nil))
+}
+
+// Link returns the chain value of the sequence so far, or nil for MD5_LEGACY.
+func (h *Hash) Link() []byte {
+	if h.link == nil {
+		return nil
+	}
+
+	return append([]byte(nil), h.link...)
+}
+
+// MarshalBinary returns the chain value of the hash, or its midstate for MD5_LEGACY.
+func (h *Hash) MarshalBinary() ([]byte, error) {
+	if h.link != nil {
+		return h.Link(), nil
+	}
+
+	return h.h.(encoding.BinaryMarshaler).MarshalBinary()
+}
+
+// UnmarshalBinary restores the state returned by MarshalBinary for a Hash of the same algorithm.
+func (h *Hash) UnmarshalBinary(data []byte) error {
+	if h.link != nil {
+		if len(data) != h.h.Size() {
+			return fmt.Errorf("%s chain value must be %d bytes, got %d", h.algorithm, h.h.Size(), len(data))
+		}
+		h.link = append(h.link[:0], data...)
+
+		return nil
+	}
+
+	return h.h.(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+}
+
+// Sum returns the hex encoded checksum of values, of type valueType, with algorithm.
+func Sum(algorithm protocol.ChecksumAlgorithm, valueType protocol.ValueType, values []uint64) (string, error) {
+	h, err := New(algorithm, valueType)
+	if err != nil {
+		return "", err
+	}
+	for _, value := range values {
+		h.Add(value)
+	}
+
+	return h.Sum(), nil
+}
+
+// Chain extends link, the chain value of a sequence of valueType values, by values and returns the chain value of
+// the longer sequence. A nil link starts a new sequence. MD5_LEGACY has no chain values.
+func Chain(algorithm protocol.ChecksumAlgorithm, valueType protocol.ValueType, link []byte, values []uint64) ([]byte, error) {
+	if algorithm == protocol.ChecksumAlgorithm_MD5_LEGACY {
+		return nil, fmt.Errorf("%s has no chain values", algorithm)
+	}
+	h, err := New(algorithm, valueType)
+	if err != nil {
+		return nil, err
+	}
+	if link != nil {
+		if err := h.UnmarshalBinary(link); err != nil {
+			return nil, err
+		}
+	}
+	for _, value := range values {
+		h.Add(value)
+	}
+
+	return h.link, nil
+}
+
+// Supported reports whether algorithm can be used.
+func Supported(algorithm protocol.ChecksumAlgorithm) bool {
+	for _, a := range names {
+		if a == algorithm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Negotiate returns the first of the client's preferred algorithms that is supported for valueType values, or if
+// there is none MD5_LEGACY for UINT32 values and SHA256 for any other type.
+func Negotiate(preferences []protocol.ChecksumAlgorithm, valueType protocol.ValueType) protocol.ChecksumAlgorithm {
+	legacy := valueType == protocol.ValueType_UINT32
+	for _, algorithm := range preferences {
+		if Supported(algorithm) && (legacy || algorithm != protocol.ChecksumAlgorithm_MD5_LEGACY) {
+			return algorithm
+		}
+	}
+
+	if !legacy {
+		return protocol.ChecksumAlgorithm_SHA256
+	}
+
+	return protocol.ChecksumAlgorithm_MD5_LEGACY
+}
+
+// ParseList parses a comma separated list of algorithm names (md5, sha256, blake2b, crc32c or xxhash). An empty
+// list returns no algorithms.
+func ParseList(list string) ([]protocol.ChecksumAlgorithm, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var algorithms []protocol.ChecksumAlgorithm
+	for _, name := range strings.Split(list, ",") {
+		algorithm, ok := names[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown checksum algorithm %q, expected one of md5, sha256, blake2b, crc32c or xxhash", name)
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+
+	return algorithms, nil
+}
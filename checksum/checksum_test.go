@@ -0,0 +1,82 @@
+package checksum
+
+import (
+	"testing"
+
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// TestSum checks checksums against values worked out independently of this package from the encoding the
+// ENCODING_VERSION documents.
+func TestSum(t *testing.T) {
+	for _, test := range []struct {
+		algorithm protocol.ChecksumAlgorithm
+		valueType protocol.ValueType
+		values    []uint64
+		want      string
+	}{
+		{protocol.ChecksumAlgorithm_MD5_LEGACY, protocol.ValueType_UINT32, nil, "d41d8cd98f00b204e9800998ecf8427e"},
+		// MD5_LEGACY hashes "124294967295".
+		{protocol.ChecksumAlgorithm_MD5_LEGACY, protocol.ValueType_UINT32, []uint64{1, 2, 4294967295}, "9acba7f45c9fbcb315509889b5c6a4a2"},
+		{protocol.ChecksumAlgorithm_SHA256, protocol.ValueType_UINT32, nil, "dbc1b4c900ffe48d575b5da5c638040125f65db0fe3e24494b76ea986457d986"},
+		{protocol.ChecksumAlgorithm_SHA256, protocol.ValueType_UINT32, []uint64{1, 2, 4294967295}, "af0369d13298e1ed0e2b3acc40169a394afff3118a12c34a38b410aead621a04"},
+		{protocol.ChecksumAlgorithm_BLAKE2B_256, protocol.ValueType_UINT32, nil, "bb30a42c1e62f0afda5f0a4e8a562f7a13a24cea00ee81917b86b89e801314aa"},
+		{protocol.ChecksumAlgorithm_BLAKE2B_256, protocol.ValueType_UINT32, []uint64{1, 2, 4294967295}, "3d35f48f6d5f4bb59cdcab4dc327eeea00c50267eef9cd155920f19579721ae9"},
+		// The same 64 bits have a different checksum as each value type.
+		{protocol.ChecksumAlgorithm_SHA256, protocol.ValueType_INT64, []uint64{1<<64 - 1, 7}, "47107ef41e5e7d1cfc05e2a17f9db280c9b768c9796cc86c7e84c9c651161ed5"},
+		{protocol.ChecksumAlgorithm_SHA256, protocol.ValueType_FLOAT64, []uint64{1<<64 - 1, 7}, "a15d443a3195c3ad85f097672b5510113795ea0d28c464f81330f8e98c6db0e9"},
+	} {
+		got, err := Sum(test.algorithm, test.valueType, test.values)
+		if err != nil {
+			t.Fatalf("%s %s: %s", test.algorithm, test.valueType, err)
+		}
+		if got != test.want {
+			t.Errorf("%s of %s values %v is %s, want %s", test.algorithm, test.valueType, test.values, got, test.want)
+		}
+	}
+}
+
+// TestUnmarshalBinary checks that a restored Hash carries on with the checksum of the sequence it was saved from,
+// whether it keeps a chain value or an MD5_LEGACY midstate.
+func TestUnmarshalBinary(t *testing.T) {
+	for algorithm := range protocol.ChecksumAlgorithm_name {
+		algorithm := protocol.ChecksumAlgorithm(algorithm)
+		want, _ := Sum(algorithm, protocol.ValueType_UINT32, []uint64{3, 1, 4, 1, 5})
+
+		h, _ := New(algorithm, protocol.ValueType_UINT32)
+		for _, value := range []uint64{3, 1, 4} {
+			h.Add(value)
+		}
+		data, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %s", algorithm, err)
+		}
+
+		restored, _ := New(algorithm, protocol.ValueType_UINT32)
+		if err := restored.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%s: %s", algorithm, err)
+		}
+		restored.Add(1)
+		restored.Add(5)
+		if got := restored.Sum(); got != want {
+			t.Errorf("%s: restored checksum is %s, want %s", algorithm, got, want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, test := range []struct {
+		preferences []protocol.ChecksumAlgorithm
+		valueType   protocol.ValueType
+		want        protocol.ChecksumAlgorithm
+	}{
+		{nil, protocol.ValueType_UINT32, protocol.ChecksumAlgorithm_MD5_LEGACY},
+		{nil, protocol.ValueType_INT64, protocol.ChecksumAlgorithm_SHA256},
+		{[]protocol.ChecksumAlgorithm{protocol.ChecksumAlgorithm(99), protocol.ChecksumAlgorithm_CRC32C}, protocol.ValueType_UINT32, protocol.ChecksumAlgorithm_CRC32C},
+		{[]protocol.ChecksumAlgorithm{protocol.ChecksumAlgorithm_MD5_LEGACY, protocol.ChecksumAlgorithm_XXHASH64}, protocol.ValueType_FLOAT64, protocol.ChecksumAlgorithm_XXHASH64},
+	} {
+		if got := Negotiate(test.preferences, test.valueType); got != test.want {
+			t.Errorf("Negotiate(%v, %s) = %s, want %s", test.preferences, test.valueType, got, test.want)
+		}
+	}
+}
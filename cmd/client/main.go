@@ -1,53 +1,250 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"crypto/md5"
-	"encoding/hex"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"os"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/jamesrobb/ably-takehome/checksum"
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/fairness"
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/merkle"
 	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+	"github.com/jamesrobb/ably-takehome/signing"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-const MAX_NUMBERS uint32 = 65535
+// RETRY_ATTEMPTS is how many times a request the server turned away as busy is retried before giving up.
+const RETRY_ATTEMPTS = 10
+
+// DEFAULT_RETRY_DELAY is how long the client waits before retrying or resuming when the server didn't say.
+const DEFAULT_RETRY_DELAY = time.Second
+
+// recovery is what the client does about an error that ended a stream.
+type recovery int
+
+const (
+	// GIVE_UP fails, the request can't succeed however often it is made.
+	GIVE_UP recovery = iota
+	// RETRY makes the same request again, the server turned it away for now.
+	RETRY
+	// RESUME continues the stream from the last number received, the stream or the server's storage broke.
+	RESUME
+)
+
+// streamOptions holds the optional NumbersRequest fields that apply to every request the client makes.
+type streamOptions struct {
+	rate       uint32
+	intervalMs uint32
+	// acked selects the GetAckedNumbers RPC, where the client acknowledges each number it processes.
+	acked           bool
+	batchSize       uint32
+	flushIntervalMs uint32
+	// reconnect resumes a broken stream on a new connection instead of failing.
+	reconnect bool
+	// checksumAlgorithms are the checksum algorithms the client accepts, most preferred first. When empty the
+	// server uses MD5_LEGACY.
+	checksumAlgorithms []protocol.ChecksumAlgorithm
+	// merkleChunkSize asks the server for Merkle proofs of chunks of this many numbers, 0 asks for none.
+	merkleChunkSize uint32
+	// segmentFile is where the numbers received are saved along with their Merkle proofs, empty saves nothing.
+	segmentFile string
+	// serverKey is the key the server's signature of the final checksum is verified with, nil skips verifying it.
+	serverKey ed25519.PublicKey
+	// clientSeed is mixed into the seed the server picks, empty contributes nothing.
+	clientSeed []byte
+	// prngAlgorithm is the generator the server is asked to generate the sequence with.
+	prngAlgorithm protocol.PrngAlgorithm
+	// source is the name of the source the server is asked to take the numbers from in place of prngAlgorithm,
+	// empty asks for the generator.
+	source string
+	// distribution is what the server is asked to draw the values from, nil asks for the generator's raw numbers.
+	distribution *protocol.Distribution
+	// duration bounds the stream by wall-clock time, 0 leaves it bounded by its length alone.
+	duration time.Duration
+	// unbounded asks for a stream with neither a length nor a duration.
+	unbounded bool
+	// checkpointMessages and checkpointInterval are how often the server is asked to send checkpoints, 0 leaves
+	// either out.
+	checkpointMessages uint32
+	checkpointInterval time.Duration
+}
+
+// keepNumbers reports whether the numbers received are kept, to be checked as a whole once the stream ends. An
+// unbounded stream's aren't, so that the client doesn't grow for as long as it runs. Each is checked against the
+// chain, the checkpoints and the range of the distribution as it arrives, and then dropped.
+func (opts streamOptions) keepNumbers() bool {
+	return !opts.unbounded
+}
+
+// valueType returns the type of the values the server is asked for.
+func (opts streamOptions) valueType() protocol.ValueType {
+	return distribution.ValueType(opts.distribution)
+}
 
 func main() {
 	port := flag.Int("port", 50051, "port the of the server to be connected to")
-	numMessagesFlag := flag.Uint("numMessages", 0, "number of messages to receive, specifying 0 will result in a random value beteen 1 and 65535")
+	numMessagesFlag := flag.Uint("numMessages", 0, "number of messages to receive, specifying 0 will result in a random value beteen 1 and -maxNumbers unless -duration or -unbounded is given")
+	maxNumbers := flag.Uint("maxNumbers", 65535, "largest number of messages -numMessages=0 picks, the server cuts longer streams to its own limit")
+	duration := flag.Duration("duration", 0, "wall-clock time the stream runs for, the server ends it with the first number sent after it has passed, with -numMessages it ends at whichever comes first")
+	unbounded := flag.Bool("unbounded", false, "receive an unbounded stream, which runs until the client is stopped or the server's limits end it (not in test mode)")
+	checkpointMessages := flag.Uint("checkpointMessages", 0, "have the server send a checkpoint checksum every this many messages and verify each one, in test mode the stream is resumed from the last checkpoint, 0 disables")
+	checkpointInterval := flag.Duration("checkpointInterval", 0, "have the server send a checkpoint checksum at least this often, as with -checkpointMessages, 0 disables")
 	testUUID := flag.String("testUUID", "", "UUID used (used in test mode only)")
 	testChecksum := flag.String("testChecksum", "", "expected checksum of successful result (used in test mode only)")
-	seed := flag.Uint("testSeed", 1, "seed used for the server's PRNG (used in test mode only)")
+	seed := flag.Uint("testSeed", 1, "seed used for the server's PRNG, 0 sends no seed for a source that takes none (used in test mode only)")
+	wideSeed := flag.String("testWideSeed", "", "hex encoded 64 or 256 bit seed used for the server's PRNG in place of -testSeed (used in test mode only)")
+	prngName := flag.String("prng", "mt19937", "generator the server generates the sequence with, one of mt19937, mt19937-64, xoshiro256+, xoshiro256++, xoshiro256**, chacha20 and crypto")
+	sourceName := flag.String("source", "", "name of a source the server is configured with to take the numbers from in place of -prng, such as a device or a recording")
 	testMode := flag.Bool("testMode", false, "run a sanity check on an interrupted stream")
+	rate := flag.Uint("rate", 0, "requested number of messages per second, the server clamps this to its configured limits")
+	intervalMs := flag.Uint("intervalMs", 0, "requested delay in milliseconds between messages (alternative to -rate)")
+	acked := flag.Bool("acked", false, "acknowledge every number received so that the server redelivers unacknowledged numbers on resume")
+	batchSize := flag.Uint("batchSize", 0, "number of numbers the server should pack into each message, 0 or 1 disables batching")
+	flushIntervalMs := flag.Uint("flushIntervalMs", 0, "longest time in milliseconds the server may hold a partial batch, 0 waits for full batches")
+	reconnect := flag.Bool("reconnect", false, "when the stream breaks (e.g., the server restarts) reconnect and resume it instead of failing")
+	testPause := flag.Duration("testPause", 2*time.Second, "time to wait before resuming the interrupted stream (used in test mode only)")
+	resumePort := flag.Int("resumePort", 0, "port of the server to resume the interrupted stream on, 0 uses -port (used in test mode only)")
+	checksumAlgorithms := flag.String("checksum", "", "comma separated checksum algorithms to accept, most preferred first, from md5, sha256, blake2b, crc32c and xxhash (default md5)")
+	serverSessionID := flag.Bool("serverSessionID", false, "have the server issue the stream's ID, for servers run with -issueSessionIDs (in test mode -testUUID is ignored)")
+	merkleChunkSize := flag.Uint("merkleChunkSize", 0, "have the server send Merkle proofs for chunks of this many numbers (a power of two of at least 16) and verify each chunk as it completes, 0 disables")
+	serverPublicKey := flag.String("serverPublicKey", "", "hex encoded Ed25519 public key of the server, the signature of the final checksum is verified with it and the client fails if it doesn't match")
+	printPublicKey := flag.Bool("printPublicKey", false, "print the server's public key, as given to -serverPublicKey, and exit")
+	clientSeed := flag.String("clientSeed", "", "string mixed into the seed the server picks, the client fails unless the server reveals its seed and the sequence replays from it (not with -testSeed)")
+	distributionSpec := flag.String("distribution", "", "distribution the server draws the values from, one of uniform:MIN:MAX, sample:MIN:MAX (without replacement), gaussian:MEAN:STDDEV, exponential:RATE and poisson:LAMBDA, empty sends the generator's raw numbers")
+	segmentFile := flag.String("segmentFile", "", "file to save the numbers received and their Merkle proofs to, for verifying offline with cmd/merkle (requires -merkleChunkSize)")
 	flag.Parse()
 
+	algorithms, err := checksum.ParseList(*checksumAlgorithms)
+	if err != nil {
+		fmt.Printf("FAILURE: %s\n", err)
+		os.Exit(1)
+	}
+	if *segmentFile != "" && *merkleChunkSize == 0 {
+		fmt.Println("FAILURE: -segmentFile requires -merkleChunkSize")
+		os.Exit(1)
+	}
+	prngAlgorithm, err := generator.Parse(*prngName)
+	if err != nil {
+		fmt.Printf("FAILURE: %s\n", err)
+		os.Exit(1)
+	}
+	if *unbounded && (*numMessagesFlag > 0 || *duration > 0 || *testMode) {
+		fmt.Println("FAILURE: -unbounded can't be combined with -numMessages, -duration or -testMode")
+		os.Exit(1)
+	}
+	if *unbounded && (*clientSeed != "" || *segmentFile != "") {
+		fmt.Println("FAILURE: -unbounded can't be combined with -clientSeed or -segmentFile, which need every number kept")
+		os.Exit(1)
+	}
+	if *maxNumbers == 0 || *maxNumbers > math.MaxUint32 {
+		fmt.Printf("FAILURE: -maxNumbers must be between 1 and %d\n", uint32(math.MaxUint32))
+		os.Exit(1)
+	}
+	if *sourceName != "" && prngAlgorithm != protocol.PrngAlgorithm_MT19937 {
+		fmt.Println("FAILURE: -source can't be combined with -prng")
+		os.Exit(1)
+	}
+	dist, err := distribution.Parse(*distributionSpec)
+	if err != nil {
+		fmt.Printf("FAILURE: %s\n", err)
+		os.Exit(1)
+	}
+	var testSeed generator.Seed
+	if *seed > 0 {
+		testSeed = generator.Uint32Seed(uint32(*seed))
+	}
+	if *wideSeed != "" {
+		testSeed, err = generator.ParseSeed(*wideSeed)
+		if err == nil && len(testSeed) == 4 {
+			err = fmt.Errorf("-testWideSeed must be 64 or 256 bits, use -testSeed for a 32 bit seed")
+		}
+		if err != nil {
+			fmt.Printf("FAILURE: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	var serverKey ed25519.PublicKey
+	if *serverPublicKey != "" {
+		serverKey, err = signing.ParsePublicKey(*serverPublicKey)
+		if err != nil {
+			fmt.Printf("FAILURE: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	opts := streamOptions{
+		rate:               uint32(*rate),
+		intervalMs:         uint32(*intervalMs),
+		acked:              *acked,
+		batchSize:          uint32(*batchSize),
+		flushIntervalMs:    uint32(*flushIntervalMs),
+		reconnect:          *reconnect,
+		checksumAlgorithms: algorithms,
+		merkleChunkSize:    uint32(*merkleChunkSize),
+		segmentFile:        *segmentFile,
+		serverKey:          serverKey,
+		clientSeed:         []byte(*clientSeed),
+		prngAlgorithm:      prngAlgorithm,
+		source:             *sourceName,
+		distribution:       dist,
+		duration:           *duration,
+		unbounded:          *unbounded,
+		checkpointMessages: uint32(*checkpointMessages),
+		checkpointInterval: *checkpointInterval,
+	}
+
 	serverAddress := fmt.Sprintf("localhost:%d", *port)
+	resumeAddress := serverAddress
+	if *resumePort != 0 {
+		resumeAddress = fmt.Sprintf("localhost:%d", *resumePort)
+	}
 
+	// A stream bounded by its duration, or not at all, only has a length if one is asked for.
 	numNumbers := uint32(*numMessagesFlag)
-	if numNumbers == 0 {
+	if numNumbers == 0 && *duration == 0 && !*unbounded {
 		rand.Seed(time.Now().Unix())
-		numNumbers = uint32(rand.Intn(int(MAX_NUMBERS)-1)) + 1
+		numNumbers = uint32(rand.Int63n(int64(*maxNumbers))) + 1
 	}
-	if numNumbers > MAX_NUMBERS {
-		numNumbers = MAX_NUMBERS
+
+	if *printPublicKey {
+		if err := printServerKey(serverAddress); err != nil {
+			fmt.Printf("FAILURE: %s\n", err)
+			os.Exit(1)
+		}
+
+		return
 	}
 
 	if *testMode {
-		uuid, err := uuid.Parse(*testUUID)
+		clientUUID, err := uuid.Parse(*testUUID)
+		if *serverSessionID {
+			clientUUID, err = uuid.Nil, nil
+		}
 		if err != nil {
 			fmt.Println("FAILURE: unable to parse provided UUID")
 			os.Exit(1)
 		}
-		err = testOperation(serverAddress, numNumbers, uuid, uint32(*seed), *testChecksum)
+		err = testOperation(serverAddress, resumeAddress, numNumbers, clientUUID, testSeed, *testChecksum, *testPause, opts)
 		if err != nil {
 			fmt.Printf("FAILURE: %s\n", err)
 			os.Exit(1)
@@ -55,7 +252,7 @@ func main() {
 
 		return
 	} else {
-		err := standardOperation(serverAddress, numNumbers)
+		err := standardOperation(serverAddress, numNumbers, *serverSessionID, opts)
 		if err != nil {
 			fmt.Printf("FAILURE: %s\n", err)
 			os.Exit(1)
@@ -81,33 +278,38 @@ func getClient(serverAddress string) (*grpc.ClientConn, protocol.NumbersClient,
 
 func testOperation(
 	serverAddress string,
+	resumeAddress string,
 	numMessages uint32,
 	uuid uuid.UUID,
-	seed uint32,
+	seed generator.Seed,
 	testChecksum string,
+	pause time.Duration,
+	opts streamOptions,
 ) error {
 	if numMessages%2 != 0 {
 		return fmt.Errorf("for testMode specify an even number of messages to be received")
 	}
 
-	conn1, client1, err := getClient(serverAddress)
-	if err != nil {
-		return err
-	}
-
-	numbers1, _, err := getNumbers(client1, uuid, numMessages, seed, numMessages/2)
+	sess := &session{id: uuid}
+	numbers1, _, err := receiveNumbers(serverAddress, sess, numMessages, seed, numMessages/2, opts)
 	if err != nil {
 		return fmt.Errorf("error getting first batch of numbers: %s", err)
 	}
-	conn1.Close()
 
-	time.Sleep(2 * time.Second)
+	time.Sleep(pause)
 
-	_, client2, err := getClient(serverAddress)
-	if err != nil {
-		return err
+	// The server checks the checkpoint against the sequence, so resuming from it rather than from the last number
+	// received proves both sides agree on everything up to it. GetAckedNumbers resumes from the last number acked.
+	if sess.checkpoint != nil && !opts.acked {
+		index, err := sess.rewindToCheckpoint()
+		if err != nil {
+			return err
+		}
+		numbers1 = numbers1[:index]
+		fmt.Printf("resuming from the checkpoint at index %d\n", index)
 	}
-	numbers2, serverChecksum, err := getNumbers(client2, uuid, numMessages, 0, 0)
+
+	numbers2, serverChecksum, err := receiveNumbers(resumeAddress, sess, numMessages, nil, 0, opts)
 	if err != nil {
 		return fmt.Errorf("error getting second batch of numbers: %s", err)
 	}
@@ -116,82 +318,243 @@ func testOperation(
 		numbers1 = append(numbers1, num)
 	}
 
-	calculatedChecksum := calculateChecksum(numbers1)
+	calculatedChecksum, err := calculateChecksum(sess, numbers1, opts)
+	if err != nil {
+		return err
+	}
 	if calculatedChecksum != serverChecksum {
 		return fmt.Errorf("calculatedChecksum=%s does not match serverChecksum=%s\n", calculatedChecksum, serverChecksum)
 	}
 	if testChecksum != calculatedChecksum {
 		return fmt.Errorf("testChecksum=%s does not match calculatedChecksum=%s\n", testChecksum, calculatedChecksum)
 	}
+	if err := verifySignature(sess, uint32(len(numbers1)), calculatedChecksum, opts); err != nil {
+		return err
+	}
+	if err := verifySeed(sess, numbers1, opts); err != nil {
+		return err
+	}
+	if err := writeSegment(sess, numMessages, numbers1, opts); err != nil {
+		return err
+	}
 
 	fmt.Printf("SUCCESS: checksum=%s\n", serverChecksum)
 
 	return nil
 }
 
-func standardOperation(serverAddress string, numMessages uint32) error {
-	_, client, err := getClient(serverAddress)
-	if err != nil {
-		return err
+func standardOperation(serverAddress string, numMessages uint32, serverSessionID bool, opts streamOptions) error {
+	sess := &session{id: uuid.New()}
+	if serverSessionID {
+		sess.id = uuid.Nil
 	}
-
-	u := uuid.New()
-	numbers, serverChecksum, err := getNumbers(client, u, numMessages, 0, 0)
+	numbers, serverChecksum, err := receiveNumbers(serverAddress, sess, numMessages, nil, 0, opts)
 	if err != nil {
 		return fmt.Errorf("error getting numbers: %s\n", err)
 	}
 
-	calculatedChecksum := calculateChecksum(numbers)
+	calculatedChecksum, err := calculateChecksum(sess, numbers, opts)
+	if err != nil {
+		return err
+	}
 	if calculatedChecksum != serverChecksum {
 		return fmt.Errorf("calculatedChecksum=%s does not match serverChecksum=%s\n", calculatedChecksum, serverChecksum)
 	}
+	if err := verifySignature(sess, sess.last, calculatedChecksum, opts); err != nil {
+		return err
+	}
+	if err := verifySeed(sess, numbers, opts); err != nil {
+		return err
+	}
+	if err := writeSegment(sess, numMessages, numbers, opts); err != nil {
+		return err
+	}
 
 	fmt.Printf("success checksum=%s\n", serverChecksum)
 
 	return nil
 }
 
-func calculateChecksum(numbers []uint32) string {
-	h := md5.New()
+// receiveNumbers connects to the server and receives the numbers that follow the last one sess received, until
+// either breakAfter numbers or the checksum have been received. sess is updated with the session ID and resume
+// tokens the server sends, so that the stream can be resumed. A request the server turns away as busy is retried,
+// and with opts.reconnect a stream that breaks is resumed on a new connection. Otherwise the numbers received so far
+// are returned along with the error. Unless opts.keepNumbers the numbers are checked and dropped, and none are
+// returned.
+func receiveNumbers(
+	serverAddress string,
+	sess *session,
+	numNumbers uint32,
+	seed generator.Seed,
+	breakAfter uint32,
+	opts streamOptions,
+) ([]uint64, string, error) {
+	numbers := make([]uint64, 0)
+	first := sess.last
+	retries := 0
 
-	for _, num := range numbers {
-		io.WriteString(h, fmt.Sprintf("%d", num))
+	for {
+		conn, client, err := getClient(serverAddress)
+		if err != nil {
+			return numbers, "", err
+		}
+
+		remaining := uint32(0)
+		if breakAfter > 0 {
+			remaining = breakAfter - (sess.last - first)
+		}
+		received, serverChecksum, err := getNumbers(client, sess, numNumbers, seed, remaining, opts)
+		conn.Close()
+		numbers = append(numbers, received...)
+
+		if err == nil {
+			return numbers, serverChecksum, nil
+		}
+
+		switch action, delay := recoveryFor(err); {
+		case action == RETRY && retries < RETRY_ATTEMPTS:
+			retries++
+			fmt.Printf("server turned the request away, retrying in %s: %s\n", delay, err)
+			time.Sleep(delay)
+		case action == RESUME && opts.reconnect:
+			fmt.Printf("stream broke after index %d, reconnecting in %s: %s\n", sess.last, delay, err)
+			time.Sleep(delay)
+		default:
+			return numbers, "", err
+		}
+	}
+}
+
+// recoveryFor decides what to do about an error that ended a stream from its status code, and how long to wait
+// before doing it. The wait is taken from the status' RetryInfo when the server sent one. Errors that didn't come
+// from the server, such as a number arriving out of order, are given up on.
+func recoveryFor(err error) (recovery, time.Duration) {
+	var statusErr interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &statusErr) {
+		return GIVE_UP, 0
+	}
+	s := statusErr.GRPCStatus()
+
+	delay := DEFAULT_RETRY_DELAY
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.RetryDelay != nil {
+			delay = info.RetryDelay.AsDuration()
+		}
+	}
+
+	switch s.Code() {
+	case codes.ResourceExhausted, codes.AlreadyExists:
+		// The server is at its stream limit, or another stream for the client is still open.
+		return RETRY, delay
+	case codes.Unavailable:
+		// The connection broke, or the server couldn't reach its state storage.
+		return RESUME, delay
+	}
+
+	// InvalidArgument and OutOfRange won't change on a retry, FailedPrecondition means the client ID has expired,
+	// NotFound that there is nothing to resume and Aborted that a newer stream has taken the client over.
+	return GIVE_UP, 0
+}
+
+// calculateChecksum returns the checksum of numbers with the algorithm the server reported using, which must be
+// one the client asked for. Every algorithm but MD5_LEGACY chains the numbers, so the checksum is the chain value
+// of the last number. The values of a distribution are also checked to be ones it can draw. Unless
+// opts.keepNumbers there are no numbers, and the session's running checksum is returned instead.
+func calculateChecksum(sess *session, numbers []uint64, opts streamOptions) (string, error) {
+	if err := checkAlgorithm(sess.algorithm, opts); err != nil {
+		return "", err
+	}
+	if !opts.keepNumbers() && sess.hash != nil {
+		if sess.hash.Algorithm() != sess.algorithm {
+			return "", fmt.Errorf("server sent the numbers with checksum algorithm %s, but the checksum with %s", sess.hash.Algorithm(), sess.algorithm)
+		}
+
+		return sess.hash.Sum(), nil
+	}
+	if err := distribution.Verify(opts.distribution, numbers); err != nil {
+		return "", err
+	}
+
+	return checksum.Sum(sess.algorithm, opts.valueType(), numbers)
+}
+
+// checkAlgorithm returns an error unless algorithm is one the client asked for, or what the server falls back to
+// for the type of values asked for when the client asked for none that suit them.
+func checkAlgorithm(algorithm protocol.ChecksumAlgorithm, opts streamOptions) error {
+	accepted := algorithm == checksum.Negotiate(opts.checksumAlgorithms, opts.valueType())
+	for _, requested := range opts.checksumAlgorithms {
+		accepted = accepted || requested == algorithm
+	}
+	if !accepted {
+		return fmt.Errorf("server used checksum algorithm %s, which was not requested", algorithm)
 	}
 
-	return hex.EncodeToString(h.Sum(nil)[:])
+	return nil
 }
 
 func getNumbers(
 	client protocol.NumbersClient,
-	clientUUID uuid.UUID,
+	sess *session,
 	numNumbers uint32,
-	seed uint32,
+	seed generator.Seed,
 	breakAfter uint32,
-) ([]uint32, string, error) {
-	m := &protocol.NumbersRequest{
-		ClientId:   clientUUID[:],
-		NumNumbers: numNumbers,
-		Seed:       seed,
+	opts streamOptions,
+) ([]uint64, string, error) {
+	if opts.acked {
+		return getAckedNumbers(client, sess, numNumbers, seed, breakAfter, opts)
 	}
 
+	first := sess.last
+	m := numbersRequest(sess, numNumbers, seed, first, opts)
+
 	stream, err := client.GetNumbers(context.Background(), m)
 	if err != nil {
 		return nil, "", err
 	}
 
 	serverChecksum := ""
-	numbers := make([]uint32, 0)
+	numbers := make([]uint64, 0)
 
 	for {
 		number, err := stream.Recv()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return nil, "", fmt.Errorf("error reading from stream: %s", err)
+			return numbers, "", fmt.Errorf("error reading from stream: %w", err)
 		}
 
-		fmt.Println(number.Number)
-		numbers = append(numbers, number.Number)
+		// numbers must arrive in order and pick up exactly where the last one received left off, which is checked
+		// before anything the message carries is taken on
+		expectedIndex := sess.last + 1
+		if number.Index != expectedIndex {
+			return numbers, "", fmt.Errorf("received index %d, expected index %d", number.Index, expectedIndex)
+		}
+
+		if err := sess.record(number, opts); err != nil {
+			return numbers, "", err
+		}
+
+		// breakAfter is to be able to simulate a connection being broken. When a batch takes us past
+		// breakAfter the rest of it is dropped, as if the connection broke part way through.
+		received := distribution.Values(number, opts.valueType())
+		kept := received
+		if breakAfter > 0 && sess.last-first+uint32(len(received)) > breakAfter {
+			kept = received[:breakAfter-(sess.last-first)]
+		}
+
+		if err := sess.advanceChain(number, kept, len(kept) == len(received), opts); err != nil {
+			return numbers, "", err
+		}
+		if err := sess.add(number, kept, opts); err != nil {
+			return numbers, "", err
+		}
+		if err := sess.checkCheckpoint(number, len(kept) == len(received), opts); err != nil {
+			return numbers, "", err
+		}
+		if err := sess.checkChunks(number, number.Index, kept, numNumbers, opts); err != nil {
+			return numbers, "", err
+		}
+		numbers = keep(numbers, kept, opts)
 
 		// if we have a non-empty checksum then the number stream is finished
 		if number.Checksum != "" {
@@ -201,9 +564,171 @@ func getNumbers(
 			break
 		}
 
+		if breakAfter > 0 {
+			if sess.last-first >= breakAfter {
+				stream.CloseSend()
+
+				return numbers, "", nil
+			}
+		}
+	}
+
+	return numbers, serverChecksum, nil
+}
+
+// numbersRequest returns the request for the numbers of sess's stream that follow lastIndex, of which there are
+// numNumbers in all.
+func numbersRequest(sess *session, numNumbers uint32, seed generator.Seed, lastIndex uint32, opts streamOptions) *protocol.NumbersRequest {
+	seed32, wideSeed := requestSeed(seed)
+
+	return &protocol.NumbersRequest{
+		ClientId:             sess.clientID(),
+		NumNumbers:           numNumbers,
+		Seed:                 seed32,
+		WideSeed:             wideSeed,
+		PrngAlgorithm:        opts.prngAlgorithm,
+		Source:               opts.source,
+		Rate:                 opts.rate,
+		IntervalMs:           opts.intervalMs,
+		LastIndex:            lastIndex,
+		ResumeToken:          sess.resumeToken,
+		ChecksumAlgorithms:   opts.checksumAlgorithms,
+		MerkleChunkSize:      opts.merkleChunkSize,
+		ClientSeed:           opts.clientSeed,
+		BatchSize:            opts.batchSize,
+		FlushIntervalMs:      opts.flushIntervalMs,
+		Distribution:         opts.distribution,
+		DurationMs:           uint32(opts.duration / time.Millisecond),
+		Unbounded:            opts.unbounded,
+		CheckpointMessages:   opts.checkpointMessages,
+		CheckpointIntervalMs: uint32(opts.checkpointInterval / time.Millisecond),
+		CheckpointChecksum:   sess.resumeChecksum,
+	}
+}
+
+// keep prints received, numbers that have been checked, and appends them to numbers when opts.keepNumbers.
+func keep(numbers []uint64, received []uint64, opts streamOptions) []uint64 {
+	for _, n := range received {
+		fmt.Println(distribution.Format(opts.valueType(), n))
+	}
+	if !opts.keepNumbers() {
+		return numbers
+	}
+
+	return append(numbers, received...)
+}
+
+// requestSeed returns the seed and wide_seed fields of a NumbersRequest for seed, which is nil to leave picking the
+// seed to the server.
+func requestSeed(seed generator.Seed) (uint32, []byte) {
+	if len(seed) == 4 {
+		return binary.BigEndian.Uint32(seed), nil
+	}
+
+	return 0, seed
+}
+
+// getAckedNumbers behaves like getNumbers but uses the GetAckedNumbers RPC. Every number is acknowledged once it
+// has been processed, and numbers the server redelivers because their ack was lost are discarded.
+func getAckedNumbers(
+	client protocol.NumbersClient,
+	sess *session,
+	numNumbers uint32,
+	seed generator.Seed,
+	breakAfter uint32,
+	opts streamOptions,
+) ([]uint64, string, error) {
+	first := sess.last
+	m := numbersRequest(sess, numNumbers, seed, first, opts)
+
+	stream, err := client.GetAckedNumbers(context.Background())
+	if err != nil {
+		return nil, "", err
+	}
+	err = stream.Send(&protocol.AckedNumbersRequest{
+		Message: &protocol.AckedNumbersRequest_Request{Request: m},
+	})
+	if err != nil {
+		if err == io.EOF {
+			if _, recvErr := stream.Recv(); recvErr != nil {
+				err = recvErr
+			}
+		}
+		return nil, "", fmt.Errorf("error sending request: %w", err)
+	}
+
+	serverChecksum := ""
+	numbers := make([]uint64, 0)
+
+	for {
+		number, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return numbers, "", fmt.Errorf("error reading from stream: %w", err)
+		}
+
+		processedIndex := sess.last
+		if number.Index > processedIndex+1 {
+			return numbers, "", fmt.Errorf("received index %d, expected index %d", number.Index, processedIndex+1)
+		}
+
+		if err := sess.record(number, opts); err != nil {
+			return numbers, "", err
+		}
+
+		received := distribution.Values(number, opts.valueType())
+
+		// skip anything already processed, the ack for it must have been lost so it is acknowledged again
+		skip := processedIndex + 1 - number.Index
+		if skip > uint32(len(received)) {
+			skip = uint32(len(received))
+		}
+		if err := sess.advanceChain(number, received[skip:], skip < uint32(len(received)), opts); err != nil {
+			return numbers, "", err
+		}
+		if err := sess.add(number, received[skip:], opts); err != nil {
+			return numbers, "", err
+		}
+		if err := sess.checkCheckpoint(number, skip < uint32(len(received)), opts); err != nil {
+			return numbers, "", err
+		}
+		if err := sess.checkChunks(number, number.Index+skip, received[skip:], numNumbers, opts); err != nil {
+			return numbers, "", err
+		}
+		numbers = keep(numbers, received[skip:], opts)
+
+		// a single ack covers the whole batch
+		lastReceived := number.Index + uint32(len(received)) - 1
+		err = stream.Send(&protocol.AckedNumbersRequest{
+			Message: &protocol.AckedNumbersRequest_Ack{Ack: &protocol.Ack{Index: lastReceived}},
+		})
+		if err != nil {
+			// Send only reports that the stream is gone, the reason why comes from Recv.
+			if err == io.EOF {
+				if _, recvErr := stream.Recv(); recvErr != nil {
+					err = recvErr
+				}
+			}
+			return numbers, "", fmt.Errorf("error acknowledging index %d: %w", lastReceived, err)
+		}
+
+		// if we have a non-empty checksum then the number stream is finished
+		if number.Checksum != "" {
+			serverChecksum = number.Checksum
+			stream.CloseSend()
+
+			// wait for the server to end the stream so we know the final ack arrived
+			if _, err := stream.Recv(); err != io.EOF {
+				return nil, "", fmt.Errorf("expected the server to end the stream: %s", err)
+			}
+
+			break
+		}
+
 		// breakAfter is to be able to simulate a connection being broken
 		if breakAfter > 0 {
-			if len(numbers) == int(breakAfter) {
+			if sess.last-first >= breakAfter {
 				stream.CloseSend()
 
 				return numbers, "", nil
@@ -213,3 +738,377 @@ func getNumbers(
 
 	return numbers, serverChecksum, nil
 }
+
+// session identifies a stream to the server across the connections it is received over.
+type session struct {
+	// id is uuid.Nil until the server issues one, when the client leaves choosing it to the server.
+	id uuid.UUID
+	// resumeToken is the latest resume token the server sent, it is empty if the server sends none.
+	resumeToken []byte
+	// algorithm is the checksum algorithm the server reported alongside the checksum.
+	algorithm protocol.ChecksumAlgorithm
+	// chain is the chain value of every number received so far, it is nil until the server sends one.
+	chain []byte
+	// last is the index of the last number received, the one the stream is resumed after.
+	last uint32
+	// hash is the checksum of every number received so far, which checkpoints, and the checksum of a stream whose
+	// numbers aren't kept, are checked against. It is nil until the first number arrives.
+	hash *checksum.Hash
+	// checkpoint is the latest checkpoint verified, it is nil until one is.
+	checkpoint *savedCheckpoint
+	// resumeChecksum is the checksum of the checkpoint the stream is being resumed from, it is empty once the
+	// server has accepted it.
+	resumeChecksum string
+	// merkleRoot is the root of the sequence's Merkle tree, it is nil until the server sends one.
+	merkleRoot []byte
+	// chunk holds the numbers received of the chunk being received, the first of which has index chunkFirst.
+	chunk      []uint32
+	chunkFirst uint32
+	// proofs are the proofs of every chunk verified so far.
+	proofs []merkle.Proof
+	// seedCommitment is the commitment to the sequence's seed, it is nil until the server sends one.
+	seedCommitment []byte
+	// signature is the server's signature of the final checksum.
+	signature []byte
+	// serverSeed and seedNonce are what the server revealed seedCommitment was made from, they are nil unless the
+	// server picked the seed.
+	serverSeed []byte
+	seedNonce  []byte
+	// prngAlgorithm and seedBits are the generator of the sequence and the width of its seed, as the server
+	// reported them, which it has once reported is set. seedBits is 0 for CRYPTO_RAND.
+	prngAlgorithm protocol.PrngAlgorithm
+	seedBits      uint32
+	reported      bool
+}
+
+// clientID returns the client_id to request with, which is left empty for the server to issue one when the session
+// has no ID yet.
+func (sess *session) clientID() []byte {
+	if sess.id == uuid.Nil {
+		return nil
+	}
+
+	return sess.id[:]
+}
+
+// record keeps the session ID, resume token, seed commitment and generator response carries, if any. A seed
+// commitment that changes part way through the sequence, a seed revealed without having been committed to before
+// the first number, or a source, generator or distribution other than the one requested, is an error.
+func (sess *session) record(response *protocol.NumberResponse, opts streamOptions) error {
+	sess.resumeChecksum = ""
+	if len(response.ResumeToken) > 0 {
+		sess.resumeToken = response.ResumeToken
+	}
+	// MT19937 always has a seed, so a response that reports no source, generator or seed reports nothing. Servers
+	// from before sources were added only report the generator.
+	if response.Source != "" || response.SeedBits > 0 || response.PrngAlgorithm != protocol.PrngAlgorithm_MT19937 {
+		source := response.Source
+		if source == "" {
+			source = generator.Name(response.PrngAlgorithm)
+		}
+		if opts.source != "" && source != opts.source {
+			return fmt.Errorf("server took the numbers from %s, but %s was requested", source, opts.source)
+		}
+		if opts.source == "" && response.PrngAlgorithm != opts.prngAlgorithm {
+			return fmt.Errorf("server generated the sequence with %s, but %s was requested", response.PrngAlgorithm, opts.prngAlgorithm)
+		}
+		// The distribution is reported alongside the generator, on the first response of every stream.
+		if !proto.Equal(response.Distribution, opts.distribution) {
+			return fmt.Errorf("server drew the values from %s, but %s was requested", distribution.String(response.Distribution), distribution.String(opts.distribution))
+		}
+		if !sess.reported {
+			if response.SeedBits > 0 {
+				fmt.Printf("sequence is generated by %s from a %d bit seed\n", source, response.SeedBits)
+			} else {
+				fmt.Printf("sequence is generated by %s, which takes no seed\n", source)
+			}
+		}
+		sess.prngAlgorithm, sess.seedBits, sess.reported = response.PrngAlgorithm, response.SeedBits, true
+	}
+	if len(response.ServerSeed) > 0 {
+		if sess.seedCommitment == nil && response.Index != 1 {
+			return fmt.Errorf("server revealed its seed at index %d without having committed to it", response.Index)
+		}
+		sess.serverSeed = response.ServerSeed
+		sess.seedNonce = response.SeedNonce
+	}
+	if len(response.SeedCommitment) > 0 {
+		if sess.seedCommitment != nil && !bytes.Equal(sess.seedCommitment, response.SeedCommitment) {
+			return fmt.Errorf("server sent seed commitment %x, but the stream's commitment is %x", response.SeedCommitment, sess.seedCommitment)
+		}
+		sess.seedCommitment = response.SeedCommitment
+	}
+	if response.Checksum != "" {
+		sess.algorithm = response.ChecksumAlgorithm
+		sess.signature = response.Signature
+	}
+	if len(response.SessionId) == 0 {
+		return nil
+	}
+
+	id, err := uuid.FromBytes(response.SessionId)
+	if err != nil {
+		return fmt.Errorf("server issued a malformed session ID: %s", err)
+	}
+	sess.id = id
+	fmt.Printf("server issued session ID %s\n", id)
+
+	return nil
+}
+
+// advanceChain extends the session's hash chain with numbers, the numbers of response the client is keeping. When
+// they run to the end of response, which checkLink says, the result is checked against the chain value response
+// carries. That way a corrupted number, or a stream resumed from the wrong place, is caught as soon as it arrives
+// rather than when the checksum does.
+func (sess *session) advanceChain(response *protocol.NumberResponse, numbers []uint64, checkLink bool, opts streamOptions) error {
+	if len(response.Chain) == 0 {
+		return nil
+	}
+	if err := checkAlgorithm(response.ChecksumAlgorithm, opts); err != nil {
+		return err
+	}
+
+	chain, err := checksum.Chain(response.ChecksumAlgorithm, opts.valueType(), sess.chain, numbers)
+	if err != nil {
+		return err
+	}
+	if checkLink && !bytes.Equal(chain, response.Chain) {
+		last := response.Index + distribution.Count(response) - 1
+		return fmt.Errorf("hash chain of index %d is %x, but the server sent %x", last, chain, response.Chain)
+	}
+	sess.chain = chain
+
+	return nil
+}
+
+// savedCheckpoint is a checkpoint the client has verified, along with what it needs to resume the stream from it.
+type savedCheckpoint struct {
+	index     uint32
+	checksum  string
+	hashState []byte
+	chain     []byte
+}
+
+// add extends the session's running checksum with numbers, the numbers of response the client is keeping, and moves
+// the session on past them. Unless opts.keepNumbers they are checked against the range of the distribution here, as
+// they can't be once the stream ends. Whether a sample repeats a value is then left unchecked.
+func (sess *session) add(response *protocol.NumberResponse, numbers []uint64, opts streamOptions) error {
+	if !opts.keepNumbers() {
+		if err := distribution.Verify(opts.distribution, numbers); err != nil {
+			return fmt.Errorf("in the message at index %d: %w", response.Index, err)
+		}
+	}
+	if sess.hash == nil {
+		if err := checkAlgorithm(response.ChecksumAlgorithm, opts); err != nil {
+			return err
+		}
+		h, err := checksum.New(response.ChecksumAlgorithm, opts.valueType())
+		if err != nil {
+			return err
+		}
+		sess.hash = h
+	}
+	for _, n := range numbers {
+		sess.hash.Add(n)
+	}
+	sess.last += uint32(len(numbers))
+
+	return nil
+}
+
+// checkCheckpoint checks the checkpoint response carries, if any, against the session's running checksum once the
+// numbers of response the client is keeping run to its end, which complete says. The server's signature of it is
+// checked too when the client has the server's key, and the checkpoint is saved.
+func (sess *session) checkCheckpoint(response *protocol.NumberResponse, complete bool, opts streamOptions) error {
+	if opts.checkpointMessages == 0 && opts.checkpointInterval == 0 {
+		return nil
+	}
+
+	cp := response.Checkpoint
+	if cp == nil || !complete {
+		return nil
+	}
+	if last := response.Index + distribution.Count(response) - 1; cp.Index != last {
+		return fmt.Errorf("server sent a checkpoint at index %d with the numbers up to index %d", cp.Index, last)
+	}
+	if sum := sess.hash.Sum(); cp.Checksum != sum {
+		return fmt.Errorf("CHECKPOINT MISMATCH: the checksum at index %d is %s, but the server sent %s", cp.Index, sum, cp.Checksum)
+	}
+	if opts.serverKey != nil {
+		statement := signing.Statement{
+			ClientID:       sess.id,
+			SeedCommitment: sess.seedCommitment,
+			Count:          cp.Index,
+			Algorithm:      response.ChecksumAlgorithm,
+			Checksum:       cp.Checksum,
+		}
+		if err := statement.Verify(opts.serverKey, cp.Signature); err != nil {
+			return fmt.Errorf("SIGNATURE MISMATCH: checkpoint at index %d: %s", cp.Index, err)
+		}
+	}
+
+	hashState, err := sess.hash.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("unable to save checksum state: %s", err)
+	}
+	sess.checkpoint = &savedCheckpoint{index: cp.Index, checksum: cp.Checksum, hashState: hashState, chain: sess.chain}
+	fmt.Printf("verified checkpoint at index %d, checksum=%s\n", cp.Index, cp.Checksum)
+
+	return nil
+}
+
+// rewindToCheckpoint moves the session back to its latest checkpoint, so that the stream is resumed from it, and
+// returns the checkpoint's index.
+func (sess *session) rewindToCheckpoint() (uint32, error) {
+	cp := sess.checkpoint
+	if err := sess.hash.UnmarshalBinary(cp.hashState); err != nil {
+		return 0, fmt.Errorf("unable to restore checksum state: %s", err)
+	}
+	sess.chain = cp.chain
+	sess.resumeChecksum = cp.checksum
+	sess.last = cp.index
+
+	return cp.index, nil
+}
+
+// checkChunks collects numbers, the numbers of response the client is keeping starting from index first, into the
+// chunks of the sequence's Merkle tree. Each chunk the client has all the numbers of is checked against the root of
+// the tree when its proof arrives, so the numbers received on every connection are checked even if the stream was
+// resumed part way through a chunk. Merkle proofs are only sent for raw numbers, so every number fits in a uint32.
+func (sess *session) checkChunks(response *protocol.NumberResponse, first uint32, numbers []uint64, total uint32, opts streamOptions) error {
+	if opts.merkleChunkSize == 0 {
+		return nil
+	}
+	if len(response.MerkleRoot) > 0 {
+		if sess.merkleRoot != nil && !bytes.Equal(sess.merkleRoot, response.MerkleRoot) {
+			return fmt.Errorf("server sent Merkle root %x, but the stream's root is %x", response.MerkleRoot, sess.merkleRoot)
+		}
+		sess.merkleRoot = response.MerkleRoot
+	}
+
+	proofs := make(map[uint32]*protocol.ChunkProof)
+	for _, proof := range response.ChunkProofs {
+		proofs[proof.Chunk] = proof
+	}
+
+	for i, n := range numbers {
+		index := first + uint32(i)
+		chunk := merkle.ChunkOf(index, opts.merkleChunkSize)
+		chunkFirst, chunkLast := merkle.ChunkBounds(total, opts.merkleChunkSize, chunk)
+		if index == chunkFirst || sess.chunkFirst+uint32(len(sess.chunk)) != index {
+			sess.chunk, sess.chunkFirst = nil, index
+		}
+		sess.chunk = append(sess.chunk, uint32(n))
+
+		// A chunk the client didn't receive from its start can't be checked.
+		p, ok := proofs[chunk]
+		if index != chunkLast || sess.chunkFirst != chunkFirst || !ok {
+			continue
+		}
+		if sess.merkleRoot == nil {
+			return fmt.Errorf("server sent the proof of chunk %d before the Merkle root", chunk)
+		}
+
+		proof := merkle.Proof{Chunk: p.Chunk, Root: p.Root, Path: p.Path}
+		if err := merkle.VerifyChunk(sess.merkleRoot, total, opts.merkleChunkSize, proof, sess.chunk); err != nil {
+			return err
+		}
+		fmt.Printf("verified chunk %d (indexes %d to %d)\n", chunk, chunkFirst, chunkLast)
+		sess.proofs = append(sess.proofs, proof)
+	}
+
+	return nil
+}
+
+// writeSegment saves numbers, the whole sequence, to opts.segmentFile along with the Merkle root and the proofs the
+// server sent, so that it can be verified offline.
+func writeSegment(sess *session, total uint32, numbers []uint64, opts streamOptions) error {
+	if opts.segmentFile == "" {
+		return nil
+	}
+
+	raw := make([]uint32, len(numbers))
+	for i, n := range numbers {
+		raw[i] = uint32(n)
+	}
+
+	data, err := json.MarshalIndent(&merkle.Segment{
+		Root:       sess.merkleRoot,
+		Total:      total,
+		ChunkSize:  opts.merkleChunkSize,
+		FirstIndex: 1,
+		Numbers:    raw,
+		Proofs:     sess.proofs,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode segment: %s", err)
+	}
+
+	return os.WriteFile(opts.segmentFile, data, 0644)
+}
+
+// verifySignature checks the server's signature of the final checksum with opts.serverKey, which proves that the
+// server sent the session's total numbers with checksum sum.
+func verifySignature(sess *session, total uint32, sum string, opts streamOptions) error {
+	if opts.serverKey == nil {
+		return nil
+	}
+	if len(sess.signature) == 0 {
+		return fmt.Errorf("SIGNATURE MISSING: the server didn't sign the final checksum, so the sequence can't be attributed to it")
+	}
+
+	statement := signing.Statement{
+		ClientID:       sess.id,
+		SeedCommitment: sess.seedCommitment,
+		Count:          total,
+		Algorithm:      sess.algorithm,
+		Checksum:       sum,
+	}
+	if err := statement.Verify(opts.serverKey, sess.signature); err != nil {
+		return fmt.Errorf("SIGNATURE MISMATCH: %s", err)
+	}
+	fmt.Printf("verified the server's signature %x of the final checksum\n", sess.signature)
+
+	return nil
+}
+
+// verifySeed checks the seed the server revealed against the commitment it sent before the first number, and
+// replays numbers, the whole sequence, from it. A server that picked the seed always reveals it, so when the client
+// contributed to the seed and the server revealed nothing, the client's contribution can't be shown to count.
+func verifySeed(sess *session, numbers []uint64, opts streamOptions) error {
+	if len(sess.serverSeed) == 0 {
+		if len(opts.clientSeed) > 0 {
+			return fmt.Errorf("SEED NOT REVEALED: the server didn't reveal its seed, so the sequence can't be replayed")
+		}
+
+		return nil
+	}
+
+	if err := fairness.Verify(sess.seedCommitment, sess.serverSeed, sess.seedNonce, opts.clientSeed, sess.prngAlgorithm, opts.distribution, numbers); err != nil {
+		return fmt.Errorf("SEED MISMATCH: %s", err)
+	}
+	if !opts.keepNumbers() {
+		fmt.Printf("revealed server seed %x matches commitment %x, the numbers weren't kept to be replayed from it\n", sess.serverSeed, sess.seedCommitment)
+		return nil
+	}
+	fmt.Printf("revealed server seed %x matches commitment %x, replayed %d numbers from it\n", sess.serverSeed, sess.seedCommitment, len(numbers))
+
+	return nil
+}
+
+// printServerKey prints the public key the server at serverAddress signs its final checksums with.
+func printServerKey(serverAddress string) error {
+	conn, client, err := getClient(serverAddress)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	response, err := client.GetPublicKey(context.Background(), &protocol.PublicKeyRequest{})
+	if err != nil {
+		return fmt.Errorf("unable to get the server's public key: %w", err)
+	}
+	fmt.Printf("%x\n", response.Ed25519PublicKey)
+
+	return nil
+}
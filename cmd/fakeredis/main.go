@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jamesrobb/ably-takehome/storagetest"
+)
+
+// systemClock expires keys by the time of day.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// main serves the fake Redis protocol server the storage tests use, so that servers run by the test scripts can use
+// the redis storage without Redis installed.
+func main() {
+	addr := flag.String("addr", "localhost:6379", "address to serve the Redis protocol on")
+	flag.Parse()
+
+	if _, err := storagetest.StartFakeRedis(*addr, systemClock{}); err != nil {
+		fmt.Printf("unable to start fake Redis server: %s\n", err)
+		os.Exit(1)
+	}
+
+	select {}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jamesrobb/ably-takehome/merkle"
+)
+
+func main() {
+	segmentFile := flag.String("segment", "", "segment file saved by the client with -segmentFile")
+	from := flag.Uint("from", 0, "index of the first number to verify, 0 starts from the first number in the segment")
+	to := flag.Uint("to", 0, "index of the last number to verify, 0 ends at the last number in the segment")
+	flag.Parse()
+
+	if err := verify(*segmentFile, uint32(*from), uint32(*to)); err != nil {
+		fmt.Printf("FAILURE: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// verify checks the numbers at indexes from to to in the segment saved in path against the segment's Merkle root,
+// using only those numbers and the proofs of the chunks they cover.
+func verify(path string, from uint32, to uint32) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read segment: %s", err)
+	}
+	segment := &merkle.Segment{}
+	if err := json.Unmarshal(data, segment); err != nil {
+		return fmt.Errorf("unable to decode segment: %s", err)
+	}
+	if len(segment.Numbers) == 0 {
+		return fmt.Errorf("segment has no numbers")
+	}
+
+	if from == 0 {
+		from = segment.FirstIndex
+	}
+	if to == 0 {
+		to = segment.FirstIndex + uint32(len(segment.Numbers)) - 1
+	}
+	segment, err = segment.Slice(from, to)
+	if err != nil {
+		return err
+	}
+
+	verified, err := segment.Verify()
+	if err != nil {
+		return err
+	}
+
+	// Numbers at either end that only make up part of a chunk can't be checked without the rest of the chunk.
+	next := from
+	for _, chunk := range verified {
+		first, last := merkle.ChunkBounds(segment.Total, segment.ChunkSize, chunk)
+		if first > next {
+			fmt.Printf("indexes %d to %d are not covered by a verified chunk\n", next, first-1)
+		}
+		fmt.Printf("verified chunk %d (indexes %d to %d)\n", chunk, first, last)
+		next = last + 1
+	}
+	if next <= to {
+		fmt.Printf("indexes %d to %d are not covered by a verified chunk\n", next, to)
+	}
+
+	if len(verified) == 0 {
+		return fmt.Errorf("no chunk between indexes %d and %d could be verified", from, to)
+	}
+	fmt.Printf("SUCCESS: %d chunks verified against root %x\n", len(verified), segment.Root)
+
+	return nil
+}
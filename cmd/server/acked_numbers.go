@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// GetAckedNumbers streams numbers like GetNumbers, but a client's progress is only persisted once the
+// client acknowledges it. A client that resumes is sent everything after its last acknowledged number.
+func (ns *numberServer) GetAckedNumbers(stream protocol.Numbers_GetAckedNumbersServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	request := first.GetRequest()
+	if request == nil {
+		return badRequest(codes.InvalidArgument, fieldViolation("request", "first message on the stream must be a NumbersRequest"))
+	}
+
+	clientID, issued, err := ns.sessionID(request)
+	if err != nil {
+		return err
+	}
+
+	// The source of a resumed stream is checked once its state is loaded, as it may not be what the request asks for.
+	if name, source, err := requestedSource(request); err == nil {
+		if err := checkAckable(sourceField(request), name, source.replayable); err != nil {
+			return err
+		}
+	}
+	e, err := ns.emissionSettings(request)
+	if err != nil {
+		return err
+	}
+
+	done, err := ns.admitStream()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	leaseCtx, le, err := ns.leases.acquire(stream.Context(), clientID)
+	if err != nil {
+		return err
+	}
+	defer ns.leases.release(clientID, le)
+
+	s, err := ns.loadState(leaseCtx, clientID, request, true)
+	if err != nil {
+		return err
+	}
+	// A resumed stream keeps its source whatever the request asks for.
+	if err := checkAckable(sourceField(request), s.sourceName, s.source.Replayable()); err != nil {
+		return err
+	}
+
+	responses, err := ns.responseStream(stream, clientID, issued, s, e)
+	if err != nil {
+		return err
+	}
+
+	// s is the sending position and runs ahead of the acknowledged position, which is tracked
+	// (and persisted) separately. Storing the acknowledged position straight away means a client
+	// that processed numbers from this stream can always resume it, even if every ack was lost.
+	// It also claims the state for this stream's epoch.
+	acked, err := s.clone()
+	if err != nil {
+		return status.Errorf(codes.Internal, "unable to copy the stream's state: %s", err)
+	}
+	tracker := newAckTracker(acked)
+	if err := ns.stateStorage.SetState(leaseCtx, clientID, tracker.acked); err != nil {
+		return ns.leases.streamError(le, storageStatus(err))
+	}
+
+	ctx, cancel := context.WithCancel(leaseCtx)
+	defer cancel()
+
+	go func() {
+		ns.receiveAcks(ctx, stream, clientID, tracker)
+		cancel()
+	}()
+
+	err = ns.sendNumbers(ctx, responses, s, e, streamHooks{
+		ready: func(ctx context.Context) error {
+			return tracker.waitForWindow(ctx, ns.config.ackWindow)
+		},
+		sent: func(index uint32, isLast bool) error {
+			tracker.markSent(index, isLast, s.checkpoints)
+			if !isLast {
+				return nil
+			}
+
+			// The stream is only finished once the client has acknowledged the last number.
+			if err := tracker.waitForAck(ctx, index); err != nil {
+				return err
+			}
+
+			if err := ns.stateStorage.DeleteState(ctx, clientID, s.epoch); err != nil {
+				return storageStatus(err)
+			}
+
+			return nil
+		},
+	})
+
+	// An invalid ack takes precedence over the cancellation it caused.
+	if ackErr := tracker.error(); ackErr != nil {
+		return ackErr
+	}
+
+	return ns.leases.streamError(le, err)
+}
+
+// receiveAcks applies the acks read off stream until the stream ends or an invalid message is received.
+func (ns *numberServer) receiveAcks(ctx context.Context, stream protocol.Numbers_GetAckedNumbersServer, clientID uuid.UUID, tracker *ackTracker) {
+	for {
+		message, err := stream.Recv()
+		if err != nil {
+			// Either the client closed its side of the stream or the stream is gone. In both cases
+			// no more acks will arrive.
+			return
+		}
+
+		ack := message.GetAck()
+		if ack == nil {
+			tracker.fail(badRequest(codes.InvalidArgument, fieldViolation("ack", "expected an Ack message")))
+			return
+		}
+
+		acked, err := tracker.ack(ack.Index)
+		if err != nil {
+			tracker.fail(err)
+			return
+		}
+		// A lost ack only means the client is sent some numbers again when it resumes, but once a newer
+		// stream has claimed the client this one has to stop.
+		if acked != nil {
+			err := ns.stateStorage.SetState(ctx, clientID, acked)
+			if errors.Is(err, ErrFenced) {
+				tracker.fail(storageStatus(err))
+				return
+			} else if err != nil {
+				fmt.Printf("unable to store ack for clientID=%s: %s\n", clientID, err)
+			}
+		}
+	}
+}
+
+// ackTracker follows how far a client has acknowledged a stream. It is shared between the goroutine
+// reading acks and the goroutine sending numbers.
+type ackTracker struct {
+	lock sync.Mutex
+
+	// acked is positioned after the last acknowledged number, it is the state that gets persisted.
+	acked *State
+	// complete is set once the last number of the sequence has been acknowledged.
+	complete bool
+	// sent is the index of the last number handed to the stream.
+	sent uint32
+	// last is the index of the sequence's last number, which for a stream bounded by its duration is only known once
+	// it has been sent. It is the state's totalNumbers until then.
+	last uint32
+	err  error
+	// checkpoints are the checkpoints sent so far, which the acknowledged state records once they are acknowledged.
+	checkpoints []issuedCheckpoint
+
+	// changed is signalled whenever the acknowledged position moves or the tracker fails.
+	changed chan struct{}
+}
+
+func newAckTracker(acked *State) *ackTracker {
+	return &ackTracker{
+		acked:       acked,
+		sent:        acked.numbersSent,
+		last:        acked.totalNumbers,
+		checkpoints: acked.checkpoints,
+		changed:     make(chan struct{}, 1),
+	}
+}
+
+// ackedIndex returns the index of the last acknowledged number. Assumes the caller holds t.lock.
+func (t *ackTracker) ackedIndex() uint32 {
+	if t.complete {
+		return t.last
+	}
+
+	return t.acked.numbersSent
+}
+
+// ack acknowledges every number up to and including index. It returns the state to persist, or nil
+// if the ack does not move the acknowledged position forward (acks are cumulative, so an old or
+// duplicated ack is harmless) or completes the sequence. The sending goroutine deletes the state of a
+// completed sequence as soon as it is woken, so persisting it could write it back once deleted.
+func (t *ackTracker) ack(index uint32) (*State, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if index > t.sent {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("ack.index",
+			fmt.Sprintf("cannot acknowledge index %d, only %d numbers have been sent", index, t.sent)))
+	}
+	if index <= t.ackedIndex() {
+		return nil, nil
+	}
+
+	for t.acked.numbersSent < index && t.acked.numbersSent+1 < t.last {
+		t.acked.advance()
+	}
+	t.acked.checkpoints = checkpointsUpTo(t.checkpoints, t.acked.numbersSent)
+	t.notify()
+	if index == t.last {
+		t.complete = true
+		return nil, nil
+	}
+
+	return t.acked, nil
+}
+
+func (t *ackTracker) markSent(index uint32, isLast bool, checkpoints []issuedCheckpoint) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.sent = index
+	t.checkpoints = checkpoints
+	if isLast {
+		t.last = index
+	}
+}
+
+func (t *ackTracker) fail(err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.err = err
+	t.notify()
+}
+
+func (t *ackTracker) error() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.err
+}
+
+// notify wakes up a waiter without blocking. Assumes the caller holds t.lock.
+func (t *ackTracker) notify() {
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+// waitForWindow blocks until fewer than window numbers are awaiting acknowledgement.
+func (t *ackTracker) waitForWindow(ctx context.Context, window uint32) error {
+	return t.waitUntil(ctx, func() bool {
+		return t.sent-t.ackedIndex() < window
+	})
+}
+
+// waitForAck blocks until the number at index has been acknowledged.
+func (t *ackTracker) waitForAck(ctx context.Context, index uint32) error {
+	return t.waitUntil(ctx, func() bool {
+		return t.ackedIndex() >= index
+	})
+}
+
+func (t *ackTracker) waitUntil(ctx context.Context, done func() bool) error {
+	for {
+		t.lock.Lock()
+		ok, err := done(), t.err
+		t.lock.Unlock()
+
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.changed:
+		}
+	}
+}
+
+// checkAckable returns an error unless the numbers of the source registered as name, which replayable says can be
+// generated again, can be acknowledged. Unacknowledged numbers are generated again when the client resumes, which
+// sources like CRYPTO_RAND can't do. field is the field of the request that names the source.
+func checkAckable(field string, name string, replayable bool) error {
+	if !replayable {
+		return badRequest(codes.InvalidArgument, fieldViolation(field,
+			fmt.Sprintf("%s numbers can't be generated again, so they can't be acknowledged", name)))
+	}
+
+	return nil
+}
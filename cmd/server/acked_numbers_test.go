@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// ackingStream is a GetAckedNumbers stream whose client acknowledges every NumberResponse as it is sent, and
+// closes its side of the stream once it has acknowledged the last one.
+type ackingStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	last     uint32
+	messages chan *protocol.AckedNumbersRequest
+	// drained is closed once the server has read every message, so it has handled the last ack.
+	drained chan struct{}
+}
+
+func (a *ackingStream) Context() context.Context {
+	return a.ctx
+}
+
+func (a *ackingStream) Send(response *protocol.NumberResponse) error {
+	// Numbers are sent one per NumberResponse.
+	index := response.Index
+	a.messages <- &protocol.AckedNumbersRequest{Message: &protocol.AckedNumbersRequest_Ack{Ack: &protocol.Ack{Index: index}}}
+	if index == a.last {
+		close(a.messages)
+	}
+
+	return nil
+}
+
+func (a *ackingStream) Recv() (*protocol.AckedNumbersRequest, error) {
+	message, ok := <-a.messages
+	if !ok {
+		close(a.drained)
+		return nil, io.EOF
+	}
+
+	return message, nil
+}
+
+// slowStorage is a StateStorage whose writes take delay, so that a write racing a delete lands after it.
+type slowStorage struct {
+	StateStorage
+	delay time.Duration
+}
+
+func (s slowStorage) SetState(ctx context.Context, clientID uuid.UUID, state *State) error {
+	time.Sleep(s.delay)
+
+	return s.StateStorage.SetState(ctx, clientID, state)
+}
+
+// TestAckedNumbersDeletesFinishedState checks that once the last number of a GetAckedNumbers stream is
+// acknowledged its state is deleted, and not written back by the ack.
+func TestAckedNumbersDeletesFinishedState(t *testing.T) {
+	storage := NewInMemoryStorage(InMemoryStorageConfig{Shards: 1, TombstoneTTL: time.Hour, ExpiryInterval: time.Hour})
+	defer storage.Close()
+	ns := newNumberServer(slowStorage{storage, 10 * time.Millisecond}, numberServerConfig{
+		minInterval:  time.Millisecond,
+		maxInterval:  time.Second,
+		maxBatchSize: 1,
+		ackWindow:    16,
+		leasePolicy:  LEASE_TAKEOVER,
+	})
+
+	clientID := uuid.New()
+	stream := &ackingStream{
+		ctx:      context.Background(),
+		last:     5,
+		messages: make(chan *protocol.AckedNumbersRequest, 8),
+		drained:  make(chan struct{}),
+	}
+	stream.messages <- &protocol.AckedNumbersRequest{Message: &protocol.AckedNumbersRequest_Request{Request: &protocol.NumbersRequest{
+		ClientId:   clientID[:],
+		NumNumbers: 5,
+		Seed:       1,
+		IntervalMs: 1,
+	}}}
+
+	if err := ns.GetAckedNumbers(stream); err != nil {
+		t.Fatalf("GetAckedNumbers: %s", err)
+	}
+	// The last ack has been handled, and any write it made has landed, once the server reads on.
+	<-stream.drained
+
+	if _, err := storage.GetState(context.Background(), clientID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetState after the last ack = %v, want ErrNotFound", err)
+	}
+}
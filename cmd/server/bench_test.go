@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// BENCH_SHARDS is the number of shards the benchmarked memory storages have, the server's default.
+const BENCH_SHARDS int = 64
+
+// BENCH_INTERVAL is the delay between numbers of the streams BenchmarkIdleStreams holds, long enough that none of
+// them sends while it is measured.
+const BENCH_INTERVAL time.Duration = 30 * time.Second
+
+// discardStream is a numberStream that throws away everything sent on it.
+type discardStream struct{}
+
+func (discardStream) Send(*protocol.NumberResponse) error {
+	return nil
+}
+
+// BenchmarkIdleStreams measures what it costs to hold b.N open but idle streams. Each stream runs the same
+// sendNumbers loop as a GetNumbers call against a stream that discards what it is sent. Once every stream is
+// waiting on the scheduler the goroutines and memory used per stream are reported, then all the streams are
+// cancelled, which is what the benchmark times.
+func BenchmarkIdleStreams(b *testing.B) {
+	var before, during runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	goroutinesBefore := runtime.NumGoroutine()
+
+	ims := NewInMemoryStorage(InMemoryStorageConfig{
+		Shards:         1,
+		ExpiryInterval: time.Hour,
+	})
+	defer ims.Close()
+	ns := newNumberServer(ims, numberServerConfig{})
+	e := emission{
+		interval:  BENCH_INTERVAL,
+		batchSize: 1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		go func(seed uint32) {
+			defer wg.Done()
+
+			s := newState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(seed), nil, DEFAULT_MAX_NUMBERS, protocol.ChecksumAlgorithm_MD5_LEGACY)
+			ns.sendNumbers(ctx, discardStream{}, s, e, streamHooks{})
+		}(uint32(i + 1))
+	}
+
+	// Wait for every stream to be parked on the scheduler.
+	for {
+		ns.scheduler.lock.Lock()
+		waiting := len(ns.scheduler.waiters)
+		ns.scheduler.lock.Unlock()
+
+		if waiting == b.N {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&during)
+	goroutines := runtime.NumGoroutine() - goroutinesBefore
+
+	b.ResetTimer()
+	cancel()
+	wg.Wait()
+	b.StopTimer()
+
+	// ResetTimer drops metrics reported before it.
+	b.ReportMetric(float64(goroutines)/float64(b.N), "goroutines/stream")
+	b.ReportMetric(float64(during.HeapInuse-before.HeapInuse)/float64(b.N), "heap-B/stream")
+	b.ReportMetric(float64(during.StackInuse-before.StackInuse)/float64(b.N), "stack-B/stream")
+}
+
+// BenchmarkMemoryStorage measures the throughput of each operation of the memory storage, run from every CPU at
+// once, and the memory a session takes.
+func BenchmarkMemoryStorage(b *testing.B) {
+	s := newState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(1), nil, DEFAULT_MAX_NUMBERS, protocol.ChecksumAlgorithm_MD5_LEGACY)
+	ctx := context.Background()
+
+	b.Run("SetState", func(b *testing.B) {
+		var before, during runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		ims := NewInMemoryStorage(InMemoryStorageConfig{Shards: BENCH_SHARDS, ExpiryInterval: time.Hour})
+		defer ims.Close()
+
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				ims.SetState(ctx, uuid.New(), s)
+			}
+		})
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&during)
+		b.ReportMetric(float64(during.HeapInuse-before.HeapInuse)/float64(b.N), "heap-B/session")
+	})
+
+	b.Run("GetState", func(b *testing.B) {
+		ims := NewInMemoryStorage(InMemoryStorageConfig{Shards: BENCH_SHARDS, ExpiryInterval: time.Hour})
+		defer ims.Close()
+		clientIDs := fillMemoryStorage(ims, b.N, s)
+
+		b.ResetTimer()
+		var next uint64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				ims.GetState(ctx, clientIDs[atomic.AddUint64(&next, 1)-1])
+			}
+		})
+	})
+}
+
+// BenchmarkMemoryStorageExpiry measures how long expiring b.N sessions, and then their tombstones, takes. Expiry
+// is driven by hand rather than by the background goroutine, shard by shard as expire does, and the longest any
+// batch holds a shard's lock, which is the longest a request could be held up by expiry, is reported.
+func BenchmarkMemoryStorageExpiry(b *testing.B) {
+	for _, phase := range []struct {
+		name  string
+		after time.Duration
+	}{
+		{"sessions", GARBGAGE_TIMEOUT},
+		{"tombstones", GARBGAGE_TIMEOUT + time.Minute},
+	} {
+		b.Run(phase.name, func(b *testing.B) {
+			s := newState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(1), nil, DEFAULT_MAX_NUMBERS, protocol.ChecksumAlgorithm_MD5_LEGACY)
+			ims := NewInMemoryStorage(InMemoryStorageConfig{
+				Shards:         BENCH_SHARDS,
+				TombstoneTTL:   time.Minute,
+				ExpiryInterval: time.Hour,
+			})
+			defer ims.Close()
+			fillMemoryStorage(ims, b.N, s)
+			if phase.name == "tombstones" {
+				expireMemoryStorage(ims, s.lastUpdated.Add(GARBGAGE_TIMEOUT))
+			}
+
+			b.ResetTimer()
+			longestHold := expireMemoryStorage(ims, s.lastUpdated.Add(phase.after))
+			b.StopTimer()
+			b.ReportMetric(float64(longestHold.Nanoseconds()), "max-lock-ns")
+		})
+	}
+}
+
+// fillMemoryStorage stores s for numSessions new client IDs in ims, and returns the IDs.
+func fillMemoryStorage(ims *InMemoryStorage, numSessions int, s *State) []uuid.UUID {
+	clientIDs := make([]uuid.UUID, numSessions)
+	for i := range clientIDs {
+		clientIDs[i] = uuid.New()
+		ims.SetState(context.Background(), clientIDs[i], s)
+	}
+
+	return clientIDs
+}
+
+// expireMemoryStorage expires everything in ims that has expired by now, and returns the longest a batch held a
+// shard's lock.
+func expireMemoryStorage(ims *InMemoryStorage, now time.Time) time.Duration {
+	var longestHold time.Duration
+	for _, shard := range ims.shards {
+		for {
+			batchStarted := time.Now()
+			states, tombstones := shard.expireBatch(now, ims.config.TombstoneTTL)
+			if hold := time.Since(batchStarted); hold > longestHold {
+				longestHold = hold
+			}
+
+			if states < MEMORY_EXPIRY_BATCH && tombstones < MEMORY_EXPIRY_BATCH {
+				break
+			}
+		}
+	}
+
+	return longestHold
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// checkpointer decides which NumberResponses of a stream carry a checkpoint: every checkpointMessages-th one, and
+// the first one once checkpointInterval has passed since the last checkpoint.
+type checkpointer struct {
+	messages uint32
+	interval time.Duration
+	// sent counts the NumberResponses of the stream so far, and last is when the last checkpoint was due.
+	sent uint32
+	last time.Time
+}
+
+func newCheckpointer(e emission) *checkpointer {
+	return &checkpointer{
+		messages: e.checkpointMessages,
+		interval: e.checkpointInterval,
+		last:     time.Now(),
+	}
+}
+
+// due counts another NumberResponse sent at now, and reports whether it is due a checkpoint.
+func (c *checkpointer) due(now time.Time) bool {
+	c.sent++
+	if (c.messages > 0 && c.sent%c.messages == 0) || (c.interval > 0 && now.Sub(c.last) >= c.interval) {
+		c.last = now
+		return true
+	}
+
+	return false
+}
+
+// checkCheckpoint returns an error unless request, which resumes the sequence in s from request.LastIndex, names no
+// checkpoint or one of the checkpoints s records being sent at that index. The checksum is compared with the one
+// that was sent rather than worked out again, so a checkpoint older than the ones s records can't be checked, and
+// the request fails with codes.FailedPrecondition rather than codes.InvalidArgument.
+func checkCheckpoint(s *State, request *protocol.NumbersRequest) error {
+	if request.CheckpointChecksum == "" {
+		return nil
+	}
+	if request.LastIndex == 0 {
+		return badRequest(codes.InvalidArgument, fieldViolation("checkpoint_checksum", "requires last_index, the index of the checkpoint"))
+	}
+
+	for _, sent := range s.checkpoints {
+		if sent.index != request.LastIndex {
+			continue
+		}
+		if sent.checksum != request.CheckpointChecksum {
+			return badRequest(codes.InvalidArgument, fieldViolation("checkpoint_checksum",
+				fmt.Sprintf("is %s, but the checkpoint sent at index %d was %s", request.CheckpointChecksum, request.LastIndex, sent.checksum)))
+		}
+		return nil
+	}
+
+	if len(s.checkpoints) == 0 || request.LastIndex < s.checkpoints[0].index {
+		return status.Errorf(codes.FailedPrecondition,
+			"cannot resume from a checkpoint at index %d, only the last %d checkpoints sent on a stream are kept (the last one, for a stream resumed from a resume token), resume from it without checkpoint_checksum instead",
+			request.LastIndex, CHECKPOINTS_KEPT)
+	}
+
+	return badRequest(codes.InvalidArgument, fieldViolation("checkpoint_checksum",
+		fmt.Sprintf("no checkpoint was sent at index %d", request.LastIndex)))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// TestCheckCheckpoint checks that a stream can only be resumed from one of the last checkpoints it was sent, with the
+// checksum it was sent with, including once it has been rewound to that checkpoint.
+func TestCheckCheckpoint(t *testing.T) {
+	s := newState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(1), nil, 100, protocol.ChecksumAlgorithm_SHA256)
+	sums := make(map[uint32]string)
+	for s.numbersSent < 50 {
+		s.advance()
+		if s.numbersSent%10 == 0 {
+			// The hash includes the value after the last one sent, so it is the checksum at numbersSent+1.
+			sums[s.numbersSent+1] = s.hash.Sum()
+			s.recordCheckpoint(s.numbersSent+1, s.hash.Sum())
+		}
+	}
+
+	for _, test := range []struct {
+		name     string
+		index    uint32
+		checksum string
+		code     codes.Code
+	}{
+		{"last checkpoint", 51, sums[51], codes.OK},
+		{"oldest kept checkpoint", 21, sums[21], codes.OK},
+		{"forgotten checkpoint", 11, sums[11], codes.FailedPrecondition},
+		{"wrong checksum", 51, sums[41], codes.InvalidArgument},
+		{"index of no checkpoint", 50, sums[51], codes.InvalidArgument},
+	} {
+		request := &protocol.NumbersRequest{LastIndex: test.index, CheckpointChecksum: test.checksum}
+		if err := checkCheckpoint(s.rewind(test.index), request); status.Code(err) != test.code {
+			t.Errorf("%s: checkCheckpoint = %v, want %s", test.name, err, test.code)
+		}
+	}
+}
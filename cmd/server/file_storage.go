@@ -0,0 +1,601 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fsyncPolicy controls when FileStorage flushes its write-ahead log to disk.
+type fsyncPolicy string
+
+const (
+	// FSYNC_ALWAYS syncs after every record, so an acknowledged write survives a power failure.
+	FSYNC_ALWAYS fsyncPolicy = "always"
+	// FSYNC_INTERVAL syncs in the background, so up to one interval of writes can be lost on power failure.
+	FSYNC_INTERVAL fsyncPolicy = "interval"
+	// FSYNC_NEVER leaves syncing to the operating system. Writes survive the server crashing, but not the host.
+	FSYNC_NEVER fsyncPolicy = "never"
+)
+
+func parseFsyncPolicy(policy string) (fsyncPolicy, error) {
+	switch p := fsyncPolicy(policy); p {
+	case FSYNC_ALWAYS, FSYNC_INTERVAL, FSYNC_NEVER:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("unknown fsync policy %q, expected one of always, interval or never", policy)
+}
+
+// Write-ahead log record types. The snapshot file uses the same record format.
+const (
+	walSetState    byte = 1
+	walDeleteState byte = 2
+	walExpireState byte = 3
+)
+
+const (
+	walFileName      = "wal"
+	snapshotFileName = "snapshot"
+)
+
+type FileStorageConfig struct {
+	Dir           string
+	Fsync         fsyncPolicy
+	FsyncInterval time.Duration
+	// SnapshotEvery is the number of log records after which the log is compacted into a snapshot.
+	SnapshotEvery int
+	// TombstoneTTL is how long an expired client ID is remembered once its state has expired.
+	TombstoneTTL time.Duration
+	// ExpiryInterval is how often the background goroutine removes expired states and tombstones.
+	ExpiryInterval time.Duration
+	// Clock defaults to the system clock.
+	Clock Clock
+}
+
+// FileStorage is a StateStorage that survives restarts. Every change is appended to a write-ahead log before it
+// is applied, and the log is periodically compacted into a snapshot of all the stored states. On startup the
+// snapshot is loaded and the log replayed on top of it. A record is framed with its length and a CRC, so a record
+// torn by a crash part way through a write is detected and discarded. Only the last record of the log can be torn,
+// so a record that is damaged anywhere else fails recovery rather than losing the records after it.
+//
+// As in InMemoryStorage, states are kept in a min-heap ordered by when they expire, and a background goroutine pops
+// the expired ones every ExpiryInterval and replaces them with tombstones, which live for TombstoneTTL. Tombstones
+// are logged with when they expire, so expired client IDs stay expired across restarts. Lookups check expiry times
+// themselves, so they don't depend on when the background goroutine last ran.
+type FileStorage struct {
+	config FileStorageConfig
+
+	lock   sync.Mutex
+	states map[uuid.UUID]*fileEntry
+	expiry expiryHeap
+	// tombstones maps an expired client ID to its tombstone, which is also in tombstoneExpiry.
+	tombstones      map[uuid.UUID]*expiryItem
+	tombstoneExpiry expiryHeap
+	wal             *os.File
+	walWriter       *bufio.Writer
+	walRecords      int
+	dirty           bool
+	closed          chan struct{}
+}
+
+// fileEntry is a stored state in its encoded form. When it expires is kept alongside it, and its epoch for fencing.
+type fileEntry struct {
+	expiryItem
+	epoch uint64
+	data  []byte
+}
+
+// NewFileStorage opens (or creates) the storage in config.Dir, recovering whatever was stored before.
+func NewFileStorage(config FileStorageConfig) (*FileStorage, error) {
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	err := os.MkdirAll(config.Dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create storage directory: %s", err)
+	}
+
+	fs := &FileStorage{
+		config:     config,
+		states:     make(map[uuid.UUID]*fileEntry),
+		tombstones: make(map[uuid.UUID]*expiryItem),
+		closed:     make(chan struct{}),
+	}
+
+	err = fs.recover()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Fsync == FSYNC_INTERVAL {
+		go fs.syncPeriodically()
+	}
+	go fs.expirePeriodically()
+
+	return fs, nil
+}
+
+// recover loads the snapshot and replays the log. The log is truncated if its last record is torn, which a crash
+// part way through a write leaves behind. Any other damaged record is an error.
+func (fs *FileStorage) recover() error {
+	snapshot, err := os.Open(filepath.Join(fs.config.Dir, snapshotFileName))
+	if err == nil {
+		_, err = fs.replay(snapshot)
+		snapshot.Close()
+		if err != nil {
+			return fmt.Errorf("unable to load snapshot: %s", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to open snapshot: %s", err)
+	}
+
+	// Replaying the whole log on top of the snapshot is safe even if a crash left records in the log that are
+	// already in the snapshot. The last record for a client ID always determines its state, and the snapshot
+	// holds exactly that state.
+	fs.wal, err = os.OpenFile(filepath.Join(fs.config.Dir, walFileName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open write-ahead log: %s", err)
+	}
+	goodLength, err := fs.replay(fs.wal)
+	if errors.Is(err, errTornRecord) {
+		fmt.Printf("discarding torn write-ahead log record at offset %d: %s\n", goodLength, err)
+	} else if err != nil {
+		fs.wal.Close()
+		return fmt.Errorf("write-ahead log is damaged at offset %d, before its last record, so the records after it can't be recovered: %s", goodLength, err)
+	}
+	err = fs.wal.Truncate(goodLength)
+	if err != nil {
+		return fmt.Errorf("unable to truncate write-ahead log: %s", err)
+	}
+	_, err = fs.wal.Seek(goodLength, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("unable to seek write-ahead log: %s", err)
+	}
+	fs.walWriter = bufio.NewWriter(fs.wal)
+
+	fmt.Printf("recovered %d states and %d expired client IDs from %s\n", len(fs.states), len(fs.tombstones), fs.config.Dir)
+
+	return nil
+}
+
+// replay applies every record read from r, returning the length of the records read. Only a record that can't be
+// read, because it is torn or corrupt, is an error, as the records after it can't be found, and a torn one is
+// reported as errTornRecord. A record that is intact but can't be applied, such as a state written by a newer
+// server, is skipped, and so is anything stored for its client ID before it, as the record replaced it.
+func (fs *FileStorage) replay(r io.Reader) (int64, error) {
+	reader := bufio.NewReader(r)
+	var read int64
+
+	for {
+		op, clientID, payload, length, err := readRecord(reader)
+		if err == io.EOF {
+			return read, nil
+		} else if err != nil {
+			return read, err
+		}
+
+		if err := fs.apply(op, clientID, payload); err != nil {
+			fmt.Printf("skipping record for clientID=%s at offset %d: %s\n", clientID, read, err)
+			fs.removeState(clientID)
+		}
+
+		read += length
+	}
+}
+
+// apply applies a record read by replay.
+func (fs *FileStorage) apply(op byte, clientID uuid.UUID, payload []byte) error {
+	switch op {
+	case walSetState:
+		// Only the header is read, decoding the whole state would rebuild its source for nothing.
+		epoch, lastUpdated, err := decodeStateHeader(payload)
+		if err != nil {
+			return err
+		}
+		fs.putState(clientID, epoch, lastUpdated, payload)
+	case walDeleteState:
+		fs.removeState(clientID)
+	case walExpireState:
+		if len(payload) != 8 {
+			return fmt.Errorf("malformed expiry record")
+		}
+		fs.removeState(clientID)
+		fs.putTombstone(clientID, time.Unix(0, int64(binary.BigEndian.Uint64(payload))))
+	default:
+		return fmt.Errorf("unknown record type %d", op)
+	}
+
+	return nil
+}
+
+// A record is laid out as: payload length uint32 | type uint8 | client ID [16]byte | header CRC-32C uint32 |
+// payload | CRC-32C uint32. The header CRC covers the fields before it, so a corrupt length is caught before it is
+// used to read the payload, and the last CRC covers everything before it.
+const recordHeaderLength = 4 + 1 + 16 + 4
+
+// maxRecordPayload is far larger than any encoded State, even a sample's, which grows with the integers its shuffle
+// has moved. A longer length can only come from a corrupt record.
+const maxRecordPayload = 1 << 30
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errTornRecord is returned by readRecord for a record that is cut short, or whose payload is damaged and runs to
+// the end of the file, which is what a crash part way through appending it leaves behind. A whole header that is
+// damaged isn't torn, as a crash cuts a record short rather than changing what was written of it.
+var errTornRecord = errors.New("torn record")
+
+func appendRecord(buf []byte, op byte, clientID uuid.UUID, payload []byte) []byte {
+	start := len(buf)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = append(buf, op)
+	buf = append(buf, clientID[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(buf[start:], crcTable))
+	buf = append(buf, payload...)
+
+	return binary.BigEndian.AppendUint32(buf, crc32.Checksum(buf[start:], crcTable))
+}
+
+func readRecord(r *bufio.Reader) (byte, uuid.UUID, []byte, int64, error) {
+	var clientID uuid.UUID
+
+	header := make([]byte, recordHeaderLength)
+	n, err := io.ReadFull(r, header)
+	if err == io.EOF {
+		return 0, clientID, nil, 0, io.EOF
+	} else if err != nil {
+		return 0, clientID, nil, 0, fmt.Errorf("%w: truncated record header after %d bytes", errTornRecord, n)
+	}
+
+	if crc32.Checksum(header[:recordHeaderLength-4], crcTable) != binary.BigEndian.Uint32(header[recordHeaderLength-4:]) {
+		return 0, clientID, nil, 0, fmt.Errorf("record header checksum mismatch")
+	}
+	payloadLength := binary.BigEndian.Uint32(header)
+	if payloadLength > maxRecordPayload {
+		return 0, clientID, nil, 0, fmt.Errorf("record length %d is too large", payloadLength)
+	}
+	body := make([]byte, int(payloadLength)+4)
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return 0, clientID, nil, 0, fmt.Errorf("%w: truncated record", errTornRecord)
+	}
+
+	crc := crc32.Update(crc32.Checksum(header, crcTable), crcTable, body[:payloadLength])
+	if crc != binary.BigEndian.Uint32(body[payloadLength:]) {
+		if _, err := r.Peek(1); err == io.EOF {
+			return 0, clientID, nil, 0, fmt.Errorf("%w: record checksum mismatch", errTornRecord)
+		}
+		return 0, clientID, nil, 0, fmt.Errorf("record checksum mismatch")
+	}
+
+	copy(clientID[:], header[5:21])
+
+	return header[4], clientID, body[:payloadLength], int64(recordHeaderLength) + int64(len(body)), nil
+}
+
+// log appends a record to the write-ahead log. Assumes the caller holds fs.lock.
+func (fs *FileStorage) log(op byte, clientID uuid.UUID, payload []byte) error {
+	_, err := fs.walWriter.Write(appendRecord(nil, op, clientID, payload))
+	if err == nil {
+		err = fs.walWriter.Flush()
+	}
+	if err == nil && fs.config.Fsync == FSYNC_ALWAYS {
+		err = fs.wal.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write to write-ahead log: %s", err)
+	}
+
+	fs.dirty = true
+	fs.walRecords++
+
+	return nil
+}
+
+// compactIfDue snapshots the stored states once enough records have been logged. It must only be called once the
+// change that was logged has been applied. Assumes the caller holds fs.lock.
+func (fs *FileStorage) compactIfDue() {
+	if fs.walRecords < fs.config.SnapshotEvery {
+		return
+	}
+
+	if err := fs.snapshot(); err != nil {
+		// The log is still intact, so nothing is lost. Compaction is retried after the next write.
+		fmt.Printf("unable to compact write-ahead log: %s\n", err)
+	}
+}
+
+// snapshot writes every stored state to a new snapshot file, then empties the log. The snapshot is written to a
+// temporary file and renamed into place so that a crash never leaves a partial snapshot behind. Assumes the caller
+// holds fs.lock.
+func (fs *FileStorage) snapshot() error {
+	path := filepath.Join(fs.config.Dir, snapshotFileName)
+	tmpPath := path + ".tmp"
+
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot: %s", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	var buf []byte
+	for clientID, entry := range fs.states {
+		buf = appendRecord(buf[:0], walSetState, clientID, entry.data)
+		w.Write(buf)
+	}
+	for clientID, t := range fs.tombstones {
+		buf = appendRecord(buf[:0], walExpireState, clientID, expiryPayload(t.expires))
+		w.Write(buf)
+	}
+
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err == nil {
+		err = syncDir(fs.config.Dir)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write snapshot: %s", err)
+	}
+
+	// Everything in the log is now in the snapshot.
+	err = fs.wal.Truncate(0)
+	if err == nil {
+		_, err = fs.wal.Seek(0, io.SeekStart)
+	}
+	if err == nil && fs.config.Fsync != FSYNC_NEVER {
+		err = fs.wal.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to reset write-ahead log: %s", err)
+	}
+	fs.walWriter.Reset(fs.wal)
+	fs.walRecords = 0
+
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+func (fs *FileStorage) syncPeriodically() {
+	ticker := time.NewTicker(fs.config.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fs.closed:
+			return
+		case <-ticker.C:
+		}
+
+		fs.lock.Lock()
+		if fs.dirty {
+			if err := fs.wal.Sync(); err != nil {
+				fmt.Printf("unable to sync write-ahead log: %s\n", err)
+			}
+			fs.dirty = false
+		}
+		fs.lock.Unlock()
+	}
+}
+
+// Close stops the background goroutines, then flushes the write-ahead log to disk and closes it.
+func (fs *FileStorage) Close() error {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	close(fs.closed)
+	err := fs.wal.Sync()
+	if closeErr := fs.wal.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (fs *FileStorage) GetState(ctx context.Context, clientID uuid.UUID) (*State, error) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	now := fs.config.Clock.Now()
+
+	entry, ok := fs.states[clientID]
+	if ok && !now.Before(entry.expires) {
+		// The state may have expired since the background goroutine last ran, or its expiry couldn't be logged.
+		return nil, ErrExpired
+	}
+	if !ok {
+		if t, tombstoned := fs.tombstones[clientID]; tombstoned && now.Before(t.expires) {
+			return nil, ErrExpired
+		}
+
+		return nil, ErrNotFound
+	}
+
+	s := &State{}
+	err := s.UnmarshalBinary(entry.data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	return s, nil
+}
+
+func (fs *FileStorage) expirePeriodically() {
+	ticker := time.NewTicker(fs.config.ExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fs.closed:
+			return
+		case <-ticker.C:
+		}
+
+		for more := true; more; {
+			more = fs.expireBatch(fs.config.Clock.Now())
+		}
+	}
+}
+
+// expireBatch replaces up to MEMORY_EXPIRY_BATCH of the states that have expired by now with tombstones, logging
+// each one, and drops up to as many of the tombstones that have expired, so that a large expiry doesn't hold up
+// the requests waiting on fs.lock. It reports whether there may be more to expire.
+func (fs *FileStorage) expireBatch(now time.Time) bool {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	select {
+	case <-fs.closed:
+		// The log is closed.
+		return false
+	default:
+	}
+
+	states := 0
+	for states < MEMORY_EXPIRY_BATCH && len(fs.expiry) > 0 && !now.Before(fs.expiry[0].expires) {
+		clientID := fs.expiry[0].clientID
+		tombstoneExpires := fs.expiry[0].expires.Add(fs.config.TombstoneTTL)
+		if err := fs.log(walExpireState, clientID, expiryPayload(tombstoneExpires)); err != nil {
+			// The state is left to expire on a later run, GetState can still tell it has expired.
+			fmt.Printf("unable to expire clientID=%s: %s\n", clientID, err)
+			return false
+		}
+
+		fs.removeState(clientID)
+		fs.putTombstone(clientID, tombstoneExpires)
+		states++
+	}
+
+	// Tombstones that expire aren't logged, the expiry logged with them drops them again after a restart.
+	tombstones := 0
+	for tombstones < MEMORY_EXPIRY_BATCH && len(fs.tombstoneExpiry) > 0 && !now.Before(fs.tombstoneExpiry[0].expires) {
+		t := heap.Pop(&fs.tombstoneExpiry).(*expiryItem)
+		delete(fs.tombstones, t.clientID)
+		tombstones++
+	}
+
+	fs.compactIfDue()
+
+	return states == MEMORY_EXPIRY_BATCH || tombstones == MEMORY_EXPIRY_BATCH
+}
+
+// putState stores the encoded state data for clientID. Assumes the caller holds fs.lock.
+func (fs *FileStorage) putState(clientID uuid.UUID, epoch uint64, lastUpdated time.Time, data []byte) {
+	expires := lastUpdated.Add(GARBGAGE_TIMEOUT)
+	if entry, ok := fs.states[clientID]; ok {
+		entry.epoch, entry.data, entry.expires = epoch, data, expires
+		heap.Fix(&fs.expiry, entry.index)
+		return
+	}
+
+	entry := &fileEntry{
+		expiryItem: expiryItem{clientID: clientID, expires: expires},
+		epoch:      epoch,
+		data:       data,
+	}
+	fs.states[clientID] = entry
+	heap.Push(&fs.expiry, &entry.expiryItem)
+}
+
+// removeState removes the state of clientID, if it has one. Assumes the caller holds fs.lock.
+func (fs *FileStorage) removeState(clientID uuid.UUID) {
+	if entry, ok := fs.states[clientID]; ok {
+		heap.Remove(&fs.expiry, entry.index)
+		delete(fs.states, clientID)
+	}
+}
+
+// putTombstone remembers clientID as expired until expires. Assumes the caller holds fs.lock.
+func (fs *FileStorage) putTombstone(clientID uuid.UUID, expires time.Time) {
+	if t, ok := fs.tombstones[clientID]; ok {
+		t.expires = expires
+		heap.Fix(&fs.tombstoneExpiry, t.index)
+		return
+	}
+
+	t := &expiryItem{clientID: clientID, expires: expires}
+	fs.tombstones[clientID] = t
+	heap.Push(&fs.tombstoneExpiry, t)
+}
+
+// expiryPayload is the payload of an expiry record, when the tombstone expires.
+func expiryPayload(expires time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(expires.UnixNano()))
+}
+
+func (fs *FileStorage) SetState(ctx context.Context, clientID uuid.UUID, state *State) error {
+	data, err := state.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	// An expired client ID can't be reused, so storing its state again mustn't bring it back, whether or not the
+	// background goroutine has removed the state yet.
+	now := fs.config.Clock.Now()
+	entry, ok := fs.states[clientID]
+	if ok && !now.Before(entry.expires) {
+		return ErrExpired
+	}
+	if t, tombstoned := fs.tombstones[clientID]; !ok && tombstoned && now.Before(t.expires) {
+		return ErrExpired
+	}
+	if ok && entry.epoch > state.epoch {
+		return ErrFenced
+	}
+
+	err = fs.log(walSetState, clientID, data)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnavailable, err)
+	}
+	fs.putState(clientID, state.epoch, state.lastUpdated, data)
+	fs.compactIfDue()
+
+	return nil
+}
+
+func (fs *FileStorage) DeleteState(ctx context.Context, clientID uuid.UUID, epoch uint64) error {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	if entry, ok := fs.states[clientID]; ok && entry.epoch > epoch {
+		return ErrFenced
+	}
+
+	err := fs.log(walDeleteState, clientID, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnavailable, err)
+	}
+	fs.removeState(clientID)
+	fs.compactIfDue()
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// TestFileStorageSkipsUndecodableRecords checks that an intact record that can't be applied costs only its own
+// client's state on recovery, and that the records after it are still replayed.
+func TestFileStorageSkipsUndecodableRecords(t *testing.T) {
+	dir := t.TempDir()
+	config := FileStorageConfig{Dir: dir, Fsync: FSYNC_NEVER, SnapshotEvery: 1000, ExpiryInterval: time.Second}
+
+	fs, err := NewFileStorage(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(1), nil, 10, protocol.ChecksumAlgorithm_SHA256)
+	before, undecodable, after := uuid.New(), uuid.New(), uuid.New()
+	for _, clientID := range []uuid.UUID{before, undecodable} {
+		if err := fs.SetState(context.Background(), clientID, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs.Close()
+
+	// A state from a server with a newer encoding version, followed by one this server can decode.
+	data, _ := s.MarshalBinary()
+	newer := append([]byte{stateEncodingVersion + 1}, data[1:]...)
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.Write(appendRecord(appendRecord(nil, walSetState, undecodable, newer), walSetState, after, data))
+	wal.Close()
+
+	fs, err = NewFileStorage(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	for _, clientID := range []uuid.UUID{before, after} {
+		if _, err := fs.GetState(context.Background(), clientID); err != nil {
+			t.Errorf("GetState(%s) = %v, want the stored state", clientID, err)
+		}
+	}
+	if _, err := fs.GetState(context.Background(), undecodable); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetState of the undecodable state = %v, want ErrNotFound", err)
+	}
+}
+
+// TestFileStorageRecoversDamagedRecords checks that a damaged last record of the log is discarded as torn, and that
+// a damaged record before it fails recovery rather than discarding the records after it.
+func TestFileStorageRecoversDamagedRecords(t *testing.T) {
+	s := newState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(1), nil, 10, protocol.ChecksumAlgorithm_SHA256)
+	data, _ := s.MarshalBinary()
+	clientIDs := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	var records []byte
+	for _, clientID := range clientIDs {
+		records = appendRecord(records, walSetState, clientID, data)
+	}
+	recordLength := len(records) / len(clientIDs)
+
+	tests := []struct {
+		name string
+		// The byte at offset in the log is XORed with flip.
+		offset int
+		flip   byte
+		// recovered is how many of the records are recovered, or -1 if recovery fails.
+		recovered int
+	}{
+		{"torn last record", len(records) - 1, 0xff, 2},
+		{"damaged payload of the middle record", 2*recordLength - 1, 0xff, -1},
+		// The length then runs past the end of the log, as a torn record's would.
+		{"damaged length of the middle record", recordLength + 1, 0x01, -1},
+		{"damaged length of the last record", 2*recordLength + 1, 0x01, -1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			damaged := append([]byte(nil), records...)
+			damaged[test.offset] ^= test.flip
+			if err := os.WriteFile(filepath.Join(dir, walFileName), damaged, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			fs, err := NewFileStorage(FileStorageConfig{Dir: dir, Fsync: FSYNC_NEVER, SnapshotEvery: 1000, ExpiryInterval: time.Second})
+			if test.recovered < 0 {
+				if err == nil {
+					fs.Close()
+					t.Fatal("NewFileStorage succeeded, want an error for the damaged record")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fs.Close()
+
+			for i, clientID := range clientIDs {
+				_, err := fs.GetState(context.Background(), clientID)
+				if i < test.recovered && err != nil {
+					t.Errorf("GetState of record %d = %v, want the stored state", i, err)
+				} else if i >= test.recovered && !errors.Is(err, ErrNotFound) {
+					t.Errorf("GetState of record %d = %v, want ErrNotFound", i, err)
+				}
+			}
+		})
+	}
+}
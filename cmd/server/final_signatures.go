@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+	"github.com/jamesrobb/ably-takehome/signing"
+)
+
+// signingStream signs the statement made by the last NumberResponse sent on a stream, and by every checkpoint, and
+// attaches the signature to it along with the seed commitment the statement was signed with.
+type signingStream struct {
+	numberStream
+	key ed25519.PrivateKey
+	// statement is completed with the count and checksum of each NumberResponse it is signed for.
+	statement signing.Statement
+}
+
+func (ss *signingStream) Send(response *protocol.NumberResponse) error {
+	if response.Checksum != "" {
+		// The last index is the stream's length, which for a stream bounded by its duration is only known now.
+		response.Signature = ss.sign(response.Index+distribution.Count(response)-1, response.ChecksumAlgorithm, response.Checksum)
+		response.SeedCommitment = ss.statement.SeedCommitment
+	}
+	if cp := response.Checkpoint; cp != nil {
+		cp.Signature = ss.sign(cp.Index, response.ChecksumAlgorithm, cp.Checksum)
+		response.SeedCommitment = ss.statement.SeedCommitment
+	}
+
+	return ss.numberStream.Send(response)
+}
+
+// sign returns the signature of the statement that count numbers were sent with checksum sum.
+func (ss *signingStream) sign(count uint32, algorithm protocol.ChecksumAlgorithm, sum string) []byte {
+	ss.statement.Count = count
+	ss.statement.Algorithm = algorithm
+	ss.statement.Checksum = sum
+
+	return ss.statement.Sign(ss.key)
+}
+
+func (ns *numberServer) GetPublicKey(ctx context.Context, request *protocol.PublicKeyRequest) (*protocol.PublicKeyResponse, error) {
+	if ns.config.signingKey == nil {
+		return nil, status.Error(codes.NotFound, "server doesn't sign its checksums")
+	}
+
+	return &protocol.PublicKeyResponse{
+		Ed25519PublicKey: ns.config.signingKey.Public().(ed25519.PublicKey),
+	}, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// leasePolicy decides what happens when a stream is opened for a client that already has one open.
+type leasePolicy string
+
+const (
+	// LEASE_TAKEOVER cancels the open stream and hands the client to the new one, which suits a client that
+	// reconnects before the server has noticed its old connection is gone.
+	LEASE_TAKEOVER leasePolicy = "takeover"
+	// LEASE_REJECT refuses the new stream with ALREADY_EXISTS while the open one lasts.
+	LEASE_REJECT leasePolicy = "reject"
+)
+
+func parseLeasePolicy(policy string) (leasePolicy, error) {
+	switch p := leasePolicy(policy); p {
+	case LEASE_TAKEOVER, LEASE_REJECT:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("unknown lease policy %q, expected one of takeover or reject", policy)
+}
+
+// leases records which clients have a stream open on this server, so that only one stream at a time serves a
+// client. Leases are local to the server; between servers sharing a storage, the epoch each stream claims with
+// its first write fences out the streams before it.
+type leases struct {
+	policy leasePolicy
+
+	lock sync.Mutex
+	held map[uuid.UUID]*lease
+}
+
+// lease is held by the stream serving a client.
+type lease struct {
+	cancel context.CancelFunc
+	// done is closed once the stream has released the lease.
+	done chan struct{}
+	// takenOver is set when a newer stream took the lease. It is guarded by the leases' lock.
+	takenOver bool
+}
+
+func newLeases(policy leasePolicy) *leases {
+	return &leases{
+		policy: policy,
+		held:   make(map[uuid.UUID]*lease),
+	}
+}
+
+// acquire takes the lease on clientID for a stream, applying the policy if another stream holds it. A taken
+// over stream is cancelled and waited for, so that it has stopped writing the client's state by the time acquire
+// returns. The returned context is cancelled if the lease is taken over in turn. The lease must be released once
+// the stream is finished.
+func (l *leases) acquire(ctx context.Context, clientID uuid.UUID) (context.Context, *lease, error) {
+	l.lock.Lock()
+	previous, held := l.held[clientID]
+	if held && l.policy == LEASE_REJECT {
+		l.lock.Unlock()
+		return nil, nil, retryableError(codes.AlreadyExists, BUSY_RETRY_DELAY, "a stream is already open for clientID")
+	}
+	if held {
+		previous.takenOver = true
+		previous.cancel()
+	}
+
+	leaseCtx, cancel := context.WithCancel(ctx)
+	le := &lease{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	l.held[clientID] = le
+	l.lock.Unlock()
+
+	// A stream stops promptly once cancelled. The wait isn't cut short even if this stream is taken over too, so
+	// that the streams for a client always finish in the order they started. If this stream goes away while it
+	// waits, its lease is only released once the previous stream is done, which keeps that order for a stream
+	// taking it over in turn.
+	if held {
+		fmt.Printf("clientID=%s reconnected, taking over its open stream\n", clientID)
+		select {
+		case <-previous.done:
+		case <-ctx.Done():
+			go func() {
+				<-previous.done
+				l.release(clientID, le)
+			}()
+			return nil, nil, ctx.Err()
+		}
+	}
+
+	return leaseCtx, le, nil
+}
+
+// release gives up the lease, which must have come from acquire for clientID.
+func (l *leases) release(clientID uuid.UUID, le *lease) {
+	l.lock.Lock()
+	if l.held[clientID] == le {
+		delete(l.held, clientID)
+	}
+	l.lock.Unlock()
+
+	le.cancel()
+	close(le.done)
+}
+
+// streamError returns the error a stream holding le should finish with, given the error it stopped with.
+func (l *leases) streamError(le *lease, err error) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if le.takenOver {
+		return status.Error(codes.Aborted, "stream was taken over by a newer stream for clientID")
+	}
+
+	return err
+}
+
+// nextEpoch returns the epoch a stream claims a client's state with, given the epoch of the stored state. The
+// high 32 bits count the streams that have served the client. The low 32 bits are random, so that if two servers
+// claim the same state at once they still pick different epochs, and only the stream with the higher one can go on
+// writing.
+func nextEpoch(stored uint64) uint64 {
+	return (stored>>32+1)<<32 | uint64(rand.Uint32())
+}
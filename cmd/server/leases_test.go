@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestLeaseTakeoverCancelled checks that a stream taking over a client gives up waiting for the open stream when its
+// own context ends, and that a stream taking over after it still waits for the open stream to finish.
+func TestLeaseTakeoverCancelled(t *testing.T) {
+	l := newLeases(LEASE_TAKEOVER)
+	clientID := uuid.New()
+
+	_, first, err := l.acquire(context.Background(), clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := l.acquire(ctx, clientID); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire while the open stream runs returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		_, le, err := l.acquire(context.Background(), clientID)
+		if err == nil {
+			l.release(clientID, le)
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lease was taken over before the open stream finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	l.release(clientID, first)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("lease wasn't taken over once the open stream finished")
+	}
+}
@@ -1,30 +1,218 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"google.golang.org/grpc"
 
 	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+	"github.com/jamesrobb/ably-takehome/signing"
 )
 
 func main() {
 	port := flag.Int("port", 50051, "port to run server on")
-	_ = port
+	minInterval := flag.Duration("minInterval", time.Millisecond, "smallest delay between numbers a client may request")
+	maxInterval := flag.Duration("maxInterval", time.Minute, "largest delay between numbers a client may request")
+	maxBatchSize := flag.Uint("maxBatchSize", 1000, "largest number of numbers a client may ask to receive per message")
+	maxNumbers := flag.Uint("maxNumbers", uint(DEFAULT_MAX_NUMBERS), "longest sequence a client may ask for, longer requests and unbounded streams are cut to it, 0 means no limit")
+	maxDuration := flag.Duration("maxDuration", 0, "longest time a stream may run for, longer requests and streams without a duration are cut to it, 0 means no limit")
+	maxMerkleNumbers := flag.Uint("maxMerkleNumbers", uint(DEFAULT_MAX_MERKLE_NUMBERS), "longest sequence a client may ask for Merkle proofs of, which the server generates in full when the stream starts")
+	ackWindow := flag.Uint("ackWindow", 256, "number of unacknowledged numbers a GetAckedNumbers stream may have in flight")
+	maxStreams := flag.Int("maxStreams", 0, "most streams served at once, further requests are refused with RESOURCE_EXHAUSTED until one ends, 0 means no limit")
+	issueSessionIDs := flag.Bool("issueSessionIDs", false, "choose the ID of every new stream on the server, which clients request by leaving client_id empty and must then resume with")
+	resumeKeys := flag.String("resumeKeys", "", "comma separated <id>:<hex secret> keys for sealing resume tokens, the first seals new tokens and all are accepted, empty issues no tokens")
+	resumeTokenInterval := flag.Duration("resumeTokenInterval", 0, "least time between two resume tokens on a stream, 0 sends one with every message")
+	signingKeyHex := flag.String("signingKey", "", "hex encoded 32 byte Ed25519 private key seed to sign the final message of every stream with, empty signs nothing")
+	leasePolicyFlag := flag.String("leasePolicy", "takeover", "what happens to a stream opened for a client that already has one, takeover cancels the open stream and reject refuses the new one")
+	storageKind := flag.String("storage", "memory", "where client state is stored, one of memory, file, redis or none")
+	memoryShards := flag.Int("memoryShards", 64, "number of independently locked shards the memory storage spreads client state over")
+	expiryInterval := flag.Duration("expiryInterval", time.Second, "how often the memory and file storages remove expired client state")
+	tombstoneTTL := flag.Duration("tombstoneTTL", 24*time.Hour, "how long the memory, file and redis storages remember an expired client ID")
+	storageDir := flag.String("storageDir", "./state", "directory the file storage keeps its write-ahead log and snapshots in")
+	fsync := flag.String("fsync", "always", "when the file storage syncs its write-ahead log to disk, one of always, interval or never")
+	fsyncInterval := flag.Duration("fsyncInterval", 100*time.Millisecond, "how often the file storage syncs with -fsync=interval")
+	snapshotEvery := flag.Int("snapshotEvery", 10000, "number of write-ahead log records after which the file storage writes a snapshot")
+	redisAddr := flag.String("redisAddr", "localhost:6379", "address of the Redis protocol server the redis storage uses")
+	redisKeyPrefix := flag.String("redisKeyPrefix", "numbers:", "prefix of every key the redis storage uses")
+	redisPoolSize := flag.Int("redisPoolSize", 16, "number of idle connections the redis storage keeps open")
+	var sources sourceFlags
+	flag.Var(&sources, "source", "register a source clients may take numbers from as <name>=<kind>:<argument>, with kind one of device:PATH, replay:PATH or script:NUMBERS, may be repeated")
 	flag.Parse()
 
-	go startNumberServer(*port)
+	if *maxBatchSize == 0 {
+		fmt.Println("maxBatchSize must be at least 1")
+		os.Exit(1)
+	}
+	if *ackWindow == 0 {
+		fmt.Println("ackWindow must be at least 1")
+		os.Exit(1)
+	}
+	if *maxNumbers > uint(UNBOUNDED_NUMBERS) {
+		fmt.Printf("maxNumbers can be at most %d\n", UNBOUNDED_NUMBERS)
+		os.Exit(1)
+	}
+	if *maxMerkleNumbers > uint(UNBOUNDED_NUMBERS) {
+		fmt.Printf("maxMerkleNumbers can be at most %d\n", UNBOUNDED_NUMBERS)
+		os.Exit(1)
+	}
+	if *maxDuration < 0 {
+		fmt.Println("maxDuration cannot be negative")
+		os.Exit(1)
+	}
+	if *maxStreams < 0 {
+		fmt.Println("maxStreams cannot be negative")
+		os.Exit(1)
+	}
+	if *minInterval <= 0 || *minInterval > *maxInterval {
+		fmt.Println("minInterval must be positive and no larger than maxInterval")
+		os.Exit(1)
+	}
+	if *maxInterval > GARBGAGE_TIMEOUT/2 {
+		fmt.Printf("maxInterval can be at most %s, half the time a stream's state is kept, so a client that disconnects between two numbers can still resume\n", GARBGAGE_TIMEOUT/2)
+		os.Exit(1)
+	}
+
+	if *resumeTokenInterval < 0 || *resumeTokenInterval >= GARBGAGE_TIMEOUT {
+		fmt.Printf("resumeTokenInterval must be at least 0 and less than %s, how long a resume token lives, so a stream always has a live token\n", GARBGAGE_TIMEOUT)
+		os.Exit(1)
+	}
+
+	policy, err := parseLeasePolicy(*leasePolicyFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var tokens *resumeTokens
+	if *resumeKeys != "" {
+		tokens, err = parseResumeKeys(*resumeKeys)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	var signingKey ed25519.PrivateKey
+	if *signingKeyHex != "" {
+		signingKey, err = signing.ParsePrivateKey(*signingKeyHex)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("signing final checksums with public key %x\n", signingKey.Public())
+	}
+
+	// Sources are registered before the storage is opened, as stored states taken from them name them.
+	for _, spec := range sources {
+		if err := registerSource(spec); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	config := numberServerConfig{
+		minInterval:         *minInterval,
+		maxInterval:         *maxInterval,
+		maxBatchSize:        uint32(*maxBatchSize),
+		ackWindow:           uint32(*ackWindow),
+		leasePolicy:         policy,
+		maxStreams:          *maxStreams,
+		issueSessionIDs:     *issueSessionIDs,
+		resumeTokens:        tokens,
+		resumeTokenInterval: *resumeTokenInterval,
+		signingKey:          signingKey,
+		maxNumbers:          uint32(*maxNumbers),
+		maxDuration:         *maxDuration,
+		maxMerkleNumbers:    uint32(*maxMerkleNumbers),
+	}
+
+	var stateStorage StateStorage
+	switch *storageKind {
+	case "memory":
+		if *memoryShards <= 0 || *expiryInterval <= 0 {
+			fmt.Println("memoryShards and expiryInterval must be positive")
+			os.Exit(1)
+		}
+
+		stateStorage = NewInMemoryStorage(InMemoryStorageConfig{
+			Shards:         *memoryShards,
+			TombstoneTTL:   *tombstoneTTL,
+			ExpiryInterval: *expiryInterval,
+		})
+	case "file":
+		policy, err := parseFsyncPolicy(*fsync)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if *snapshotEvery <= 0 || *expiryInterval <= 0 || (policy == FSYNC_INTERVAL && *fsyncInterval <= 0) {
+			fmt.Println("snapshotEvery, expiryInterval and fsyncInterval must be positive")
+			os.Exit(1)
+		}
+
+		stateStorage, err = NewFileStorage(FileStorageConfig{
+			Dir:            *storageDir,
+			Fsync:          policy,
+			FsyncInterval:  *fsyncInterval,
+			SnapshotEvery:  *snapshotEvery,
+			TombstoneTTL:   *tombstoneTTL,
+			ExpiryInterval: *expiryInterval,
+		})
+		if err != nil {
+			fmt.Printf("unable to open file storage: %s\n", err)
+			os.Exit(1)
+		}
+	case "redis":
+		if *redisPoolSize <= 0 {
+			fmt.Println("redisPoolSize must be positive")
+			os.Exit(1)
+		}
+
+		var err error
+		stateStorage, err = NewRedisStorage(RedisStorageConfig{
+			Addr:         *redisAddr,
+			KeyPrefix:    *redisKeyPrefix,
+			TombstoneTTL: *tombstoneTTL,
+			PoolSize:     *redisPoolSize,
+			Timeout:      REDIS_TIMEOUT,
+		})
+		if err != nil {
+			fmt.Printf("unable to connect to redis storage: %s\n", err)
+			os.Exit(1)
+		}
+	case "none":
+		if tokens == nil {
+			fmt.Println("storage none can only resume streams from resume tokens, which need resumeKeys")
+			os.Exit(1)
+		}
+
+		stateStorage = NoStorage{}
+	default:
+		fmt.Printf("unknown storage %q\n", *storageKind)
+		os.Exit(1)
+	}
+
+	go startNumberServer(*port, config, stateStorage)
 	fmt.Println("listening...")
 
 	waitForTerminationSignal()
+
+	if closer, ok := stateStorage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			fmt.Printf("unable to close state storage: %s\n", err)
+		}
+	}
 }
 
-func startNumberServer(port int) {
+func startNumberServer(port int, config numberServerConfig, stateStorage StateStorage) {
 	var opts []grpc.ServerOption
 
 	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
@@ -34,8 +222,7 @@ func startNumberServer(port int) {
 	}
 
 	grpcServer := grpc.NewServer(opts...)
-	inMemoryStorage := NewInMemoryStorage()
-	ns := newNumberServer(inMemoryStorage)
+	ns := newNumberServer(stateStorage, config)
 	protocol.RegisterNumbersServer(grpcServer, ns)
 	err = grpcServer.Serve(lis)
 	if err != nil {
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MEMORY_EXPIRY_BATCH is the most entries expired per shard while holding its lock, so that a large expiry doesn't
+// hold up the requests waiting on that shard.
+const MEMORY_EXPIRY_BATCH = 1024
+
+type InMemoryStorageConfig struct {
+	// Shards is the number of independently locked partitions the client IDs are spread over.
+	Shards int
+	// TombstoneTTL is how long an expired client ID is remembered once its state has expired.
+	TombstoneTTL time.Duration
+	// ExpiryInterval is how often the background goroutine removes expired states and tombstones.
+	ExpiryInterval time.Duration
+	// Clock defaults to the system clock.
+	Clock Clock
+}
+
+// InMemoryStorage keeps client state in memory, spread over shards that each have their own lock. Each shard keeps
+// its states in a min-heap ordered by when they expire, and a background goroutine pops the expired ones every
+// ExpiryInterval, leaving a tombstone in their place. Tombstones all live for TombstoneTTL, so they expire in the
+// order they were made and are kept in a queue. Lookups check expiry times themselves, so they don't depend on when
+// the background goroutine last ran.
+type InMemoryStorage struct {
+	config InMemoryStorageConfig
+	shards []*memoryShard
+	closed chan struct{}
+}
+
+type memoryShard struct {
+	lock   sync.Mutex
+	states map[uuid.UUID]*memoryEntry
+	expiry expiryHeap
+
+	// tombstones maps an expired client ID to when its tombstone expires.
+	tombstones     map[uuid.UUID]time.Time
+	tombstoneQueue []tombstone
+}
+
+type memoryEntry struct {
+	expiryItem
+	epoch uint64
+	// data is the state encoded by State.MarshalBinary, as every storage keeps it.
+	data []byte
+}
+
+type tombstone struct {
+	clientID uuid.UUID
+	expires  time.Time
+}
+
+func NewInMemoryStorage(config InMemoryStorageConfig) *InMemoryStorage {
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	ims := &InMemoryStorage{
+		config: config,
+		shards: make([]*memoryShard, config.Shards),
+		closed: make(chan struct{}),
+	}
+	for i := range ims.shards {
+		ims.shards[i] = &memoryShard{
+			states:     make(map[uuid.UUID]*memoryEntry),
+			tombstones: make(map[uuid.UUID]time.Time),
+		}
+	}
+
+	go ims.expirePeriodically()
+
+	return ims
+}
+
+func (ims *InMemoryStorage) shard(clientID uuid.UUID) *memoryShard {
+	return ims.shards[binary.BigEndian.Uint32(clientID[12:])%uint32(len(ims.shards))]
+}
+
+func (ims *InMemoryStorage) GetState(ctx context.Context, clientID uuid.UUID) (*State, error) {
+	shard := ims.shard(clientID)
+	now := ims.config.Clock.Now()
+
+	shard.lock.Lock()
+	entry, ok := shard.states[clientID]
+	if !ok || !now.Before(entry.expires) {
+		tombstoneExpires, tombstoned := shard.tombstones[clientID]
+		shard.lock.Unlock()
+
+		// The state may have expired since the background goroutine last ran.
+		if ok || (tombstoned && now.Before(tombstoneExpires)) {
+			return nil, ErrExpired
+		}
+
+		return nil, ErrNotFound
+	}
+	data := entry.data
+	shard.lock.Unlock()
+
+	// Encoded states are replaced rather than modified, so decoding one doesn't need the lock.
+	s := &State{}
+	err := s.UnmarshalBinary(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	return s, nil
+}
+
+func (ims *InMemoryStorage) SetState(ctx context.Context, clientID uuid.UUID, state *State) error {
+	shard := ims.shard(clientID)
+
+	// Store a snapshot, the caller carries on advancing its own copy of the state.
+	data, err := state.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	expires := state.lastUpdated.Add(GARBGAGE_TIMEOUT)
+	now := ims.config.Clock.Now()
+
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	// An expired client ID can't be reused, so storing its state again mustn't bring it back, whether or not the
+	// background goroutine has removed the state yet.
+	entry, ok := shard.states[clientID]
+	if ok && !now.Before(entry.expires) {
+		return ErrExpired
+	}
+	if tombstoneExpires, tombstoned := shard.tombstones[clientID]; !ok && tombstoned && now.Before(tombstoneExpires) {
+		return ErrExpired
+	}
+	if ok && entry.epoch > state.epoch {
+		return ErrFenced
+	}
+	if ok {
+		entry.epoch = state.epoch
+		entry.data = data
+		entry.expires = expires
+		heap.Fix(&shard.expiry, entry.index)
+		return nil
+	}
+
+	entry = &memoryEntry{
+		expiryItem: expiryItem{clientID: clientID, expires: expires},
+		epoch:      state.epoch,
+		data:       data,
+	}
+	shard.states[clientID] = entry
+	heap.Push(&shard.expiry, &entry.expiryItem)
+
+	return nil
+}
+
+func (ims *InMemoryStorage) DeleteState(ctx context.Context, clientID uuid.UUID, epoch uint64) error {
+	shard := ims.shard(clientID)
+
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	entry, ok := shard.states[clientID]
+	if ok && entry.epoch > epoch {
+		return ErrFenced
+	}
+	if ok {
+		heap.Remove(&shard.expiry, entry.index)
+		delete(shard.states, clientID)
+	}
+
+	return nil
+}
+
+// Close stops the background expiry.
+func (ims *InMemoryStorage) Close() error {
+	close(ims.closed)
+
+	return nil
+}
+
+func (ims *InMemoryStorage) expirePeriodically() {
+	ticker := time.NewTicker(ims.config.ExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ims.closed:
+			return
+		case <-ticker.C:
+			ims.expire(ims.config.Clock.Now())
+		}
+	}
+}
+
+// expire replaces the states that have expired by now with tombstones, and drops the tombstones that have
+// expired. It returns the number of states and tombstones removed.
+func (ims *InMemoryStorage) expire(now time.Time) (int, int) {
+	expiredStates, expiredTombstones := 0, 0
+
+	for _, shard := range ims.shards {
+		for {
+			states, tombstones := shard.expireBatch(now, ims.config.TombstoneTTL)
+			expiredStates += states
+			expiredTombstones += tombstones
+
+			if states < MEMORY_EXPIRY_BATCH && tombstones < MEMORY_EXPIRY_BATCH {
+				break
+			}
+		}
+	}
+
+	return expiredStates, expiredTombstones
+}
+
+// expireBatch expires up to MEMORY_EXPIRY_BATCH states and tombstones from the shard.
+func (shard *memoryShard) expireBatch(now time.Time, tombstoneTTL time.Duration) (int, int) {
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	states := 0
+	for states < MEMORY_EXPIRY_BATCH && len(shard.expiry) > 0 && !now.Before(shard.expiry[0].expires) {
+		entry := heap.Pop(&shard.expiry).(*expiryItem)
+		delete(shard.states, entry.clientID)
+
+		t := tombstone{
+			clientID: entry.clientID,
+			expires:  entry.expires.Add(tombstoneTTL),
+		}
+		shard.tombstones[t.clientID] = t.expires
+		shard.tombstoneQueue = append(shard.tombstoneQueue, t)
+		states++
+	}
+
+	tombstones := 0
+	for tombstones < MEMORY_EXPIRY_BATCH && len(shard.tombstoneQueue) > 0 && !now.Before(shard.tombstoneQueue[0].expires) {
+		t := shard.tombstoneQueue[0]
+		shard.tombstoneQueue[0] = tombstone{}
+		shard.tombstoneQueue = shard.tombstoneQueue[1:]
+
+		// The client ID may have been given a newer tombstone since.
+		if shard.tombstones[t.clientID].Equal(t.expires) {
+			delete(shard.tombstones, t.clientID)
+		}
+		tombstones++
+	}
+
+	return states, tombstones
+}
+
+// expiryItem is an entry of an expiryHeap, something stored for a client ID until it expires.
+type expiryItem struct {
+	clientID uuid.UUID
+	expires  time.Time
+	// index is the item's position in the heap.
+	index int
+}
+
+// expiryHeap is a container/heap of items ordered by when they expire.
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	e := x.(*expiryItem)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+
+	return e
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/merkle"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// merkleStream attaches the root of the sequence's Merkle tree to the first NumberResponse sent on a stream, and the
+// proof of every chunk to the NumberResponse that carries the chunk's last number. Every stream sends the root, so a
+// client that resumes on another server can check it is still being sent the same sequence.
+type merkleStream struct {
+	numberStream
+	tree *merkle.Tree
+	// sendRoot is cleared once the root has been sent.
+	sendRoot bool
+}
+
+func (ms *merkleStream) Send(response *protocol.NumberResponse) error {
+	if ms.sendRoot {
+		response.MerkleRoot = ms.tree.Root()
+		ms.sendRoot = false
+	}
+
+	last := response.Index + distribution.Count(response) - 1
+
+	for chunk := ms.tree.ChunkOf(response.Index); chunk <= ms.tree.ChunkOf(last); chunk++ {
+		if _, chunkLast := ms.tree.ChunkBounds(chunk); chunkLast > last {
+			continue
+		}
+
+		proof := ms.tree.Proof(chunk)
+		response.ChunkProofs = append(response.ChunkProofs, &protocol.ChunkProof{
+			Chunk: proof.Chunk,
+			Root:  proof.Root,
+			Path:  proof.Path,
+		})
+	}
+
+	return ms.numberStream.Send(response)
+}
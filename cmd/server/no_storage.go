@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// NoStorage is a StateStorage that keeps nothing, for servers that resume streams from resume tokens alone. Every
+// lookup finds no state and every write succeeds.
+type NoStorage struct{}
+
+func (NoStorage) GetState(ctx context.Context, clientID uuid.UUID) (*State, error) {
+	return nil, ErrNotFound
+}
+
+func (NoStorage) SetState(ctx context.Context, clientID uuid.UUID, state *State) error {
+	return nil
+}
+
+func (NoStorage) DeleteState(ctx context.Context, clientID uuid.UUID, epoch uint64) error {
+	return nil
+}
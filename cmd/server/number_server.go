@@ -1,23 +1,76 @@
 package main
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
+	"math"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/jamesrobb/ably-takehome/checksum"
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/fairness"
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/merkle"
 	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
-
-	"gonum.org/v1/gonum/mathext/prng"
+	"github.com/jamesrobb/ably-takehome/signing"
 )
 
-const MAX_NUMBERS uint32 = 65535
+// DEFAULT_MAX_NUMBERS is the longest sequence a client may ask for unless the server is configured otherwise.
+const DEFAULT_MAX_NUMBERS uint32 = 65535
+
+// UNBOUNDED_NUMBERS is the length of a stream that isn't bounded by num_numbers, the largest index a
+// NumberResponse can carry.
+const UNBOUNDED_NUMBERS uint32 = math.MaxUint32
+
+// DEFAULT_INTERVAL is the delay between numbers when a request specifies neither a rate nor an interval.
+const DEFAULT_INTERVAL time.Duration = time.Second
+
+// MIN_MERKLE_CHUNK_SIZE is the smallest merkle_chunk_size a client may request. It bounds the size of the Merkle
+// tree the server holds for a stream.
+const MIN_MERKLE_CHUNK_SIZE uint32 = 16
+
+// DEFAULT_MAX_MERKLE_NUMBERS is the longest sequence a client may ask for Merkle proofs of unless the server is
+// configured otherwise. The whole sequence is generated to build its tree when the stream starts, so this bounds
+// that work and the tree the server holds for a stream.
+const DEFAULT_MAX_MERKLE_NUMBERS uint32 = 1 << 20
+
+type numberServerConfig struct {
+	// minInterval and maxInterval bound the delay between numbers that a client may request.
+	minInterval time.Duration
+	maxInterval time.Duration
+	// maxBatchSize bounds the number of numbers a client may ask to have packed into one NumberResponse.
+	maxBatchSize uint32
+	// ackWindow is the number of unacknowledged numbers a GetAckedNumbers stream may have in flight.
+	ackWindow uint32
+	// leasePolicy decides what happens to a stream opened for a client that already has one.
+	leasePolicy leasePolicy
+	// maxStreams bounds the number of streams served at once, 0 means no limit.
+	maxStreams int
+	// issueSessionIDs makes the server choose the ID of every new stream, clients may only resume streams with IDs
+	// it issued.
+	issueSessionIDs bool
+	// resumeTokens seals the resume tokens sent to clients and opens the ones they present, it is nil when the
+	// server doesn't issue them.
+	resumeTokens *resumeTokens
+	// resumeTokenInterval is the least time between two resume tokens on a stream, 0 sends one with every
+	// NumberResponse.
+	resumeTokenInterval time.Duration
+	// signingKey signs the final NumberResponse of every stream, it is nil when the server doesn't sign.
+	signingKey ed25519.PrivateKey
+	// maxNumbers and maxDuration bound the length and duration of every stream, 0 means no limit.
+	maxNumbers  uint32
+	maxDuration time.Duration
+	// maxMerkleNumbers is the longest sequence Merkle proofs are sent for.
+	maxMerkleNumbers uint32
+}
 
 type numberServer struct {
 	protocol.UnimplementedNumbersServer
@@ -26,80 +79,570 @@ type numberServer struct {
 	clientStateLock sync.Mutex
 
 	stateStorage StateStorage
+	config       numberServerConfig
+
+	// scheduler paces every stream served by the server.
+	scheduler *scheduler
+	leases    *leases
+	// streamSlots holds a token for every stream being served, it is nil when the number of streams isn't limited.
+	streamSlots chan struct{}
 }
 
-func newNumberServer(stateStore StateStorage) *numberServer {
-	return &numberServer{
+func newNumberServer(stateStore StateStorage, config numberServerConfig) *numberServer {
+	ns := &numberServer{
 		stateStorage: stateStore,
+		config:       config,
+		scheduler:    newScheduler(),
+		leases:       newLeases(config.leasePolicy),
+	}
+	if config.maxStreams > 0 {
+		ns.streamSlots = make(chan struct{}, config.maxStreams)
+	}
+
+	return ns
+}
+
+// admitStream takes a slot for a new stream, failing with codes.ResourceExhausted if the server is already serving
+// maxStreams streams. The returned function gives the slot back.
+func (ns *numberServer) admitStream() (func(), error) {
+	if ns.streamSlots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case ns.streamSlots <- struct{}{}:
+		return func() { <-ns.streamSlots }, nil
+	default:
+		return nil, retryableError(codes.ResourceExhausted, BUSY_RETRY_DELAY, "server is serving as many streams as it allows")
 	}
 }
 
 func (ns *numberServer) GetNumbers(request *protocol.NumbersRequest, stream protocol.Numbers_GetNumbersServer) error {
-	var s *State
-	var clientID uuid.UUID
-	copy(clientID[:], request.ClientId)
+	clientID, issued, err := ns.sessionID(request)
+	if err != nil {
+		return err
+	}
+
+	e, err := ns.emissionSettings(request)
+	if err != nil {
+		return err
+	}
+
+	done, err := ns.admitStream()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, le, err := ns.leases.acquire(stream.Context(), clientID)
+	if err != nil {
+		return err
+	}
+	defer ns.leases.release(clientID, le)
+
+	s, err := ns.loadState(ctx, clientID, request, false)
+	if err != nil {
+		return err
+	}
+
+	responses, err := ns.responseStream(stream, clientID, issued, s, e)
+	if err != nil {
+		return err
+	}
+
+	// Storing the state straight away claims it for this stream's epoch, fencing out any older stream.
+	if err := ns.stateStorage.SetState(ctx, clientID, s); err != nil {
+		return ns.leases.streamError(le, storageStatus(err))
+	}
+
+	// Progress is persisted just before numbers are handed to the stream. If the server stops between the two,
+	// the stored position is ahead of the client, which is safe as a client can always resume from further back.
+	err = ns.sendNumbers(ctx, responses, s, e, streamHooks{
+		sending: func(index uint32, isLast bool) error {
+			if isLast {
+				return nil
+			}
+
+			if err := ns.stateStorage.SetState(ctx, clientID, s); err != nil {
+				return storageStatus(err)
+			}
+
+			return nil
+		},
+		sent: func(index uint32, isLast bool) error {
+			if !isLast {
+				return nil
+			}
+
+			if err := ns.stateStorage.DeleteState(ctx, clientID, s.epoch); err != nil {
+				return storageStatus(err)
+			}
+
+			return nil
+		},
+	})
+
+	return ns.leases.streamError(le, err)
+}
+
+// sessionID returns the ID of the client request is for. When the server issues session IDs, a request for a new
+// stream leaves client_id empty and is given a fresh ID, in which case issued is set. Otherwise client_id must hold
+// a UUID.
+func (ns *numberServer) sessionID(request *protocol.NumbersRequest) (clientID uuid.UUID, issued bool, err error) {
+	if len(request.ClientId) == 0 && ns.config.issueSessionIDs {
+		if request.LastIndex > 0 {
+			return uuid.Nil, false, badRequest(codes.InvalidArgument, fieldViolation("client_id", "the session ID is required to resume a stream"))
+		}
 
-	if ns.stateStorage.IsExpiredClientID(clientID) {
-		return fmt.Errorf("clientID has expired and cannot be reused")
+		return uuid.New(), true, nil
 	}
 
+	clientID, err = uuid.FromBytes(request.ClientId)
+	if err != nil {
+		return uuid.Nil, false, badRequest(codes.InvalidArgument, fieldViolation("client_id", fmt.Sprintf("must be 16 bytes, got %d", len(request.ClientId))))
+	}
+	if clientID == uuid.Nil {
+		return uuid.Nil, false, badRequest(codes.InvalidArgument, fieldViolation("client_id", "cannot be the nil UUID"))
+	}
+
+	return clientID, false, nil
+}
+
+// loadState returns the state to serve request from, either resuming a stored stream or starting a new one.
+// When fromStored is set a resumed stream continues from the stored position rather than from request.LastIndex.
+// The state is given the next epoch after the stored one, which the stream claims it with.
+//
+// Only a client the storage has no state for is started afresh. If the storage can't be reached the request fails
+// with codes.Unavailable rather than restarting a sequence the client may be part way through.
+func (ns *numberServer) loadState(ctx context.Context, clientID uuid.UUID, request *protocol.NumbersRequest, fromStored bool) (*State, error) {
+	var s *State
+
 	// Check if we already have a stored data for a client.
 	// Assumptions:
 	// - two clients don't ever try to connect with the same clientID.
 	// - if a clientID is reused, it is because a client lost connection to the server and is trying to resume.
-	storedState, err := ns.stateStorage.GetState(clientID)
+	storedState, err := ns.stateStorage.GetState(ctx, clientID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, storageStatus(err)
+	}
 	if err == nil {
 		s = storedState
+		s.epoch = nextEpoch(storedState.epoch)
 		fmt.Printf("found stored state for clientID=%s\n", clientID)
-	} else {
-		numNumbers := request.NumNumbers
-		if numNumbers == 0 {
-			return fmt.Errorf("cannot send 0 numbers")
+
+		if fromStored {
+			return s, nil
+		}
+
+		// The client is the authority on what it has received. Numbers the server
+		// believes were sent but the client never got are regenerated from the seed.
+		if request.LastIndex > s.numbersSent {
+			return nil, badRequest(codes.OutOfRange, fieldViolation("last_index",
+				fmt.Sprintf("cannot resume from index %d, only %d numbers have been sent", request.LastIndex, s.numbersSent)))
 		}
-		if numNumbers > MAX_NUMBERS {
-			numNumbers = MAX_NUMBERS
+		if request.LastIndex < s.numbersSent && !s.source.Replayable() {
+			return nil, status.Errorf(codes.DataLoss, "cannot resume from index %d, numbers %d to %d came from %s and can't be generated again",
+				request.LastIndex, request.LastIndex+1, s.numbersSent, s.sourceName)
 		}
+		if request.LastIndex < s.numbersSent {
+			fmt.Printf("rewinding clientID=%s from index %d to %d\n", clientID, s.numbersSent, request.LastIndex)
+			s = s.rewind(request.LastIndex)
+		}
+		if err := checkCheckpoint(s, request); err != nil {
+			return nil, err
+		}
+
+		return s, nil
+	}
 
-		s = &State{
-			nextNumber:   0,
-			numbersSent:  0,
-			totalNumbers: numNumbers,
-			lastUpdated:  time.Now(),
-			hash:         md5.New(),
-			prng:         prng.NewMT19937(),
+	// Without a stored state, a stream can still be resumed from the resume token the client was sent.
+	if len(request.ResumeToken) > 0 {
+		return ns.stateFromToken(clientID, request)
+	}
+	if request.LastIndex > 0 {
+		return nil, status.Errorf(codes.NotFound, "cannot resume from index %d, no stream found for clientID", request.LastIndex)
+	}
+	// A client can't choose the ID of a new stream when the server issues them.
+	if ns.config.issueSessionIDs && len(request.ClientId) > 0 {
+		return nil, status.Error(codes.NotFound, "unknown session ID, leave client_id empty to start a new stream")
+	}
+
+	numNumbers, duration, err := ns.streamBounds(request)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceName, source, err := requestedSource(request)
+	if err != nil {
+		return nil, err
+	}
+	if request.Seed > 0 && len(request.WideSeed) > 0 {
+		return nil, badRequest(codes.InvalidArgument,
+			fieldViolation("seed", "only one of seed and wide_seed may be specified"),
+			fieldViolation("wide_seed", "only one of seed and wide_seed may be specified"),
+		)
+	}
+	clientChoseSeed := request.Seed > 0 || len(request.WideSeed) > 0
+	if len(request.ClientSeed) > 0 && clientChoseSeed {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("client_seed", "cannot be combined with seed or wide_seed"))
+	}
+	if len(request.ClientSeed) > fairness.MAX_CLIENT_SEED_SIZE {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("client_seed",
+			fmt.Sprintf("must be at most %d bytes, got %d", fairness.MAX_CLIENT_SEED_SIZE, len(request.ClientSeed))))
+	}
+	if !source.seeded() && (clientChoseSeed || len(request.ClientSeed) > 0) {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation(sourceField(request), fmt.Sprintf("%s can't be seeded", sourceName)))
+	}
+
+	// Did the client provide a seed? If not the server picks one and commits to it, so that the client can check
+	// the sequence wasn't chosen once the stream was under way.
+	var seed generator.Seed
+	var serverSeed, seedNonce []byte
+	switch {
+	case request.Seed > 0:
+		seed = generator.Uint32Seed(request.Seed)
+	case len(request.WideSeed) > 0:
+		if len(request.WideSeed) != 8 && len(request.WideSeed) != 32 {
+			return nil, badRequest(codes.InvalidArgument, fieldViolation("wide_seed", fmt.Sprintf("must be 8 or 32 bytes, got %d", len(request.WideSeed))))
+		}
+		seed = generator.Seed(request.WideSeed)
+	case source.seeded():
+		serverSeed, seedNonce, err = fairness.NewServerSeed()
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to pick a seed: %s", err)
 		}
+		seed = fairness.Seed(serverSeed, request.ClientSeed)
+	}
+	if err := source.checkSeed(seed); err != nil {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("wide_seed", err.Error()))
+	}
+	// A recording is replayed as it was recorded, so it can't be drawn from or run past its end.
+	if source.length > 0 && numNumbers > source.length {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("num_numbers",
+			fmt.Sprintf("source %s has only %d numbers", sourceName, source.length)))
+	}
+	if source.length > 0 && request.Distribution != nil {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("distribution",
+			fmt.Sprintf("source %s is replayed as it was recorded, so values can't be drawn from it", sourceName)))
+	}
+	if err := distribution.Check(request.Distribution, source.replayable, numNumbers); err != nil {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("distribution", err.Error()))
+	}
+	algorithm := checksum.Negotiate(request.ChecksumAlgorithms, distribution.ValueType(request.Distribution))
+	s = newState(sourceName, seed, request.Distribution, numNumbers, algorithm)
+	s.serverSeed, s.seedNonce = serverSeed, seedNonce
+	if duration > 0 {
+		s.deadline = time.Now().Add(duration)
+	}
+	s.epoch = nextEpoch(0)
 
-		// Did the client provide a seed?
-		var seed uint64
-		if request.Seed > 0 {
-			seed = uint64(request.Seed)
-		} else {
-			seed = uint64(rand.Uint32())
+	fmt.Printf("sending %d numbers to clientID=%s source=%s seed=%x distribution=%s duration=%s\n", numNumbers, clientID, sourceName, []byte(seed), distribution.String(request.Distribution), duration)
+
+	return s, nil
+}
+
+// streamBounds returns the length and duration of the new stream request asks for, clamped to the configured
+// limits. A stream that isn't bounded by num_numbers is given a length of UNBOUNDED_NUMBERS, and a duration of 0
+// means the stream has no deadline.
+func (ns *numberServer) streamBounds(request *protocol.NumbersRequest) (uint32, time.Duration, error) {
+	if request.Unbounded && (request.NumNumbers > 0 || request.DurationMs > 0) {
+		return 0, 0, badRequest(codes.InvalidArgument, fieldViolation("unbounded", "cannot be combined with num_numbers or duration_ms"))
+	}
+	if request.NumNumbers == 0 && request.DurationMs == 0 && !request.Unbounded {
+		return 0, 0, badRequest(codes.InvalidArgument, fieldViolation("num_numbers", "cannot send 0 numbers, set duration_ms or unbounded for a stream without a length"))
+	}
+
+	numNumbers := request.NumNumbers
+	if numNumbers == 0 {
+		numNumbers = UNBOUNDED_NUMBERS
+	}
+	if ns.config.maxNumbers > 0 && numNumbers > ns.config.maxNumbers {
+		numNumbers = ns.config.maxNumbers
+	}
+	duration := time.Duration(request.DurationMs) * time.Millisecond
+	if ns.config.maxDuration > 0 && (duration == 0 || duration > ns.config.maxDuration) {
+		duration = ns.config.maxDuration
+	}
+
+	return numNumbers, duration, nil
+}
+
+// stateFromToken returns the state to resume request from, taken from its resume token. As with a stored state the
+// client is the authority on what it has received, so the stream continues from request.LastIndex, which can't be
+// past the position the token was issued at.
+func (ns *numberServer) stateFromToken(clientID uuid.UUID, request *protocol.NumbersRequest) (*State, error) {
+	if ns.config.resumeTokens == nil {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("resume_token", "this server doesn't accept resume tokens"))
+	}
+
+	token, err := ns.config.resumeTokens.open(request.ResumeToken, time.Now())
+	if errors.Is(err, errResumeTokenExpired) {
+		return nil, status.Error(codes.FailedPrecondition, "clientID has expired and cannot be reused")
+	} else if err != nil {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("resume_token", err.Error()))
+	}
+	if token.clientID != clientID {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("resume_token", "was issued for a different client_id"))
+	}
+	if request.LastIndex > token.position {
+		return nil, badRequest(codes.OutOfRange, fieldViolation("last_index",
+			fmt.Sprintf("cannot resume from index %d, the resume token was issued at index %d", request.LastIndex, token.position)))
+	}
+
+	s := newState(token.source, token.seed, token.distribution, token.totalNumbers, token.algorithm)
+	s.serverSeed, s.seedNonce = token.serverSeed, token.seedNonce
+	s.deadline = token.deadline
+	if token.checkpoint.index > 0 {
+		s.checkpoints = []issuedCheckpoint{token.checkpoint}
+	}
+	if len(token.hashState) > 0 {
+		s.anchors = []hashAnchor{{numbersSent: token.position, hashState: token.hashState}}
+	}
+	s = s.rewind(request.LastIndex)
+	s.epoch = nextEpoch(0)
+	if err := checkCheckpoint(s, request); err != nil {
+		return nil, err
+	}
+	fmt.Printf("resuming clientID=%s from a resume token at index %d\n", clientID, request.LastIndex)
+
+	return s, nil
+}
+
+// storageStatus converts an error returned by the StateStorage into the status the client is sent.
+func storageStatus(err error) error {
+	switch {
+	case errors.Is(err, ErrExpired):
+		return status.Error(codes.FailedPrecondition, "clientID has expired and cannot be reused")
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, "no stream found for clientID")
+	case errors.Is(err, ErrFenced):
+		return status.Error(codes.Aborted, "stream was superseded by a newer stream for clientID")
+	case errors.Is(err, ErrCorrupt):
+		return status.Error(codes.DataLoss, "stored state for clientID can't be decoded, so its stream can't be resumed")
+	case errors.Is(err, ErrUnavailable):
+		return retryableError(codes.Unavailable, UNAVAILABLE_RETRY_DELAY, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+
+	return status.Errorf(codes.Internal, "unable to access client state: %s", err)
+}
+
+// numberStream is the sending half of the GetNumbers and GetAckedNumbers streams.
+type numberStream interface {
+	Send(*protocol.NumberResponse) error
+}
+
+// responseStream returns stream, wrapped to send the session ID in the first NumberResponse if the server issued
+// it, to attach resume tokens for the sequence in s if the server issues them, to commit to and reveal the seed if
+// the server picked it, to sign the final NumberResponse if the server has a signing key, to attach Merkle proofs
+// if the client asked for them, and to report the sequence's source, generator and distribution.
+func (ns *numberServer) responseStream(stream numberStream, clientID uuid.UUID, issued bool, s *State, e emission) (numberStream, error) {
+	if e.merkleChunkSize > 0 && !s.source.Replayable() {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("merkle_chunk_size",
+			fmt.Sprintf("%s numbers can't be generated ahead of time, so they have no Merkle proofs", s.sourceName)))
+	}
+	if e.merkleChunkSize > 0 && (s.totalNumbers == UNBOUNDED_NUMBERS || !s.deadline.IsZero()) {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("merkle_chunk_size",
+			"the length of a stream bounded by its duration, or not at all, isn't known ahead of time, so it has no Merkle proofs"))
+	}
+	if e.merkleChunkSize > 0 && s.values.Distribution() != nil {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("merkle_chunk_size",
+			fmt.Sprintf("Merkle proofs are only sent for %s values", protocol.ValueType_UINT32)))
+	}
+	if e.merkleChunkSize > 0 && s.totalNumbers > ns.config.maxMerkleNumbers {
+		return nil, badRequest(codes.InvalidArgument, fieldViolation("merkle_chunk_size",
+			fmt.Sprintf("Merkle proofs are only sent for sequences of at most %d numbers, this one has %d", ns.config.maxMerkleNumbers, s.totalNumbers)))
+	}
+	if e.merkleChunkSize > 0 {
+		tree, err := merkle.Build(s.totalNumbers, e.merkleChunkSize, s.replay().Uint32)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to build the sequence's Merkle tree: %s", err)
+		}
+		stream = &merkleStream{numberStream: stream, tree: tree, sendRoot: true}
+	}
+	// A stream whose numbers can't be generated again can't be resumed from a token.
+	if ns.config.resumeTokens != nil && s.source.Replayable() {
+		var lastCheckpoint issuedCheckpoint
+		if len(s.checkpoints) > 0 {
+			lastCheckpoint = s.checkpoints[len(s.checkpoints)-1]
+		}
+		stream = &tokenStream{
+			numberStream: stream,
+			tokens:       ns.config.resumeTokens,
+			interval:     ns.config.resumeTokenInterval,
+			state:        s,
+			token: resumeToken{
+				clientID:     clientID,
+				source:       s.sourceName,
+				seed:         s.seed,
+				distribution: s.values.Distribution(),
+				totalNumbers: s.totalNumbers,
+				deadline:     s.deadline,
+				algorithm:    s.hash.Algorithm(),
+				serverSeed:   s.serverSeed,
+				seedNonce:    s.seedNonce,
+				checkpoint:   lastCheckpoint,
+			},
+		}
+	}
+	if s.serverSeed != nil {
+		stream = &commitStream{
+			numberStream: stream,
+			commitment:   fairness.Commitment(s.serverSeed, s.seedNonce),
+			serverSeed:   s.serverSeed,
+			seedNonce:    s.seedNonce,
+			first:        true,
+		}
+	}
+	if ns.config.signingKey != nil {
+		stream = &signingStream{
+			numberStream: stream,
+			key:          ns.config.signingKey,
+			statement: signing.Statement{
+				ClientID:       clientID,
+				SeedCommitment: seedCommitment(s),
+			},
 		}
-		s.prng.Seed(seed)
+	}
+	stream = &generatorStream{
+		numberStream: stream,
+		source:       s.sourceName,
+		algorithm:    s.definition().generator,
+		seedBits:     uint32(s.seed.Bits()),
+		distribution: s.values.Distribution(),
+		first:        true,
+	}
+	if issued {
+		stream = &issuedSessionStream{numberStream: stream, sessionID: clientID[:]}
+	}
+
+	return stream, nil
+}
+
+type issuedSessionStream struct {
+	numberStream
+	// sessionID is cleared once it has been sent.
+	sessionID []byte
+}
+
+func (s *issuedSessionStream) Send(response *protocol.NumberResponse) error {
+	response.SessionId, s.sessionID = s.sessionID, nil
+
+	return s.numberStream.Send(response)
+}
 
-		fmt.Printf("sending %d numbers to clientID=%s seed=%d\n", numNumbers, clientID, seed)
+// generatorStream reports the source of the sequence, its generator, the width of its seed and the distribution its
+// values are drawn from on the first NumberResponse.
+type generatorStream struct {
+	numberStream
+	source       string
+	algorithm    protocol.PrngAlgorithm
+	seedBits     uint32
+	distribution *protocol.Distribution
+	// first is cleared once the first NumberResponse has been sent.
+	first bool
+}
 
-		s.nextNumber = s.prng.Uint32()
-		io.WriteString(s.hash, fmt.Sprintf("%d", s.nextNumber))
+func (gs *generatorStream) Send(response *protocol.NumberResponse) error {
+	if gs.first {
+		response.Source = gs.source
+		response.PrngAlgorithm = gs.algorithm
+		response.SeedBits = gs.seedBits
+		response.Distribution = gs.distribution
+		gs.first = false
 	}
 
-	// Executes loop body every 1 second.
-	for range time.Tick(time.Second * 1) {
+	return gs.numberStream.Send(response)
+}
+
+// streamHooks let the caller of sendNumbers record a client's progress. Any of them may be nil.
+type streamHooks struct {
+	// ready is called before each number and may block until the number can be sent.
+	ready func(ctx context.Context) error
+	// sending is called just before a NumberResponse is sent, with the index of the last number in it.
+	sending func(index uint32, isLast bool) error
+	// sent is called once a NumberResponse has been sent, with the index of the last number in it.
+	sent func(index uint32, isLast bool) error
+}
 
-		isLastPayload := s.totalNumbers == s.numbersSent+1
-		var payload *protocol.NumberResponse
+// sendNumbers paces the rest of the sequence in s out over stream, one number per interval and, in batch mode,
+// several numbers per NumberResponse. s is advanced as numbers are taken from it, so when the sending and sent
+// hooks are called it is positioned after the last number in the NumberResponse.
+func (ns *numberServer) sendNumbers(
+	ctx context.Context,
+	stream numberStream,
+	s *State,
+	e emission,
+	hooks streamHooks,
+) error {
+	batch := make([]uint64, 0, e.batchSize)
+	var batchStarted time.Time
+	checkpoints := newCheckpointer(e)
 
-		if isLastPayload {
-			checksum := hex.EncodeToString(s.hash.Sum(nil)[:])
-			payload = &protocol.NumberResponse{
-				Number:   s.nextNumber,
-				Checksum: checksum,
+	// Executes loop body once per interval.
+	p := newPacer(ns.scheduler, e.interval)
+	for {
+		if hooks.ready != nil {
+			if err := hooks.ready(ctx); err != nil {
+				return err
+			}
+		}
+
+		if err := p.wait(ctx); err != nil {
+			return err
+		}
+
+		index := s.numbersSent + 1
+		// A number the source failed to produce is never sent, and the stream can't go on without it.
+		if err := s.sourceErr(); err != nil {
+			return status.Errorf(codes.Unavailable, "unable to take the number at index %d from source %s: %s", index, s.sourceName, err)
+		}
+		isLastPayload := s.totalNumbers == index || s.pastDeadline(time.Now())
+
+		if len(batch) == 0 {
+			batchStarted = time.Now()
+		}
+		batch = append(batch, s.nextValue)
+
+		// A batch is sent early if waiting for the next number would hold it past its flush interval.
+		flush := isLastPayload || uint32(len(batch)) >= e.batchSize ||
+			(e.flushInterval > 0 && time.Since(batchStarted)+e.interval > e.flushInterval)
+
+		// The hash already includes the number at index, and moves on to the next one as s advances.
+		var link []byte
+		var checkpoint *protocol.Checkpoint
+		if flush {
+			link = s.hash.Link()
+			if checkpoints.due(time.Now()) && !isLastPayload {
+				checkpoint = &protocol.Checkpoint{Index: index, Checksum: s.hash.Sum()}
+				s.recordCheckpoint(index, checkpoint.Checksum)
 			}
+		}
+
+		sum := ""
+		if isLastPayload {
+			sum = s.hash.Sum()
 		} else {
-			payload = &protocol.NumberResponse{
-				Number:   s.nextNumber,
-				Checksum: "",
+			s.advance()
+		}
+
+		if !flush {
+			continue
+		}
+
+		payload := &protocol.NumberResponse{
+			Checksum:          sum,
+			Index:             index + 1 - uint32(len(batch)),
+			ChecksumAlgorithm: s.hash.Algorithm(),
+			Chain:             link,
+			Checkpoint:        checkpoint,
+		}
+		distribution.SetValues(payload, s.hash.ValueType(), batch, e.batchSize > 1)
+
+		if hooks.sending != nil {
+			if err := hooks.sending(index, isLastPayload); err != nil {
+				return err
 			}
 		}
 
@@ -107,22 +650,78 @@ func (ns *numberServer) GetNumbers(request *protocol.NumbersRequest, stream prot
 		if errors.Is(err, io.EOF) {
 			return nil
 		} else if err != nil {
-			return fmt.Errorf("failed to send number: %s\n", payload)
+			return status.Errorf(codes.Unavailable, "failed to send number at index %d: %s", payload.Index, err)
 		}
 
-		if isLastPayload {
-			ns.stateStorage.DeleteState(clientID)
+		// Numbers successfully sent, the caller can record that
+		if hooks.sent != nil {
+			if err := hooks.sent(index, isLastPayload); err != nil {
+				return err
+			}
+		}
 
+		if isLastPayload {
 			return nil
 		}
 
-		// Number successfully sent, can update and store state
-		s.nextNumber = s.prng.Uint32()
-		s.numbersSent++
-		s.lastUpdated = time.Now()
-		io.WriteString(s.hash, fmt.Sprintf("%d", s.nextNumber))
-		ns.stateStorage.SetState(clientID, s)
+		batch = batch[:0]
+	}
+}
+
+// emission holds how a stream's numbers are paced and packed into NumberResponses.
+type emission struct {
+	interval      time.Duration
+	batchSize     uint32
+	flushInterval time.Duration
+	// merkleChunkSize is the size of the chunks Merkle proofs are sent for, 0 when the client didn't ask for them.
+	merkleChunkSize uint32
+	// checkpointMessages and checkpointInterval are how often the stream sends checkpoints, 0 leaves either out.
+	checkpointMessages uint32
+	checkpointInterval time.Duration
+}
+
+// emissionSettings works out the pacing and batching requested by the client, clamped to the configured limits.
+func (ns *numberServer) emissionSettings(request *protocol.NumbersRequest) (emission, error) {
+	interval := DEFAULT_INTERVAL
+
+	if request.Rate > 0 && request.IntervalMs > 0 {
+		return emission{}, badRequest(codes.InvalidArgument,
+			fieldViolation("rate", "only one of rate and interval_ms may be specified"),
+			fieldViolation("interval_ms", "only one of rate and interval_ms may be specified"),
+		)
+	}
+	if request.MerkleChunkSize > 0 && (!merkle.ValidChunkSize(request.MerkleChunkSize) || request.MerkleChunkSize < MIN_MERKLE_CHUNK_SIZE) {
+		return emission{}, badRequest(codes.InvalidArgument, fieldViolation("merkle_chunk_size",
+			fmt.Sprintf("must be a power of two of at least %d, got %d", MIN_MERKLE_CHUNK_SIZE, request.MerkleChunkSize)))
+	}
+	if request.Rate > 0 {
+		interval = time.Second / time.Duration(request.Rate)
+	}
+	if request.IntervalMs > 0 {
+		interval = time.Duration(request.IntervalMs) * time.Millisecond
+	}
+
+	if interval < ns.config.minInterval {
+		interval = ns.config.minInterval
+	}
+	if interval > ns.config.maxInterval {
+		interval = ns.config.maxInterval
+	}
+
+	batchSize := request.BatchSize
+	if batchSize == 0 {
+		batchSize = 1
+	}
+	if batchSize > ns.config.maxBatchSize {
+		batchSize = ns.config.maxBatchSize
 	}
 
-	return nil
+	return emission{
+		interval:           interval,
+		batchSize:          batchSize,
+		flushInterval:      time.Duration(request.FlushIntervalMs) * time.Millisecond,
+		merkleChunkSize:    request.MerkleChunkSize,
+		checkpointMessages: request.CheckpointMessages,
+		checkpointInterval: time.Duration(request.CheckpointIntervalMs) * time.Millisecond,
+	}, nil
 }
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+	"github.com/jamesrobb/ably-takehome/signing"
+)
+
+// MAX_SOURCE_NAME is the longest name a source may be registered under.
+const MAX_SOURCE_NAME = 64
+
+// NumberSource is where the numbers of a sequence come from. Every generator is a NumberSource, and the server can
+// be configured with more, such as a recording to replay.
+type NumberSource interface {
+	// Uint32 returns the next number.
+	Uint32() uint32
+	// Skip moves the source past the next n numbers. Numbers that can't be generated again are simply never
+	// generated, so skipping them does nothing.
+	Skip(n uint32)
+	// Replayable reports whether a source created the same way generates the same numbers again. The numbers of a
+	// source that doesn't can't be rewound to, acknowledged, proved with Merkle proofs or resumed from a resume
+	// token.
+	Replayable() bool
+	// MarshalBinary returns the cursor the source needs to carry on from where it is, which UnmarshalBinary
+	// restores into a source created the same way.
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(data []byte) error
+}
+
+// failingSource is a NumberSource that can fail to produce numbers, such as a device that can no longer be read.
+// Once Err returns an error the numbers the source returns are meaningless, so none of them may be sent.
+type failingSource interface {
+	Err() error
+}
+
+// sourceKind is the kind of a registered source.
+type sourceKind string
+
+const (
+	// SOURCE_GENERATOR is one of the generators, which are registered under their command line names.
+	SOURCE_GENERATOR sourceKind = "generator"
+	// SOURCE_DEVICE reads numbers from a file that never runs out, such as /dev/urandom.
+	SOURCE_DEVICE sourceKind = "device"
+	// SOURCE_REPLAY replays a recorded sequence.
+	SOURCE_REPLAY sourceKind = "replay"
+	// SOURCE_SCRIPT emits a fixed list of numbers over and over, for testing.
+	SOURCE_SCRIPT sourceKind = "script"
+)
+
+// sourceDefinition is a registered source, which creates the NumberSource of every sequence taken from it.
+type sourceDefinition struct {
+	kind sourceKind
+	// generator is the generator of a SOURCE_GENERATOR source, which is reported to clients as prng_algorithm.
+	generator  protocol.PrngAlgorithm
+	replayable bool
+	// length is the number of numbers the source has, 0 for a source that never runs out.
+	length uint32
+	// new returns a source positioned at the start of the sequence it generates from seed, which checkSeed must
+	// accept.
+	new func(seed generator.Seed) (NumberSource, error)
+	// commitment identifies the sequence generated from seed in the statement the server signs.
+	commitment func(seed generator.Seed) []byte
+}
+
+// seeded reports whether sequences taken from the source have a seed, which only generators do.
+func (d *sourceDefinition) seeded() bool {
+	return d.kind == SOURCE_GENERATOR && generator.Replayable(d.generator)
+}
+
+// checkSeed returns an error unless a sequence can be taken from the source with seed.
+func (d *sourceDefinition) checkSeed(seed generator.Seed) error {
+	if d.kind == SOURCE_GENERATOR {
+		return generator.CheckSeed(d.generator, seed)
+	}
+	if len(seed) > 0 {
+		return errors.New("only a generator can be seeded")
+	}
+
+	return nil
+}
+
+// numberSources are the sources a sequence can be taken from, by name. Every generator is registered under its
+// command line name, and the server registers the sources it is configured with before it serves. A stored state
+// names its source, so it can only be restored by a server that registered the source under the same name.
+var numberSources = generatorSources()
+
+func generatorSources() map[string]*sourceDefinition {
+	sources := make(map[string]*sourceDefinition)
+	for value := range protocol.PrngAlgorithm_name {
+		algorithm := protocol.PrngAlgorithm(value)
+		if !generator.Supported(algorithm) {
+			continue
+		}
+
+		sources[generator.Name(algorithm)] = &sourceDefinition{
+			kind:       SOURCE_GENERATOR,
+			generator:  algorithm,
+			replayable: generator.Replayable(algorithm),
+			new: func(seed generator.Seed) (NumberSource, error) {
+				p, err := generator.New(algorithm, seed)
+				if err != nil {
+					return nil, err
+				}
+
+				return p, nil
+			},
+			commitment: func(seed generator.Seed) []byte {
+				return signing.SeedCommitment(algorithm, seed)
+			},
+		}
+	}
+
+	return sources
+}
+
+// registerSource registers the source spec describes, given as <name>=<kind>:<argument> with one of the kinds:
+//
+//   - device:PATH reads each number from the file at PATH as 4 bytes in big-endian order. The file must never run
+//     out, like /dev/urandom or the device of a hardware generator, and what was read from it can't be read again.
+//   - replay:PATH replays the sequence recorded in the file at PATH, one decimal number per line as the client
+//     prints them. A sequence taken from it can be no longer than the recording.
+//   - script:NUMBERS emits the space separated NUMBERS, starting again from the first once they run out.
+func registerSource(spec string) error {
+	name, definition, ok := strings.Cut(spec, "=")
+	kind, argument, hasArgument := strings.Cut(definition, ":")
+	if !ok || !hasArgument {
+		return fmt.Errorf("malformed source %q, expected <name>=<kind>:<argument>", spec)
+	}
+	if name == "" || len(name) > MAX_SOURCE_NAME || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("source name %q must be 1 to %d characters without spaces", name, MAX_SOURCE_NAME)
+	}
+	if _, ok := numberSources[name]; ok {
+		return fmt.Errorf("source %q is already registered", name)
+	}
+
+	var source *sourceDefinition
+	var err error
+	switch sourceKind(kind) {
+	case SOURCE_DEVICE:
+		source, err = deviceSource(name, argument)
+	case SOURCE_REPLAY:
+		var numbers []uint32
+		numbers, err = readRecording(argument)
+		if err == nil {
+			source = listSourceDefinition(name, SOURCE_REPLAY, numbers)
+		}
+	case SOURCE_SCRIPT:
+		var numbers []uint32
+		numbers, err = parseScript(argument)
+		if err == nil {
+			source = listSourceDefinition(name, SOURCE_SCRIPT, numbers)
+		}
+	default:
+		return fmt.Errorf("unknown source kind %q, expected one of device, replay or script", kind)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to register source %q: %s", name, err)
+	}
+	numberSources[name] = source
+
+	return nil
+}
+
+// sourceFlags collects the specs of every -source flag, each of which registerSource registers.
+type sourceFlags []string
+
+func (f *sourceFlags) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *sourceFlags) Set(spec string) error {
+	*f = append(*f, spec)
+
+	return nil
+}
+
+// requestedSource returns the name and definition of the source request asks for, which is its source or else its
+// generator.
+func requestedSource(request *protocol.NumbersRequest) (string, *sourceDefinition, error) {
+	if request.Source == "" {
+		if !generator.Supported(request.PrngAlgorithm) {
+			return "", nil, badRequest(codes.InvalidArgument, fieldViolation("prng_algorithm", fmt.Sprintf("unsupported PRNG %s", request.PrngAlgorithm)))
+		}
+		name := generator.Name(request.PrngAlgorithm)
+
+		return name, numberSources[name], nil
+	}
+
+	if request.PrngAlgorithm != protocol.PrngAlgorithm_MT19937 {
+		return "", nil, badRequest(codes.InvalidArgument,
+			fieldViolation("source", "only one of source and prng_algorithm may be specified"),
+			fieldViolation("prng_algorithm", "only one of source and prng_algorithm may be specified"),
+		)
+	}
+	source, ok := numberSources[request.Source]
+	if !ok {
+		return "", nil, badRequest(codes.InvalidArgument, fieldViolation("source", fmt.Sprintf("unknown source %q", request.Source)))
+	}
+
+	return request.Source, source, nil
+}
+
+// sourceField returns the field of request that names its source.
+func sourceField(request *protocol.NumbersRequest) string {
+	if request.Source != "" {
+		return "source"
+	}
+
+	return "prng_algorithm"
+}
+
+// deviceFile is a file that never runs out, which every sequence taken from it reads from. Nothing read from it can
+// be read again.
+type deviceFile struct {
+	path string
+
+	lock sync.Mutex
+	r    *bufio.Reader
+}
+
+func (f *deviceFile) read() (uint32, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var b [4]byte
+	if _, err := io.ReadFull(f.r, b[:]); err != nil {
+		return 0, fmt.Errorf("unable to read from %s: %s", f.path, err)
+	}
+
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+// deviceReader is a sequence read from a device file, which has no cursor. A read that fails is recorded rather than
+// retried, as the sequence can't go on without the number it lost, and ends the stream.
+type deviceReader struct {
+	file *deviceFile
+	err  error
+}
+
+func deviceSource(name string, path string) (*sourceDefinition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &deviceFile{path: path, r: bufio.NewReader(f)}
+	if _, err := d.r.Peek(4); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to read from %s: %s", path, err)
+	}
+
+	return &sourceDefinition{
+		kind: SOURCE_DEVICE,
+		new: func(seed generator.Seed) (NumberSource, error) {
+			return &deviceReader{file: d}, nil
+		},
+		commitment: func(seed generator.Seed) []byte {
+			return signing.SourceCommitment(name, nil)
+		},
+	}, nil
+}
+
+func (d *deviceReader) Uint32() uint32 {
+	if d.err != nil {
+		return 0
+	}
+
+	n, err := d.file.read()
+	d.err = err
+
+	return n
+}
+
+func (d *deviceReader) Err() error {
+	return d.err
+}
+
+func (d *deviceReader) Skip(n uint32) {}
+
+func (d *deviceReader) Replayable() bool {
+	return false
+}
+
+func (d *deviceReader) MarshalBinary() ([]byte, error) {
+	return nil, nil
+}
+
+func (d *deviceReader) UnmarshalBinary(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("%s has no cursor, got %d bytes", d.file.path, len(data))
+	}
+
+	return nil
+}
+
+// listSource emits a list of numbers from the first on. A script starts again from its first number once it runs
+// out, while a recording gives zeros past its end, which are never sent as a sequence is no longer than its
+// recording.
+type listSource struct {
+	numbers []uint32
+	cycle   bool
+	// position is the number of numbers emitted so far, which is the source's cursor.
+	position uint64
+}
+
+func listSourceDefinition(name string, kind sourceKind, numbers []uint32) *sourceDefinition {
+	// The numbers identify the sequence, so the statement the server signs commits to them.
+	h := sha256.New()
+	h.Write([]byte(kind))
+	for _, n := range numbers {
+		h.Write(binary.BigEndian.AppendUint32(nil, n))
+	}
+	content := h.Sum(nil)
+
+	d := &sourceDefinition{
+		kind:       kind,
+		replayable: true,
+		new: func(seed generator.Seed) (NumberSource, error) {
+			return &listSource{numbers: numbers, cycle: kind == SOURCE_SCRIPT}, nil
+		},
+		commitment: func(seed generator.Seed) []byte {
+			return signing.SourceCommitment(name, content)
+		},
+	}
+	if kind == SOURCE_REPLAY {
+		d.length = uint32(len(numbers))
+	}
+
+	return d
+}
+
+func (l *listSource) Uint32() uint32 {
+	position := l.position
+	l.position++
+	if l.cycle {
+		position %= uint64(len(l.numbers))
+	}
+	if position >= uint64(len(l.numbers)) {
+		return 0
+	}
+
+	return l.numbers[position]
+}
+
+func (l *listSource) Skip(n uint32) {
+	l.position += uint64(n)
+}
+
+func (l *listSource) Replayable() bool {
+	return true
+}
+
+// MarshalBinary encodes the position as a uint64 in big-endian order.
+func (l *listSource) MarshalBinary() ([]byte, error) {
+	return binary.BigEndian.AppendUint64(nil, l.position), nil
+}
+
+func (l *listSource) UnmarshalBinary(data []byte) error {
+	if len(data) != 8 {
+		return fmt.Errorf("list source cursor must be 8 bytes, got %d", len(data))
+	}
+	l.position = binary.BigEndian.Uint64(data)
+
+	return nil
+}
+
+// readRecording reads a recorded sequence, one decimal number per line. Blank lines are skipped.
+func readRecording(path string) ([]uint32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var numbers []uint32
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(text, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of %s is %q, which isn't a uint32", line, path, text)
+		}
+		numbers = append(numbers, uint32(n))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("%s has no numbers recorded", path)
+	}
+
+	return numbers, nil
+}
+
+// parseScript parses a script of space separated decimal numbers.
+func parseScript(script string) ([]uint32, error) {
+	var numbers []uint32
+	for _, field := range strings.Fields(script) {
+		n, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("script has %q, which isn't a uint32", field)
+		}
+		numbers = append(numbers, uint32(n))
+	}
+	if len(numbers) == 0 {
+		return nil, errors.New("script has no numbers")
+	}
+
+	return numbers, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// pacer hands out emission slots at a fixed interval. Slots are anchored to the start of the stream rather than to
+// the previous send, so time spent in stream.Send does not accumulate as drift. A stream that falls a whole interval
+// or more behind, because of a slow stream.Send for instance, is re-anchored rather than sent the missed slots
+// back-to-back.
+type pacer struct {
+	sch      *scheduler
+	w        *waiter
+	interval time.Duration
+	next     time.Time
+}
+
+func newPacer(sch *scheduler, interval time.Duration) *pacer {
+	return &pacer{
+		sch:      sch,
+		w:        newWaiter(),
+		interval: interval,
+		next:     time.Now().Add(interval),
+	}
+}
+
+// wait blocks until the next emission slot. It returns early with the context's error if ctx is done first.
+func (p *pacer) wait(ctx context.Context) error {
+	now := time.Now()
+	delay := p.next.Sub(now)
+
+	if delay > 0 {
+		if err := p.sch.wait(ctx, p.w, p.next); err != nil {
+			return err
+		}
+	} else if -delay >= p.interval {
+		// At least one slot was missed entirely. Skip it instead of bursting.
+		p.next = now
+	}
+
+	p.next = p.next.Add(p.interval)
+
+	return nil
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// REDIS_TIMEOUT bounds each round trip to the Redis protocol server.
+const REDIS_TIMEOUT = 5 * time.Second
+
+// REDIS_TRANSACTION_ATTEMPTS is how many times a write is tried when the state it checked the epoch of keeps being
+// changed before the write can be made.
+const REDIS_TRANSACTION_ATTEMPTS = 10
+
+// REDIS_TRANSACTION_BACKOFF is the most a write waits before its second attempt, it doubles with each attempt after.
+const REDIS_TRANSACTION_BACKOFF = time.Millisecond
+
+type RedisStorageConfig struct {
+	Addr string
+	// KeyPrefix namespaces every key the storage uses, so several servers can share a database with other data.
+	KeyPrefix string
+	// TombstoneTTL is how long an expired client ID is remembered once its state has expired.
+	TombstoneTTL time.Duration
+	// PoolSize is the most connections kept open to the server.
+	PoolSize int
+	// Timeout bounds dialling and each round trip.
+	Timeout time.Duration
+	// Clock defaults to the system clock.
+	Clock Clock
+}
+
+// RedisStorage is a StateStorage backed by a server that speaks the Redis protocol (RESP), so that several
+// replicas of the number server can share client state.
+//
+// A client's encoded state is kept under "<prefix>state:<client ID>" with a native TTL of GARBGAGE_TIMEOUT, so it
+// expires without any sweeping. Whenever the state is stored, a tombstone is also written under
+// "<prefix>expired:<client ID>" holding the time at which that state will expire. A client ID without a state whose
+// tombstone time has passed is expired. Deleting the state deletes the tombstone too, and tombstones have their own
+// TTL so that the expired client IDs aren't kept forever.
+//
+// Writes are fenced by epoch with an optimistic transaction: the state is WATCHed while its epoch is checked, and the
+// write is retried if the state changes before it is made.
+type RedisStorage struct {
+	config RedisStorageConfig
+	// pool holds idle connections.
+	pool chan *respConn
+}
+
+func NewRedisStorage(config RedisStorageConfig) (*RedisStorage, error) {
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	rs := &RedisStorage{
+		config: config,
+		pool:   make(chan *respConn, config.PoolSize),
+	}
+
+	// Fail at startup, rather than on the first request, if the server can't be reached.
+	_, err := rs.do(context.Background(), []string{"PING"})
+	if err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+func (rs *RedisStorage) stateKey(clientID uuid.UUID) string {
+	return rs.config.KeyPrefix + "state:" + clientID.String()
+}
+
+func (rs *RedisStorage) tombstoneKey(clientID uuid.UUID) string {
+	return rs.config.KeyPrefix + "expired:" + clientID.String()
+}
+
+func (rs *RedisStorage) GetState(ctx context.Context, clientID uuid.UUID) (*State, error) {
+	replies, err := rs.do(ctx,
+		[]string{"GET", rs.stateKey(clientID)},
+		[]string{"GET", rs.tombstoneKey(clientID)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if replies[0] == nil {
+		if replies[1] == nil {
+			return nil, ErrNotFound
+		}
+
+		expiresAt, err := strconv.ParseInt(string(replies[1].([]byte)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed tombstone for clientID=%s: %s", clientID, err)
+		}
+		if rs.config.Clock.Now().UnixMilli() >= expiresAt {
+			return nil, ErrExpired
+		}
+
+		// The state was evicted before its TTL.
+		return nil, ErrNotFound
+	}
+
+	s := &State{}
+	err = s.UnmarshalBinary(replies[0].([]byte))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	return s, nil
+}
+
+func (rs *RedisStorage) SetState(ctx context.Context, clientID uuid.UUID, state *State) error {
+	data, err := state.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := state.lastUpdated.Add(GARBGAGE_TIMEOUT)
+	stateTTL := expiresAt.Sub(rs.config.Clock.Now()).Milliseconds()
+	if stateTTL < 1 {
+		stateTTL = 1
+	}
+	tombstoneTTL := stateTTL + rs.config.TombstoneTTL.Milliseconds()
+
+	return rs.fencedExec(ctx, clientID, state.epoch, true,
+		[]string{"SET", rs.stateKey(clientID), string(data), "PX", strconv.FormatInt(stateTTL, 10)},
+		[]string{"SET", rs.tombstoneKey(clientID), strconv.FormatInt(expiresAt.UnixMilli(), 10), "PX", strconv.FormatInt(tombstoneTTL, 10)},
+	)
+}
+
+func (rs *RedisStorage) DeleteState(ctx context.Context, clientID uuid.UUID, epoch uint64) error {
+	return rs.fencedExec(ctx, clientID, epoch, false, []string{"DEL", rs.stateKey(clientID), rs.tombstoneKey(clientID)})
+}
+
+// fencedExec runs commands in a transaction, provided the client's stored state doesn't belong to an epoch newer
+// than epoch. It returns ErrFenced if it does. If refuseExpired is set, it also returns ErrExpired if the client has
+// expired, so that an expired client ID can't be brought back by storing its state again.
+func (rs *RedisStorage) fencedExec(ctx context.Context, clientID uuid.UUID, epoch uint64, refuseExpired bool, commands ...[]string) error {
+	key := rs.stateKey(clientID)
+	tombstoneKey := rs.tombstoneKey(clientID)
+
+	for attempt := 0; attempt < REDIS_TRANSACTION_ATTEMPTS; attempt++ {
+		if attempt > 0 {
+			// Back off for a random time, so that writers racing for the state don't keep colliding.
+			backoff := time.NewTimer(time.Duration(rand.Int63n(int64(REDIS_TRANSACTION_BACKOFF << (attempt - 1)))))
+			select {
+			case <-ctx.Done():
+				backoff.Stop()
+				return ctx.Err()
+			case <-backoff.C:
+			}
+		}
+
+		committed := false
+		err := rs.withConn(ctx, func(conn *respConn, deadline time.Time) (err error) {
+			// The connection goes back to the pool, so it mustn't be left watching the key or the next transaction
+			// on it could be aborted by a change to this client's state. EXEC unwatches, but every other way out
+			// has to, and a connection that can't be unwatched is closed instead.
+			defer func() {
+				if err != nil && !conn.broken {
+					if _, unwatchErr := conn.do([][]string{{"UNWATCH"}}, deadline); unwatchErr != nil {
+						conn.broken = true
+					}
+				}
+			}()
+
+			replies, err := conn.do([][]string{{"WATCH", key, tombstoneKey}, {"GET", key}, {"GET", tombstoneKey}}, deadline)
+			if err != nil {
+				return err
+			}
+			if refuseExpired && replies[1] == nil && replies[2] != nil {
+				expiresAt, err := strconv.ParseInt(string(replies[2].([]byte)), 10, 64)
+				if err == nil && rs.config.Clock.Now().UnixMilli() >= expiresAt {
+					return ErrExpired
+				}
+			}
+
+			// Only the epoch is needed, which is read from the stored state's header rather than decoding all of it.
+			// A state whose header can't be read has no epoch to fence with, so it is overwritten rather than
+			// blocking the client's key until it expires.
+			if replies[1] != nil {
+				storedEpoch, _, err := decodeStateHeader(replies[1].([]byte))
+				if err == nil && storedEpoch > epoch {
+					return ErrFenced
+				}
+			}
+
+			transaction := append([][]string{{"MULTI"}}, commands...)
+			transaction = append(transaction, []string{"EXEC"})
+			replies, err = conn.do(transaction, deadline)
+			if err != nil {
+				return err
+			}
+
+			// EXEC replies with nil if the state changed after it was watched, and otherwise with the replies of the
+			// queued commands, any of which may have failed.
+			results, _ := replies[len(replies)-1].([]any)
+			for _, result := range results {
+				if replyErr, ok := result.(respError); ok {
+					return fmt.Errorf("%w: redis replied %s", ErrUnavailable, replyErr)
+				}
+			}
+			committed = results != nil
+
+			return nil
+		})
+		if err != nil || committed {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: state for clientID=%s kept changing", ErrUnavailable, clientID)
+}
+
+// do sends the commands in a single pipeline and returns their replies. A reply is nil, []byte, int64, string
+// (for simple strings), respError (for an error within an array) or []any. An error reply from the server
+// is returned as an error wrapping ErrUnavailable.
+func (rs *RedisStorage) do(ctx context.Context, commands ...[]string) ([]any, error) {
+	var replies []any
+	err := rs.withConn(ctx, func(conn *respConn, deadline time.Time) error {
+		var err error
+		replies, err = conn.do(commands, deadline)
+		return err
+	})
+
+	return replies, err
+}
+
+// withConn calls f with a connection from the pool, which f may make several round trips on before the deadline.
+// The deadline is the configured timeout from now, or ctx's deadline if that is sooner.
+func (rs *RedisStorage) withConn(ctx context.Context, f func(conn *respConn, deadline time.Time) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(rs.config.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	conn, err := rs.getConn(deadline)
+	if err != nil {
+		return err
+	}
+
+	err = f(conn, deadline)
+	if conn.broken {
+		// The connection is in an unknown state, don't reuse it.
+		conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("%w: %s", ErrUnavailable, err)
+	}
+	rs.putConn(conn)
+
+	return err
+}
+
+func (rs *RedisStorage) getConn(deadline time.Time) (*respConn, error) {
+	select {
+	case conn := <-rs.pool:
+		return conn, nil
+	default:
+	}
+
+	c, err := net.DialTimeout("tcp", rs.config.Addr, time.Until(deadline))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnavailable, err)
+	}
+
+	return &respConn{
+		Conn:   c,
+		reader: bufio.NewReader(c),
+		writer: bufio.NewWriter(c),
+	}, nil
+}
+
+func (rs *RedisStorage) putConn(conn *respConn) {
+	select {
+	case rs.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// Close closes the idle connections.
+func (rs *RedisStorage) Close() error {
+	for {
+		select {
+		case conn := <-rs.pool:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// respError is an error reply sent by the server.
+type respError string
+
+func (e respError) Error() string {
+	return string(e)
+}
+
+// respConn is a connection to a RESP server. It is not safe for concurrent use, the pool hands each connection
+// to one caller at a time.
+type respConn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	// broken is set once a round trip fails part way, after which the connection can't be used.
+	broken bool
+}
+
+func (c *respConn) do(commands [][]string, deadline time.Time) ([]any, error) {
+	c.SetDeadline(deadline)
+
+	for _, args := range commands {
+		fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := c.writer.Flush(); err != nil {
+		c.broken = true
+		return nil, err
+	}
+
+	// Every reply has to be read, even after an error reply, to leave the connection ready for reuse.
+	replies := make([]any, len(commands))
+	var firstErr error
+	for i := range commands {
+		reply, err := readRESP(c.reader)
+		var replyErr respError
+		if err != nil && !errors.As(err, &replyErr) {
+			c.broken = true
+			return nil, err
+		}
+		if err != nil && firstErr == nil {
+			// The server refusing a command leaves the state unread or unwritten, which the caller handles like
+			// the server being unreachable.
+			firstErr = fmt.Errorf("%w: redis replied %s", ErrUnavailable, err)
+		}
+		replies[i] = reply
+	}
+
+	return replies, firstErr
+}
+
+// readRESP reads a single RESP value.
+func readRESP(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed RESP line %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, respError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		// An error within an array, such as a failed command in a transaction, is kept as the element so that the
+		// rest of the array is still read.
+		values := make([]any, n)
+		for i := range values {
+			var replyErr respError
+			values[i], err = readRESP(r)
+			if errors.As(err, &replyErr) {
+				values[i] = replyErr
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("unknown RESP type %q", kind)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+	"github.com/jamesrobb/ably-takehome/storagetest"
+)
+
+func newFakeRedisStorage(t *testing.T) *RedisStorage {
+	lis, err := storagetest.StartFakeRedis("localhost:0", systemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	rs, err := NewRedisStorage(RedisStorageConfig{
+		Addr:         lis.Addr().String(),
+		KeyPrefix:    "test:",
+		TombstoneTTL: GARBGAGE_TIMEOUT,
+		PoolSize:     1,
+		Timeout:      REDIS_TIMEOUT,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rs.Close() })
+
+	return rs
+}
+
+// TestRedisStorageOverwritesUndecodableStates checks that a stored state too damaged to have an epoch doesn't fence
+// off its client's key.
+func TestRedisStorageOverwritesUndecodableStates(t *testing.T) {
+	rs := newFakeRedisStorage(t)
+	clientID := uuid.New()
+	if _, err := rs.do(context.Background(), []string{"SET", rs.stateKey(clientID), "\x00"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(1), nil, 10, protocol.ChecksumAlgorithm_SHA256)
+	if err := rs.SetState(context.Background(), clientID, s); err != nil {
+		t.Fatalf("SetState over an undecodable state = %v", err)
+	}
+	if _, err := rs.GetState(context.Background(), clientID); err != nil {
+		t.Errorf("GetState = %v, want the stored state", err)
+	}
+}
+
+// TestRedisStorageErrorReplies checks that a command the server refuses is reported as the storage being
+// unavailable.
+func TestRedisStorageErrorReplies(t *testing.T) {
+	rs := newFakeRedisStorage(t)
+	if _, err := rs.do(context.Background(), []string{"UNKNOWN"}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("do of an unknown command = %v, want ErrUnavailable", err)
+	}
+}
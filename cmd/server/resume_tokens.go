@@ -0,0 +1,354 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jamesrobb/ably-takehome/checksum"
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// resumeTokenVersion is the format version of the resume tokens the server issues, and the only one it accepts.
+// Version 1 tokens were signed with an HMAC rather than sealed, and can't be opened as version 2 tokens.
+const resumeTokenVersion = 2
+
+// A resume token is laid out as follows, with integers in big-endian order:
+//
+//	version        u8
+//	key ID length  u8
+//	key ID         [key ID length]byte
+//	nonce          [12]byte
+//	sealed fields  the fields below, sealed with AES-256-GCM under the key, with the version and key ID as
+//	               additional data
+//
+// The fields are:
+//
+//	client ID      [16]byte
+//	algorithm      u8, the protocol.ChecksumAlgorithm of the stream
+//	source         u8 length, followed by the name the stream's source is registered under
+//	seed           u8 length, followed by the seed
+//	distribution   17 bytes, as encoded by distribution.AppendBinary
+//	totalNumbers   u32
+//	position       u32
+//	issued         i64, unix milliseconds
+//	optional       the optional fields below, each a u8 tag followed by a u8 length prefixed value
+//
+// As in an encoded State, a field that not every stream has is an optional field, left out when it has its zero
+// value, so that adding one doesn't need a new version. The fields are sealed as a server seed must stay secret
+// until it is revealed with the checksum. Tokens only live for GARBGAGE_TIMEOUT, so when the version does change
+// none of the old version are left shortly after an upgrade.
+const resumeTokenFieldsSize = 16 + 1 + 1 + 1 + distribution.ENCODED_SIZE + 4 + 4 + 8
+
+// The tags of the optional fields of a resume token.
+const (
+	// TOKEN_SERVER_SEED is the server seed the seed was derived from, when the server picked the seed.
+	TOKEN_SERVER_SEED uint8 = 1
+	// TOKEN_SEED_NONCE is the nonce the server seed was committed to with.
+	TOKEN_SEED_NONCE uint8 = 2
+	// TOKEN_DEADLINE is the i64 deadline (unix milliseconds) of a stream bounded by its duration.
+	TOKEN_DEADLINE uint8 = 3
+	// TOKEN_CHECKPOINT is the last checkpoint sent before the token was issued, a u32 index followed by the
+	// checksum.
+	TOKEN_CHECKPOINT uint8 = 4
+	// TOKEN_HASH_STATE is the state of the stream's hash at position, including the number after it, as
+	// checksum.Hash.MarshalBinary returns it.
+	TOKEN_HASH_STATE uint8 = 5
+	// TOKEN_CHECKPOINT_HASH_STATE is the state of the stream's hash at TOKEN_CHECKPOINT.
+	TOKEN_CHECKPOINT_HASH_STATE uint8 = 6
+)
+
+// errResumeTokenExpired is returned for a genuine token that is older than GARBGAGE_TIMEOUT.
+var errResumeTokenExpired = errors.New("resume token has expired")
+
+// resumeToken is what a resume token records about a stream: enough to regenerate the rest of its sequence.
+type resumeToken struct {
+	clientID     uuid.UUID
+	algorithm    protocol.ChecksumAlgorithm
+	source       string
+	seed         generator.Seed
+	distribution *protocol.Distribution
+	totalNumbers uint32
+	// position is the index of the last number sent before the token was issued.
+	position uint32
+	issued   time.Time
+	// deadline is zero for a stream that isn't bounded by its duration.
+	deadline time.Time
+	// serverSeed and seedNonce are nil unless the server picked the seed.
+	serverSeed []byte
+	seedNonce  []byte
+	// checkpoint is the last checkpoint sent before the token was issued, the only one a stream resumed from the
+	// token can be resumed from. Its index is 0 if none was.
+	checkpoint issuedCheckpoint
+	// hashState is the state of the stream's hash at position, so that resuming from it doesn't checksum the sequence
+	// again. It is empty if the token has none.
+	hashState []byte
+}
+
+type resumeKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// resumeTokens seals and opens resume tokens. New tokens are sealed with the first key, and a token sealed with
+// any of the keys is accepted, so keys can be rotated by putting a new key first and dropping the old one once the
+// tokens it sealed have expired.
+//
+// Tokens are encrypted with AES-GCM rather than signed with an HMAC. Both stop a client altering a token, but a
+// token of a stream whose seed the server picked carries the server seed, which the client mustn't learn until the
+// server reveals it with the final checksum, so a token also has to be unreadable.
+type resumeTokens struct {
+	keys []resumeKey
+}
+
+// parseResumeKeys parses a comma separated list of keys, each an ID and a hex encoded secret separated by a colon.
+func parseResumeKeys(keys string) (*resumeTokens, error) {
+	rt := &resumeTokens{}
+	seen := make(map[string]bool)
+
+	for _, key := range strings.Split(keys, ",") {
+		id, secretHex, ok := strings.Cut(key, ":")
+		if !ok || id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("malformed resume key %q, expected <id>:<hex secret>", key)
+		}
+		secret, err := hex.DecodeString(secretHex)
+		if err != nil || len(secret) < 16 {
+			return nil, fmt.Errorf("resume key %q must have a hex encoded secret of at least 16 bytes", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("resume key %q is given more than once", id)
+		}
+		seen[id] = true
+
+		// Secrets can be any length, so the AES key is derived from the secret rather than being the secret.
+		h := sha256.New()
+		fmt.Fprintf(h, "ably-takehome resume token v%d\x00", resumeTokenVersion)
+		h.Write(secret)
+		block, err := aes.NewCipher(h.Sum(nil))
+		if err != nil {
+			return nil, fmt.Errorf("unable to create cipher for resume key %q: %s", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create cipher for resume key %q: %s", id, err)
+		}
+
+		rt.keys = append(rt.keys, resumeKey{id: id, aead: aead})
+	}
+
+	return rt, nil
+}
+
+// seal returns a token recording t, sealed with the first key.
+func (rt *resumeTokens) seal(t resumeToken) []byte {
+	key := rt.keys[0]
+
+	fields := make([]byte, 0, resumeTokenFieldsSize+len(t.source)+len(t.seed)+2+len(t.serverSeed)+2+len(t.seedNonce)+2+8+2+4+len(t.checkpoint.checksum)+2+len(t.hashState)+2+len(t.checkpoint.hashState))
+	fields = append(fields, t.clientID[:]...)
+	fields = append(fields, byte(t.algorithm))
+	fields = append(fields, byte(len(t.source)))
+	fields = append(fields, t.source...)
+	fields = append(fields, byte(len(t.seed)))
+	fields = append(fields, t.seed...)
+	fields = distribution.AppendBinary(fields, t.distribution)
+	fields = binary.BigEndian.AppendUint32(fields, t.totalNumbers)
+	fields = binary.BigEndian.AppendUint32(fields, t.position)
+	fields = binary.BigEndian.AppendUint64(fields, uint64(t.issued.UnixMilli()))
+	fields = appendTokenField(fields, TOKEN_SERVER_SEED, t.serverSeed)
+	fields = appendTokenField(fields, TOKEN_SEED_NONCE, t.seedNonce)
+	if !t.deadline.IsZero() {
+		fields = appendTokenField(fields, TOKEN_DEADLINE, binary.BigEndian.AppendUint64(nil, uint64(t.deadline.UnixMilli())))
+	}
+	if t.checkpoint.index > 0 {
+		fields = appendTokenField(fields, TOKEN_CHECKPOINT, append(binary.BigEndian.AppendUint32(nil, t.checkpoint.index), t.checkpoint.checksum...))
+		fields = appendTokenField(fields, TOKEN_CHECKPOINT_HASH_STATE, t.checkpoint.hashState)
+	}
+	fields = appendTokenField(fields, TOKEN_HASH_STATE, t.hashState)
+
+	header := make([]byte, 0, 2+len(key.id))
+	header = append(header, resumeTokenVersion, byte(len(key.id)))
+	header = append(header, key.id...)
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// crypto/rand only fails if the system's source of randomness is broken, which nothing can recover from.
+		panic(fmt.Sprintf("unable to draw resume token nonce: %s", err))
+	}
+
+	token := make([]byte, 0, len(header)+len(nonce)+len(fields)+key.aead.Overhead())
+	token = append(token, header...)
+	token = append(token, nonce...)
+
+	return key.aead.Seal(token, nonce, fields, header)
+}
+
+// open opens token, checks its age, and returns what it records.
+func (rt *resumeTokens) open(token []byte, now time.Time) (resumeToken, error) {
+	if len(token) < 2 || token[0] != resumeTokenVersion {
+		return resumeToken{}, errors.New("malformed resume token")
+	}
+	idLen := int(token[1])
+	if len(token) < 2+idLen {
+		return resumeToken{}, errors.New("malformed resume token")
+	}
+	id := string(token[2 : 2+idLen])
+
+	var key *resumeKey
+	for i := range rt.keys {
+		if rt.keys[i].id == id {
+			key = &rt.keys[i]
+		}
+	}
+	if key == nil {
+		return resumeToken{}, fmt.Errorf("resume token is sealed with unknown key %q", id)
+	}
+
+	header, sealed := token[:2+idLen], token[2+idLen:]
+	nonceSize := key.aead.NonceSize()
+	if len(sealed) < nonceSize+resumeTokenFieldsSize+key.aead.Overhead() {
+		return resumeToken{}, errors.New("malformed resume token")
+	}
+	fields, err := key.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], header)
+	if err != nil {
+		return resumeToken{}, errors.New("resume token wasn't sealed by this server or has been altered")
+	}
+
+	var t resumeToken
+	copy(t.clientID[:], fields[0:16])
+	t.algorithm = protocol.ChecksumAlgorithm(fields[16])
+	source, rest, err := tokenBytes(fields[17:])
+	if err != nil {
+		return resumeToken{}, errors.New("malformed resume token")
+	}
+	t.source = string(source)
+	seed, rest, err := tokenBytes(rest)
+	if err != nil || len(rest) < distribution.ENCODED_SIZE+4+4+8 {
+		return resumeToken{}, errors.New("malformed resume token")
+	}
+	t.seed = seed
+	t.distribution, err = distribution.Decode(rest[:distribution.ENCODED_SIZE])
+	if err != nil {
+		return resumeToken{}, fmt.Errorf("resume token uses unsupported distribution: %s", err)
+	}
+	rest = rest[distribution.ENCODED_SIZE:]
+	t.totalNumbers = binary.BigEndian.Uint32(rest[0:4])
+	t.position = binary.BigEndian.Uint32(rest[4:8])
+	t.issued = time.UnixMilli(int64(binary.BigEndian.Uint64(rest[8:16])))
+	for rest = rest[16:]; len(rest) > 0; {
+		tag := rest[0]
+		var value []byte
+		value, rest, err = tokenBytes(rest[1:])
+		if err != nil {
+			return resumeToken{}, errors.New("malformed resume token")
+		}
+
+		switch tag {
+		case TOKEN_SERVER_SEED:
+			t.serverSeed = value
+		case TOKEN_SEED_NONCE:
+			t.seedNonce = value
+		case TOKEN_DEADLINE:
+			if len(value) != 8 {
+				return resumeToken{}, errors.New("malformed resume token")
+			}
+			t.deadline = time.UnixMilli(int64(binary.BigEndian.Uint64(value)))
+		case TOKEN_CHECKPOINT:
+			if len(value) < 4 {
+				return resumeToken{}, errors.New("malformed resume token")
+			}
+			t.checkpoint.index = binary.BigEndian.Uint32(value[:4])
+			t.checkpoint.checksum = string(value[4:])
+		case TOKEN_HASH_STATE:
+			t.hashState = value
+		case TOKEN_CHECKPOINT_HASH_STATE:
+			t.checkpoint.hashState = value
+		default:
+			return resumeToken{}, fmt.Errorf("resume token has unknown field %d", tag)
+		}
+	}
+
+	// The token is sealed, so these only fail for a token issued by a server that supports more algorithms.
+	if _, err := checksum.New(t.algorithm, distribution.ValueType(t.distribution)); err != nil {
+		return resumeToken{}, fmt.Errorf("resume token uses unsupported checksum algorithm %s", t.algorithm)
+	}
+	// A source is only registered by the servers configured with it, so a token can name one this server lacks.
+	definition, ok := numberSources[t.source]
+	if !ok {
+		return resumeToken{}, fmt.Errorf("resume token uses unknown source %q", t.source)
+	}
+	if err := definition.checkSeed(t.seed); err != nil || !definition.replayable {
+		return resumeToken{}, fmt.Errorf("resume token uses unsupported source %s", t.source)
+	}
+	if err := distribution.Check(t.distribution, definition.replayable, t.totalNumbers); err != nil {
+		return resumeToken{}, fmt.Errorf("resume token uses unsupported distribution: %s", err)
+	}
+
+	if !now.Before(t.issued.Add(GARBGAGE_TIMEOUT)) {
+		return resumeToken{}, errResumeTokenExpired
+	}
+
+	return t, nil
+}
+
+// appendTokenField appends the optional field value to fields under tag, unless it is empty.
+func appendTokenField(fields []byte, tag uint8, value []byte) []byte {
+	if len(value) == 0 {
+		return fields
+	}
+	fields = append(fields, tag, byte(len(value)))
+
+	return append(fields, value...)
+}
+
+// tokenBytes reads a u8 length prefixed field from the start of fields, returning nil for an empty one.
+func tokenBytes(fields []byte) (field []byte, rest []byte, err error) {
+	if len(fields) < 1 || len(fields) < 1+int(fields[0]) {
+		return nil, nil, errors.New("truncated field")
+	}
+	n := int(fields[0])
+	if n > 0 {
+		field = fields[1 : 1+n]
+	}
+
+	return field, fields[1+n:], nil
+}
+
+// tokenStream attaches a fresh resume token to the NumberResponses sent on a stream, at most once per interval.
+// The last NumberResponse doesn't get one, as there is nothing left to resume.
+type tokenStream struct {
+	numberStream
+	tokens   *resumeTokens
+	interval time.Duration
+	// state is the state of the stream, which sendNumbers has advanced past the last number of a NumberResponse by the
+	// time it is sent.
+	state *State
+	// token is reissued with the position of each NumberResponse it is attached to.
+	token resumeToken
+}
+
+func (ts *tokenStream) Send(response *protocol.NumberResponse) error {
+	if response.Checkpoint != nil {
+		ts.token.checkpoint = ts.state.checkpoints[len(ts.state.checkpoints)-1]
+	}
+
+	now := time.Now()
+	if response.Checksum == "" && now.Sub(ts.token.issued) >= ts.interval {
+		ts.token.position = response.Index + distribution.Count(response) - 1
+		ts.token.hashState, _ = ts.state.hash.MarshalBinary()
+		ts.token.issued = now
+		response.ResumeToken = ts.tokens.seal(ts.token)
+	}
+
+	return ts.numberStream.Send(response)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// scheduler wakes streams up at their next emission time. Every stream shares the one timer owned by the
+// scheduler's goroutine, so an idle stream costs a heap entry rather than a runtime timer.
+type scheduler struct {
+	lock    sync.Mutex
+	waiters waiterHeap
+
+	// wake tells the run loop that the earliest deadline has changed.
+	wake chan struct{}
+}
+
+// waiter is a stream's entry in the scheduler. A stream reuses its waiter for every wait.
+type waiter struct {
+	deadline time.Time
+	ready    chan struct{}
+	// index is the waiter's position in the heap, or -1 when it isn't scheduled.
+	index int
+}
+
+func newScheduler() *scheduler {
+	sch := &scheduler{
+		wake: make(chan struct{}, 1),
+	}
+	go sch.run()
+
+	return sch
+}
+
+func newWaiter() *waiter {
+	return &waiter{
+		ready: make(chan struct{}, 1),
+		index: -1,
+	}
+}
+
+// wait blocks until deadline has passed. It returns straight away with the context's error if ctx is done first.
+func (sch *scheduler) wait(ctx context.Context, w *waiter, deadline time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !deadline.After(time.Now()) {
+		return nil
+	}
+
+	sch.lock.Lock()
+	w.deadline = deadline
+	heap.Push(&sch.waiters, w)
+	if w.index == 0 {
+		sch.notify()
+	}
+	sch.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		sch.lock.Lock()
+		if w.index >= 0 {
+			heap.Remove(&sch.waiters, w.index)
+		}
+		sch.lock.Unlock()
+
+		// The waiter may have been woken between ctx being done and it being removed.
+		select {
+		case <-w.ready:
+		default:
+		}
+
+		return ctx.Err()
+	}
+}
+
+// notify wakes up the run loop without blocking.
+func (sch *scheduler) notify() {
+	select {
+	case sch.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (sch *scheduler) run() {
+	timer := time.NewTimer(time.Hour)
+
+	for {
+		sch.lock.Lock()
+		now := time.Now()
+		for len(sch.waiters) > 0 && !sch.waiters[0].deadline.After(now) {
+			w := heap.Pop(&sch.waiters).(*waiter)
+			w.ready <- struct{}{}
+		}
+
+		// Sleep until the earliest deadline, or until a new waiter needs to be woken before it.
+		sleep := time.Hour
+		if len(sch.waiters) > 0 {
+			sleep = sch.waiters[0].deadline.Sub(now)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(sleep)
+		sch.lock.Unlock()
+
+		select {
+		case <-timer.C:
+		case <-sch.wake:
+		}
+	}
+}
+
+// waiterHeap is a container/heap of waiters ordered by deadline.
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+
+	return w
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/jamesrobb/ably-takehome/fairness"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// commitStream sends the commitment to the server seed of a sequence whose seed the server picked, on the first
+// NumberResponse of each stream so that a client resuming part way through can check it hasn't changed, and reveals
+// the server seed and nonce on the last.
+type commitStream struct {
+	numberStream
+	commitment []byte
+	serverSeed []byte
+	seedNonce  []byte
+	// first is cleared once the first NumberResponse has been sent.
+	first bool
+}
+
+func (cs *commitStream) Send(response *protocol.NumberResponse) error {
+	if cs.first || response.Checksum != "" {
+		response.SeedCommitment = cs.commitment
+	}
+	if response.Checksum != "" {
+		response.ServerSeed = cs.serverSeed
+		response.SeedNonce = cs.seedNonce
+	}
+	cs.first = false
+
+	return cs.numberStream.Send(response)
+}
+
+// seedCommitment returns the commitment to the seed of the sequence in s that the final checksum is signed with:
+// the commitment sent to the client if the server picked the seed, or else one made from the seed itself, or from
+// the source for a source that takes no seed.
+func seedCommitment(s *State) []byte {
+	if s.serverSeed != nil {
+		return fairness.Commitment(s.serverSeed, s.seedNonce)
+	}
+
+	return s.definition().commitment(s.seed)
+}
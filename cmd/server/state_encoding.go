@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jamesrobb/ably-takehome/checksum"
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// stateEncodingVersion is the format version written by State.MarshalBinary.
+//
+// Version 1 is laid out as follows, with integers in big-endian order:
+//
+//	version       uint8
+//	epoch         uint64
+//	lastUpdated   int64 (nanoseconds since the Unix epoch)
+//	source        uint8 length, followed by the name the source is registered under
+//	seed          uint16 length, followed by the seed, empty for a source that takes no seed
+//	distribution  17 bytes, as encoded by distribution.AppendBinary
+//	numbersSent   uint32
+//	totalNumbers  uint32
+//	nextValue     uint64
+//	algorithm     uint8, the protocol.ChecksumAlgorithm of the hash
+//	hash length   uint16, followed by the hash's chain value, or its midstate for MD5_LEGACY
+//	cursor length uint16, followed by the cursor of a source that can't be replayed, empty for any other source
+//	optional      the optional fields below, each a uint8 tag followed by a uint16 length prefixed value
+//
+// The version, epoch and lastUpdated make up a header that every version starts with, so that storages can read
+// them without decoding the rest of the state (see decodeStateHeader).
+//
+// A replayable source's cursor isn't encoded, as it can be as large as MT19937's 2.5KB state array. The source is
+// created again from the seed instead and skipped past the numbers read from it (see STATE_SOURCE_POSITION), which
+// for MT19937 jumps ahead rather than generating them.
+//
+// A field that not every state has is encoded as an optional field, and left out when it has its zero value. A new
+// field is added as a new tag rather than a new version, as a state without it decodes as it always has. A server
+// refuses a tag it doesn't know rather than skip it, as it couldn't honour the field.
+const stateEncodingVersion uint8 = 1
+
+// STATE_HEADER_SIZE is the size of the header every encoded State starts with.
+const STATE_HEADER_SIZE = 1 + 8 + 8
+
+// The tags of the optional fields of an encoded State.
+const (
+	// STATE_SERVER_SEED is the server seed the seed was derived from, when the server picked the seed.
+	STATE_SERVER_SEED uint8 = 1
+	// STATE_SEED_NONCE is the nonce the server seed was committed to with.
+	STATE_SEED_NONCE uint8 = 2
+	// STATE_DEADLINE is the int64 deadline (nanoseconds since the Unix epoch) of a stream bounded by its duration.
+	STATE_DEADLINE uint8 = 3
+	// STATE_CHECKPOINTS is the checkpoints last sent on the stream, oldest first, each a uint32 index followed by a
+	// uint8 length prefixed checksum.
+	STATE_CHECKPOINTS uint8 = 4
+	// STATE_CHECKPOINT_HASHES is the state of the hash at each checkpoint of STATE_CHECKPOINTS, in the same order,
+	// each uint8 length prefixed.
+	STATE_CHECKPOINT_HASHES uint8 = 5
+	// STATE_HASH_ANCHORS is the hash states kept every HASH_ANCHOR_INTERVAL numbers, oldest first, each a uint32
+	// numbersSent followed by the uint8 length prefixed hash state.
+	STATE_HASH_ANCHORS uint8 = 6
+	// STATE_SOURCE_POSITION is the uint64 number of numbers read from a replayable source, as
+	// distribution.Sampler.Read returns it.
+	STATE_SOURCE_POSITION uint8 = 7
+	// STATE_SAMPLE_SWAPPED is the integers a sample has moved, as distribution.Sampler.Swapped returns them, each a
+	// uvarint position less numbersSent+1 followed by the uvarint integer at it. There can be more of them than one
+	// field holds, so the field is repeated as often as they need.
+	STATE_SAMPLE_SWAPPED uint8 = 8
+)
+
+// stateMigrations upgrade an encoded State from one format version to the next, the entry for version v
+// returning the version v+1 encoding. When the format changes in a way an optional field can't cover, bump
+// stateEncodingVersion and add a migration from the previous version so that a server can still resume sessions
+// stored by the version before it. A migration must leave the header as it is.
+var stateMigrations = map[uint8]func(data []byte) ([]byte, error){}
+
+// decodeStateHeader returns the epoch and lastUpdated of an encoded State without decoding the rest of it, which
+// would rebuild its source.
+func decodeStateHeader(data []byte) (epoch uint64, lastUpdated time.Time, err error) {
+	if len(data) == 0 {
+		return 0, time.Time{}, fmt.Errorf("encoded state is empty")
+	}
+	if version := data[0]; version == 0 || version > stateEncodingVersion {
+		return 0, time.Time{}, fmt.Errorf("encoded state has unsupported version %d, this server supports up to version %d", version, stateEncodingVersion)
+	}
+	if len(data) < STATE_HEADER_SIZE {
+		return 0, time.Time{}, fmt.Errorf("encoded state is truncated")
+	}
+
+	return binary.BigEndian.Uint64(data[1:9]), time.Unix(0, int64(binary.BigEndian.Uint64(data[9:17]))), nil
+}
+
+// MarshalBinary encodes s, including where its source is and the state of its checksum, so that it can be restored
+// exactly. For sample, the integers its shuffle has moved are encoded too, so that it isn't drawn again when s is
+// restored.
+func (s *State) MarshalBinary() ([]byte, error) {
+	hashState, err := s.hash.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode checksum state: %s", err)
+	}
+	var cursor, position []byte
+	if s.source.Replayable() {
+		position = binary.BigEndian.AppendUint64(nil, s.values.Read())
+	} else {
+		cursor, err = s.source.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode source cursor: %s", err)
+		}
+	}
+
+	data := make([]byte, 0, STATE_HEADER_SIZE+1+len(s.sourceName)+2+len(s.seed)+distribution.ENCODED_SIZE+4+4+8+1+2+len(hashState)+2+len(cursor)+3+len(s.serverSeed)+3+len(s.seedNonce)+3+8+3+8)
+	data = append(data, stateEncodingVersion)
+	data = binary.BigEndian.AppendUint64(data, s.epoch)
+	data = binary.BigEndian.AppendUint64(data, uint64(s.lastUpdated.UnixNano()))
+	data = append(data, byte(len(s.sourceName)))
+	data = append(data, s.sourceName...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(s.seed)))
+	data = append(data, s.seed...)
+	data = distribution.AppendBinary(data, s.values.Distribution())
+	data = binary.BigEndian.AppendUint32(data, s.numbersSent)
+	data = binary.BigEndian.AppendUint32(data, s.totalNumbers)
+	data = binary.BigEndian.AppendUint64(data, s.nextValue)
+	data = append(data, byte(s.hash.Algorithm()))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(hashState)))
+	data = append(data, hashState...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(cursor)))
+	data = append(data, cursor...)
+	data = appendStateField(data, STATE_SERVER_SEED, s.serverSeed)
+	data = appendStateField(data, STATE_SEED_NONCE, s.seedNonce)
+	if !s.deadline.IsZero() {
+		data = appendStateField(data, STATE_DEADLINE, binary.BigEndian.AppendUint64(nil, uint64(s.deadline.UnixNano())))
+	}
+	data = appendStateField(data, STATE_CHECKPOINTS, appendCheckpoints(nil, s.checkpoints))
+	data = appendStateField(data, STATE_CHECKPOINT_HASHES, appendCheckpointHashes(nil, s.checkpoints))
+	data = appendStateField(data, STATE_HASH_ANCHORS, appendAnchors(nil, s.anchors))
+	data = appendStateField(data, STATE_SOURCE_POSITION, position)
+	for _, swapped := range encodeSwapped(s.values.Swapped(), uint64(s.numbersSent)+1) {
+		data = appendStateField(data, STATE_SAMPLE_SWAPPED, swapped)
+	}
+
+	return data, nil
+}
+
+// UnmarshalBinary restores s from data produced by MarshalBinary, migrating it first if it was written in an
+// older format version.
+func (s *State) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("encoded state is empty")
+	}
+
+	version := data[0]
+	if version == 0 || version > stateEncodingVersion {
+		return fmt.Errorf("encoded state has unsupported version %d, this server supports up to version %d", version, stateEncodingVersion)
+	}
+	for version < stateEncodingVersion {
+		migrate, ok := stateMigrations[version]
+		if !ok {
+			return fmt.Errorf("no migration from encoded state version %d", version)
+		}
+
+		var err error
+		data, err = migrate(data)
+		if err != nil {
+			return fmt.Errorf("unable to migrate encoded state from version %d: %s", version, err)
+		}
+		if len(data) == 0 || data[0] != version+1 {
+			return fmt.Errorf("migration from encoded state version %d produced the wrong version", version)
+		}
+		version = data[0]
+	}
+
+	r := stateReader{data: data[1:]}
+	decoded := State{
+		epoch:       r.uint64(),
+		lastUpdated: time.Unix(0, int64(r.uint64())),
+	}
+	decoded.sourceName = string(r.next(int(r.uint8())))
+	if seed := r.bytes(); len(seed) > 0 {
+		decoded.seed = append(generator.Seed(nil), seed...)
+	}
+	encodedDistribution := r.next(distribution.ENCODED_SIZE)
+	decoded.numbersSent = r.uint32()
+	decoded.totalNumbers = r.uint32()
+	decoded.nextValue = r.uint64()
+	algorithm := protocol.ChecksumAlgorithm(r.uint8())
+	hashState := r.bytes()
+	cursor := r.bytes()
+	var checkpointHashes [][]byte
+	var position []byte
+	var swapped map[uint64]uint64
+	for r.err == nil && len(r.data) > 0 {
+		tag := r.uint8()
+		value := r.bytes()
+		if r.err != nil {
+			break
+		}
+
+		switch tag {
+		case STATE_SERVER_SEED:
+			decoded.serverSeed = append([]byte(nil), value...)
+		case STATE_SEED_NONCE:
+			decoded.seedNonce = append([]byte(nil), value...)
+		case STATE_DEADLINE:
+			if len(value) != 8 {
+				return fmt.Errorf("encoded state has a malformed deadline")
+			}
+			decoded.deadline = time.Unix(0, int64(binary.BigEndian.Uint64(value)))
+		case STATE_CHECKPOINTS:
+			checkpoints, err := decodeCheckpoints(value)
+			if err != nil {
+				return fmt.Errorf("encoded state has malformed checkpoints: %s", err)
+			}
+			decoded.checkpoints = checkpoints
+		case STATE_CHECKPOINT_HASHES:
+			hashes, err := decodeHashStates(value)
+			if err != nil {
+				return fmt.Errorf("encoded state has malformed checkpoint hashes: %s", err)
+			}
+			checkpointHashes = hashes
+		case STATE_HASH_ANCHORS:
+			anchors, err := decodeAnchors(value)
+			if err != nil {
+				return fmt.Errorf("encoded state has malformed hash anchors: %s", err)
+			}
+			decoded.anchors = anchors
+		case STATE_SOURCE_POSITION:
+			if len(value) != 8 {
+				return fmt.Errorf("encoded state has a malformed source position")
+			}
+			position = value
+		case STATE_SAMPLE_SWAPPED:
+			if swapped == nil {
+				swapped = make(map[uint64]uint64)
+			}
+			if err := decodeSwapped(value, uint64(decoded.numbersSent)+1, swapped); err != nil {
+				return fmt.Errorf("encoded state has malformed sample: %s", err)
+			}
+		default:
+			return fmt.Errorf("encoded state has unknown field %d", tag)
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if checkpointHashes != nil {
+		if len(checkpointHashes) != len(decoded.checkpoints) {
+			return fmt.Errorf("encoded state has %d checkpoint hashes for %d checkpoints", len(checkpointHashes), len(decoded.checkpoints))
+		}
+		for i := range decoded.checkpoints {
+			decoded.checkpoints[i].hashState = checkpointHashes[i]
+		}
+	}
+
+	dist, err := distribution.Decode(encodedDistribution)
+	if err != nil {
+		return fmt.Errorf("unable to decode distribution: %s", err)
+	}
+	decoded.hash, err = checksum.New(algorithm, distribution.ValueType(dist))
+	if err != nil {
+		return fmt.Errorf("unable to decode checksum state: %s", err)
+	}
+	if err := decoded.hash.UnmarshalBinary(hashState); err != nil {
+		return fmt.Errorf("unable to decode checksum state: %s", err)
+	}
+	definition, ok := numberSources[decoded.sourceName]
+	if !ok {
+		return fmt.Errorf("source %q isn't registered", decoded.sourceName)
+	}
+	decoded.source, err = definition.new(decoded.seed)
+	if err != nil {
+		return fmt.Errorf("unable to decode source cursor: %s", err)
+	}
+	if decoded.source.Replayable() {
+		if position == nil {
+			return fmt.Errorf("encoded state has no source position")
+		}
+		decoded.values = distribution.Resume(dist, decoded.source, uint64(decoded.numbersSent)+1, binary.BigEndian.Uint64(position), swapped)
+	} else {
+		if err := decoded.source.UnmarshalBinary(cursor); err != nil {
+			return fmt.Errorf("unable to decode source cursor: %s", err)
+		}
+		decoded.values = distribution.Resume(dist, decoded.source, uint64(decoded.numbersSent)+1, 0, nil)
+	}
+
+	*s = decoded
+
+	return nil
+}
+
+// appendStateField appends the optional field value to data under tag, unless it is empty.
+func appendStateField(data []byte, tag uint8, value []byte) []byte {
+	if len(value) == 0 {
+		return data
+	}
+	data = append(data, tag)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(value)))
+
+	return append(data, value...)
+}
+
+// appendCheckpoints appends checkpoints to data, each as a uint32 index followed by a uint8 length prefixed
+// checksum.
+func appendCheckpoints(data []byte, checkpoints []issuedCheckpoint) []byte {
+	for _, c := range checkpoints {
+		data = binary.BigEndian.AppendUint32(data, c.index)
+		data = append(data, byte(len(c.checksum)))
+		data = append(data, c.checksum...)
+	}
+
+	return data
+}
+
+// decodeCheckpoints decodes checkpoints encoded by appendCheckpoints.
+func decodeCheckpoints(data []byte) ([]issuedCheckpoint, error) {
+	var checkpoints []issuedCheckpoint
+	for len(data) > 0 {
+		if len(data) < 5 || len(data) < 5+int(data[4]) {
+			return nil, fmt.Errorf("truncated checkpoint")
+		}
+		n := int(data[4])
+		checkpoints = append(checkpoints, issuedCheckpoint{
+			index:    binary.BigEndian.Uint32(data[:4]),
+			checksum: string(data[5 : 5+n]),
+		})
+		data = data[5+n:]
+	}
+
+	return checkpoints, nil
+}
+
+// appendCheckpointHashes appends the hash state of each of checkpoints to data, each uint8 length prefixed, or
+// nothing if the checkpoints have none.
+func appendCheckpointHashes(data []byte, checkpoints []issuedCheckpoint) []byte {
+	for _, c := range checkpoints {
+		if len(c.hashState) == 0 {
+			return data
+		}
+	}
+	for _, c := range checkpoints {
+		data = append(data, byte(len(c.hashState)))
+		data = append(data, c.hashState...)
+	}
+
+	return data
+}
+
+// appendAnchors appends anchors to data, each as a uint32 numbersSent followed by the uint8 length prefixed hash
+// state.
+func appendAnchors(data []byte, anchors []hashAnchor) []byte {
+	for _, a := range anchors {
+		data = binary.BigEndian.AppendUint32(data, a.numbersSent)
+		data = append(data, byte(len(a.hashState)))
+		data = append(data, a.hashState...)
+	}
+
+	return data
+}
+
+// encodeSwapped encodes the integers a sample has moved, by position, after drawn values, as the values of as many
+// STATE_SAMPLE_SWAPPED fields as they need.
+func encodeSwapped(swapped map[uint64]uint64, drawn uint64) [][]byte {
+	var fields [][]byte
+	var field []byte
+	for position, v := range swapped {
+		if len(field)+2*binary.MaxVarintLen64 > math.MaxUint16 {
+			fields = append(fields, field)
+			field = nil
+		}
+		field = binary.AppendUvarint(field, position-drawn)
+		field = binary.AppendUvarint(field, v)
+	}
+	if len(field) > 0 {
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// decodeSwapped adds the integers encoded by encodeSwapped in the value of a STATE_SAMPLE_SWAPPED field to
+// swapped.
+func decodeSwapped(data []byte, drawn uint64, swapped map[uint64]uint64) error {
+	for len(data) > 0 {
+		offset, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("truncated position")
+		}
+		data = data[n:]
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("truncated integer")
+		}
+		data = data[n:]
+		swapped[drawn+offset] = v
+	}
+
+	return nil
+}
+
+// decodeHashStates decodes the uint8 length prefixed hash states encoded by appendCheckpointHashes.
+func decodeHashStates(data []byte) ([][]byte, error) {
+	var hashStates [][]byte
+	for len(data) > 0 {
+		if len(data) < 1+int(data[0]) {
+			return nil, fmt.Errorf("truncated hash state")
+		}
+		n := int(data[0])
+		hashStates = append(hashStates, append([]byte(nil), data[1:1+n]...))
+		data = data[1+n:]
+	}
+
+	return hashStates, nil
+}
+
+// decodeAnchors decodes anchors encoded by appendAnchors.
+func decodeAnchors(data []byte) ([]hashAnchor, error) {
+	var anchors []hashAnchor
+	for len(data) > 0 {
+		if len(data) < 5 || len(data) < 5+int(data[4]) {
+			return nil, fmt.Errorf("truncated anchor")
+		}
+		n := int(data[4])
+		anchors = append(anchors, hashAnchor{
+			numbersSent: binary.BigEndian.Uint32(data[:4]),
+			hashState:   append([]byte(nil), data[5:5+n]...),
+		})
+		data = data[5+n:]
+	}
+
+	return anchors, nil
+}
+
+// stateReader consumes the fields of an encoded State. After the first short read every read returns a zero
+// value and err is set.
+type stateReader struct {
+	data []byte
+	err  error
+}
+
+func (r *stateReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = fmt.Errorf("encoded state is truncated")
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+
+	return b
+}
+
+func (r *stateReader) uint8() uint8 {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+func (r *stateReader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+
+	return 0
+}
+
+func (r *stateReader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+
+	return 0
+}
+
+func (r *stateReader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+
+	return 0
+}
+
+// bytes reads a uint16 length prefixed field.
+func (r *stateReader) bytes() []byte {
+	return r.next(int(r.uint16()))
+}
@@ -1,110 +1,315 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"hash"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"gonum.org/v1/gonum/mathext/prng"
+
+	"github.com/jamesrobb/ably-takehome/checksum"
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
 )
 
-const GARBGAGE_TIMEOUT time.Duration = 30 * time.Second
+// GARBGAGE_TIMEOUT is how long a stream's state is kept after it was last updated. A stream only updates its state
+// when it sends, so it has to be well over the longest delay between two numbers, which -maxInterval is held to half
+// of, or the state of a client that disconnects between two numbers would expire before it could resume.
+const GARBGAGE_TIMEOUT time.Duration = 2 * time.Minute
+
+// CHECKPOINTS_KEPT is how many of the checkpoints most recently sent on a stream its state remembers, which are the
+// checkpoints a client can resume the stream from.
+const CHECKPOINTS_KEPT = 4
+
+// HASH_ANCHOR_INTERVAL is how many numbers apart a state keeps the state of its hash, so that rewinding it only has
+// to checksum the numbers since the closest one rather than the whole sequence again.
+const HASH_ANCHOR_INTERVAL = 1024
+
+// HASH_ANCHORS_KEPT is how many of the hash states kept every HASH_ANCHOR_INTERVAL numbers a state remembers.
+const HASH_ANCHORS_KEPT = 4
+
+// issuedCheckpoint is a checkpoint sent to a client, the index of a number and the checksum of the sequence up to
+// it.
+type issuedCheckpoint struct {
+	index    uint32
+	checksum string
+	// hashState is the state of the hash at the checkpoint, as checksum.Hash.MarshalBinary returns it, so that the
+	// sequence can be rewound to the checkpoint without checksumming it again. It is empty for a checkpoint stored
+	// before hash states were kept.
+	hashState []byte
+}
+
+// hashAnchor is the state of a sequence's hash when numbersSent numbers had been sent, which as in a State includes
+// the number after them.
+type hashAnchor struct {
+	numbersSent uint32
+	hashState   []byte
+}
 
 type State struct {
-	numbersSent  uint32
-	nextNumber   uint32
+	// sourceName is the name the sequence's source is registered under.
+	sourceName string
+	// seed is nil for a source that takes no seed.
+	seed        generator.Seed
+	numbersSent uint32
+	// nextValue is the value at index numbersSent+1, as the 64 bits the checksum package takes it as.
+	nextValue    uint64
 	totalNumbers uint32
-	lastUpdated  time.Time
-	hash         hash.Hash
-	prng         *prng.MT19937
+	// deadline is when a stream bounded by its duration ends, it is zero for a stream without one.
+	deadline    time.Time
+	lastUpdated time.Time
+	hash        *checksum.Hash
+	source      NumberSource
+	// values draws the sequence's values from source.
+	values *distribution.Sampler
+	// epoch is the fencing token of the stream that owns the state. Storage refuses to overwrite a state with one
+	// from an older epoch, so a stream that has been superseded can't write over its successor's progress.
+	epoch uint64
+	// serverSeed and seedNonce are what seed was derived from and committed to when the server picked it, they are
+	// nil when the client chose the seed.
+	serverSeed []byte
+	seedNonce  []byte
+	// checkpoints are the last CHECKPOINTS_KEPT checkpoints sent, oldest first. The slice is replaced rather than
+	// changed, so states rewound from one another can share it.
+	checkpoints []issuedCheckpoint
+	// anchors are the last HASH_ANCHORS_KEPT hash states kept every HASH_ANCHOR_INTERVAL numbers, oldest first. Like
+	// checkpoints, the slice is replaced rather than changed.
+	anchors []hashAnchor
 }
 
-type StateStorage interface {
-	IsExpiredClientID(clientID uuid.UUID) bool
-	GetState(clientID uuid.UUID) (*State, error)
-	SetState(clientID uuid.UUID, state *State) error
-	DeleteState(clientID uuid.UUID) error
-}
+// newState creates the state for a sequence of totalNumbers values drawn from dist over the numbers of the source
+// registered as sourceName, seeded with seed, checksummed with algorithm, positioned at the first value of the
+// sequence. The source's checkSeed must accept the seed, distribution.Check the distribution, and algorithm must be
+// able to checksum the distribution's values.
+func newState(sourceName string, seed generator.Seed, dist *protocol.Distribution, totalNumbers uint32, algorithm protocol.ChecksumAlgorithm) *State {
+	h, _ := checksum.New(algorithm, distribution.ValueType(dist))
+	src, _ := numberSources[sourceName].new(seed)
+	s := &State{
+		sourceName:   sourceName,
+		seed:         seed,
+		numbersSent:  0,
+		totalNumbers: totalNumbers,
+		lastUpdated:  time.Now(),
+		hash:         h,
+		source:       src,
+		values:       distribution.New(dist, src),
+	}
 
-type InMemoryStorage struct {
-	states     map[uuid.UUID]*State
-	statesLock sync.Mutex
+	s.nextValue = s.values.Next()
+	s.hash.Add(s.nextValue)
 
-	badClients     map[uuid.UUID]bool
-	badClientsLock sync.Mutex
+	return s
 }
 
-func NewInMemoryStorage() *InMemoryStorage {
-	return &InMemoryStorage{
-		states:     make(map[uuid.UUID]*State),
-		badClients: make(map[uuid.UUID]bool),
+// advance records nextValue as sent and moves the state on to the following value.
+func (s *State) advance() {
+	s.nextValue = s.values.Next()
+	s.numbersSent++
+	s.lastUpdated = time.Now()
+	s.hash.Add(s.nextValue)
+
+	if s.numbersSent%HASH_ANCHOR_INTERVAL == 0 {
+		hashState, _ := s.hash.MarshalBinary()
+		kept := s.anchors
+		if len(kept) >= HASH_ANCHORS_KEPT {
+			kept = kept[len(kept)-HASH_ANCHORS_KEPT+1:]
+		}
+
+		anchors := make([]hashAnchor, 0, len(kept)+1)
+		anchors = append(anchors, kept...)
+		s.anchors = append(anchors, hashAnchor{numbersSent: s.numbersSent, hashState: hashState})
 	}
 }
 
-func (ims *InMemoryStorage) IsExpiredClientID(clientID uuid.UUID) bool {
-	// Triggers garbage collection (which marks expired clients)
-	ims.statesLock.Lock()
-	ims.garbageCollectStates()
-	ims.statesLock.Unlock()
-
-	_, ok := ims.badClients[clientID]
+// sourceErr returns the error s's source failed with, nil if it hasn't failed or can't fail.
+func (s *State) sourceErr() error {
+	if fs, ok := s.source.(failingSource); ok {
+		return fs.Err()
+	}
 
-	return ok
+	return nil
 }
 
-func (ims *InMemoryStorage) GetState(clientID uuid.UUID) (*State, error) {
-	ims.statesLock.Lock()
-	ims.statesLock.Unlock()
+// recordCheckpoint remembers that the checkpoint at index, with checksum, was sent. s's hash must have just had the
+// number at index added. Only the last CHECKPOINTS_KEPT are remembered.
+func (s *State) recordCheckpoint(index uint32, checksum string) {
+	hashState, _ := s.hash.MarshalBinary()
 
-	ims.garbageCollectStates()
+	kept := s.checkpoints
+	if len(kept) >= CHECKPOINTS_KEPT {
+		kept = kept[len(kept)-CHECKPOINTS_KEPT+1:]
+	}
 
-	state, ok := ims.states[clientID]
-	if !ok {
-		return nil, fmt.Errorf("state not found for clientID=%s", clientID)
+	checkpoints := make([]issuedCheckpoint, 0, len(kept)+1)
+	checkpoints = append(checkpoints, kept...)
+	s.checkpoints = append(checkpoints, issuedCheckpoint{index: index, checksum: checksum, hashState: hashState})
+}
+
+// checkpointsUpTo returns the checkpoints in checkpoints, oldest first, at or before index.
+func checkpointsUpTo(checkpoints []issuedCheckpoint, index uint32) []issuedCheckpoint {
+	n := 0
+	for n < len(checkpoints) && checkpoints[n].index <= index {
+		n++
 	}
 
-	return state, nil
+	return checkpoints[:n]
 }
 
-func (ims *InMemoryStorage) garbageCollectStates() {
-	// Assumes calling method holds ims.statesLock.
+// anchorsUpTo returns the anchors in anchors, oldest first, at or before numbersSent.
+func anchorsUpTo(anchors []hashAnchor, numbersSent uint32) []hashAnchor {
+	n := 0
+	for n < len(anchors) && anchors[n].numbersSent <= numbersSent {
+		n++
+	}
 
-	for key, state := range ims.states {
-		now := time.Now()
-		if now.Sub(state.lastUpdated) > GARBGAGE_TIMEOUT {
-			ims.badClientsLock.Lock()
-			ims.badClients[key] = true
-			ims.badClientsLock.Unlock()
+	return anchors[:n]
+}
 
-			delete(ims.states, key)
+// closestAnchor returns the latest state of s's hash that it remembers, from its anchors and checkpoints, at or
+// before numbersSent. It returns false if s remembers none.
+func (s *State) closestAnchor(numbersSent uint32) (hashAnchor, bool) {
+	var closest hashAnchor
+	found := false
+	consider := func(a hashAnchor) {
+		if len(a.hashState) > 0 && a.numbersSent <= numbersSent && (!found || a.numbersSent > closest.numbersSent) {
+			closest, found = a, true
 		}
 	}
+
+	for _, a := range s.anchors {
+		consider(a)
+	}
+	// The hash at a checkpoint includes the number at its index, so it is the state once the number before was sent.
+	for _, c := range s.checkpoints {
+		consider(hashAnchor{numbersSent: c.index - 1, hashState: c.hashState})
+	}
+
+	return closest, found
 }
 
-func (ims *InMemoryStorage) DeleteState(clientID uuid.UUID) error {
-	ims.statesLock.Lock()
-	defer ims.statesLock.Unlock()
+// pastDeadline reports whether the stream in s has run for its duration by now.
+func (s *State) pastDeadline(now time.Time) bool {
+	return !s.deadline.IsZero() && !now.Before(s.deadline)
+}
 
-	delete(ims.states, clientID)
+// clone returns a copy of s that can be advanced without moving s. The source's cursor and the hash's state are
+// copied rather than generated again.
+func (s *State) clone() (*State, error) {
+	c := *s
 
-	return nil
+	hashState, err := s.hash.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("unable to copy checksum state: %s", err)
+	}
+	c.hash, _ = checksum.New(s.hash.Algorithm(), s.hash.ValueType())
+	if err := c.hash.UnmarshalBinary(hashState); err != nil {
+		return nil, fmt.Errorf("unable to copy checksum state: %s", err)
+	}
+
+	cursor, err := s.source.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("unable to copy source cursor: %s", err)
+	}
+	c.source, err = s.definition().new(s.seed)
+	if err != nil {
+		return nil, fmt.Errorf("unable to copy source cursor: %s", err)
+	}
+	if err := c.source.UnmarshalBinary(cursor); err != nil {
+		return nil, fmt.Errorf("unable to copy source cursor: %s", err)
+	}
+	c.values = s.values.Clone(c.source)
+
+	return &c, nil
 }
 
-func (ims *InMemoryStorage) SetState(clientID uuid.UUID, state *State) error {
-	ims.statesLock.Lock()
-	defer ims.statesLock.Unlock()
+// rewind returns a copy of s positioned as if only numbersSent numbers had been sent, provided s's source is
+// replayable. The source is regenerated from the seed and skipped ahead, and the hash is restored from the closest
+// state of it s remembers at or before numbersSent, so only the numbers after that are checksummed again. Without
+// one the whole sequence up to numbersSent is.
+func (s *State) rewind(numbersSent uint32) *State {
+	h, _ := checksum.New(s.hash.Algorithm(), s.hash.ValueType())
+	src := s.replay()
+	r := &State{
+		sourceName:   s.sourceName,
+		seed:         s.seed,
+		totalNumbers: s.totalNumbers,
+		deadline:     s.deadline,
+		lastUpdated:  time.Now(),
+		hash:         h,
+		source:       src,
+		values:       distribution.New(s.values.Distribution(), src),
+		epoch:        s.epoch,
+		serverSeed:   s.serverSeed,
+		seedNonce:    s.seedNonce,
+		checkpoints:  checkpointsUpTo(s.checkpoints, numbersSent),
+		anchors:      anchorsUpTo(s.anchors, numbersSent),
+	}
 
-	storeState := &State{
-		numbersSent:  state.numbersSent,
-		nextNumber:   state.nextNumber,
-		totalNumbers: state.totalNumbers,
-		lastUpdated:  state.lastUpdated,
-		hash:         state.hash,
-		prng:         state.prng,
+	if anchor, ok := s.closestAnchor(numbersSent); ok && r.hash.UnmarshalBinary(anchor.hashState) == nil {
+		// The hash already includes the value after the anchor, so it is drawn without being added.
+		r.values.Skip(anchor.numbersSent)
+		r.numbersSent = anchor.numbersSent
+		r.nextValue = r.values.Next()
+	} else {
+		r.hash, _ = checksum.New(s.hash.Algorithm(), s.hash.ValueType())
+		r.nextValue = r.values.Next()
+		r.hash.Add(r.nextValue)
+	}
+	for r.numbersSent < numbersSent {
+		r.advance()
 	}
-	ims.states[clientID] = storeState
 
-	return nil
+	return r
+}
+
+// replay returns a new source positioned at the first number of the sequence s is positioned in. s's source must be
+// replayable.
+func (s *State) replay() NumberSource {
+	src, _ := numberSources[s.sourceName].new(s.seed)
+
+	return src
+}
+
+// definition returns the registered definition of s's source.
+func (s *State) definition() *sourceDefinition {
+	return numberSources[s.sourceName]
+}
+
+// Clock tells a StateStorage the time, so that expiry can be tested without waiting for it.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// The errors a StateStorage returns. Implementations may wrap them to add detail, so compare with errors.Is.
+var (
+	// ErrNotFound means the client has no stored state, so it is new.
+	ErrNotFound = errors.New("state not found")
+	// ErrExpired means the client's state expired, and its ID can't be reused.
+	ErrExpired = errors.New("client ID has expired")
+	// ErrUnavailable means the storage couldn't be reached, so whether the client has a stored state is unknown.
+	ErrUnavailable = errors.New("state storage unavailable")
+	// ErrFenced means the stored state belongs to a newer epoch than the write, so the write was refused.
+	ErrFenced = errors.New("state belongs to a newer stream")
+	// ErrCorrupt means the client's stored state can't be decoded, so its stream can't be resumed.
+	ErrCorrupt = errors.New("stored state can't be decoded")
+)
+
+type StateStorage interface {
+	// GetState returns the client's stored state, ErrExpired if its state has expired, ErrNotFound if it has
+	// none, or ErrCorrupt if its stored state can't be decoded.
+	GetState(ctx context.Context, clientID uuid.UUID) (*State, error)
+	// SetState stores state, unless the client has expired in which case it returns ErrExpired, or the stored state
+	// has a newer epoch in which case it returns ErrFenced.
+	SetState(ctx context.Context, clientID uuid.UUID, state *State) error
+	// DeleteState deletes the client's state, unless the stored state has a newer epoch than epoch in which case
+	// it returns ErrFenced.
+	DeleteState(ctx context.Context, clientID uuid.UUID, epoch uint64) error
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// TestRewind checks that a rewound state is the state that was at that position, whether its hash is restored from
+// a checkpoint, from an anchor, or checksummed again from the start of the sequence.
+func TestRewind(t *testing.T) {
+	uniform, _ := distribution.Parse("uniform:1:6")
+	for _, test := range []struct {
+		name      string
+		dist      *protocol.Distribution
+		algorithm protocol.ChecksumAlgorithm
+	}{
+		{"raw numbers", nil, protocol.ChecksumAlgorithm_SHA256},
+		{"md5 midstate", nil, protocol.ChecksumAlgorithm_MD5_LEGACY},
+		{"distribution", uniform, protocol.ChecksumAlgorithm_BLAKE2B_256},
+	} {
+		name := generator.Name(protocol.PrngAlgorithm_MT19937)
+		s := newState(name, generator.Uint32Seed(7), test.dist, DEFAULT_MAX_NUMBERS, test.algorithm)
+		type position struct {
+			nextValue uint64
+			sum       string
+		}
+		positions := map[uint32]position{0: {s.nextValue, s.hash.Sum()}}
+		for s.numbersSent < 5*HASH_ANCHOR_INTERVAL {
+			if s.numbersSent%700 == 0 {
+				s.recordCheckpoint(s.numbersSent+1, s.hash.Sum())
+			}
+			s.advance()
+			positions[s.numbersSent] = position{s.nextValue, s.hash.Sum()}
+		}
+
+		for _, numbersSent := range []uint32{0, 1, 2*HASH_ANCHOR_INTERVAL - 1, 2101, 2102, 3500, 4*HASH_ANCHOR_INTERVAL + 3, s.numbersSent} {
+			r := s.rewind(numbersSent)
+			want := positions[numbersSent]
+			if r.numbersSent != numbersSent || r.nextValue != want.nextValue || r.hash.Sum() != want.sum {
+				t.Errorf("%s: rewind(%d) is at %d with next value %d and checksum %s, want next value %d and checksum %s",
+					test.name, numbersSent, r.numbersSent, r.nextValue, r.hash.Sum(), want.nextValue, want.sum)
+			}
+		}
+	}
+}
+
+// TestClone checks that a clone carries on with the sequence of the state it was cloned from without moving it.
+func TestClone(t *testing.T) {
+	sample, _ := distribution.Parse("sample:1:1000")
+	for _, dist := range []*protocol.Distribution{nil, sample} {
+		s := newState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(7), dist, DEFAULT_MAX_NUMBERS, protocol.ChecksumAlgorithm_SHA256)
+		for s.numbersSent < 100 {
+			s.advance()
+		}
+		nextValue, sum := s.nextValue, s.hash.Sum()
+
+		c, err := s.clone()
+		if err != nil {
+			t.Fatalf("%s: clone: %s", distribution.String(dist), err)
+		}
+		for c.numbersSent < 200 {
+			c.advance()
+		}
+		if s.numbersSent != 100 || s.nextValue != nextValue || s.hash.Sum() != sum {
+			t.Errorf("%s: advancing the clone moved the state it was cloned from", distribution.String(dist))
+		}
+
+		for s.numbersSent < 200 {
+			s.advance()
+		}
+		if c.nextValue != s.nextValue || c.hash.Sum() != s.hash.Sum() {
+			t.Errorf("%s: clone is at next value %d and checksum %s, want %d and %s", distribution.String(dist), c.nextValue, c.hash.Sum(), s.nextValue, s.hash.Sum())
+		}
+	}
+}
+
+// TestStateEncodingSize checks that an encoded state stays small however far into its sequence it is, as a
+// replayable source is skipped ahead from its seed rather than having its cursor stored.
+func TestStateEncodingSize(t *testing.T) {
+	const maxSize = 512
+
+	uniform, _ := distribution.Parse("uniform:1:6")
+	for _, algorithm := range []protocol.PrngAlgorithm{protocol.PrngAlgorithm_MT19937, protocol.PrngAlgorithm_MT19937_64, protocol.PrngAlgorithm_XOSHIRO256_PLUS_PLUS, protocol.PrngAlgorithm_CHACHA20} {
+		for _, dist := range []*protocol.Distribution{nil, uniform} {
+			s := newState(generator.Name(algorithm), generator.Uint32Seed(7), dist, DEFAULT_MAX_NUMBERS, protocol.ChecksumAlgorithm_SHA256)
+			for s.numbersSent < 5*HASH_ANCHOR_INTERVAL {
+				s.advance()
+			}
+			s.recordCheckpoint(s.numbersSent+1, s.hash.Sum())
+
+			data, err := s.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) > maxSize {
+				t.Errorf("%s %s: encoded state is %d bytes, want at most %d", algorithm, distribution.String(dist), len(data), maxSize)
+			}
+
+			decoded := &State{}
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatalf("%s %s: %s", algorithm, distribution.String(dist), err)
+			}
+			for i := 0; i < 10; i++ {
+				s.advance()
+				decoded.advance()
+			}
+			if decoded.nextValue != s.nextValue || decoded.hash.Sum() != s.hash.Sum() {
+				t.Errorf("%s %s: decoded state is at next value %d and checksum %s, want %d and %s",
+					algorithm, distribution.String(dist), decoded.nextValue, decoded.hash.Sum(), s.nextValue, s.hash.Sum())
+			}
+		}
+	}
+}
+
+// TestSampleEncoding checks that a decoded sample carries on drawing the values that were left, when it has moved
+// more integers than one encoded field holds.
+func TestSampleEncoding(t *testing.T) {
+	sample, _ := distribution.Parse("sample:0:9223372036854775807")
+	s := newState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(7), sample, DEFAULT_MAX_NUMBERS, protocol.ChecksumAlgorithm_SHA256)
+	for s.numbersSent < 10000 {
+		s.advance()
+	}
+
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := len(encodeSwapped(s.values.Swapped(), uint64(s.numbersSent)+1)); fields < 2 {
+		t.Fatalf("moved integers fit in %d field, want a test that needs more", fields)
+	}
+	decoded := &State{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		s.advance()
+		decoded.advance()
+	}
+	if decoded.nextValue != s.nextValue || decoded.hash.Sum() != s.hash.Sum() {
+		t.Errorf("decoded sample is at next value %d and checksum %s, want %d and %s", decoded.nextValue, decoded.hash.Sum(), s.nextValue, s.hash.Sum())
+	}
+}
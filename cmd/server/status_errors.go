@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// UNAVAILABLE_RETRY_DELAY is how long a client is asked to wait before retrying a request that failed because the
+// state storage couldn't be reached.
+const UNAVAILABLE_RETRY_DELAY = time.Second
+
+// BUSY_RETRY_DELAY is how long a client is asked to wait before retrying a request that was turned away because
+// the server, or the client's stream, was busy.
+const BUSY_RETRY_DELAY = 5 * time.Second
+
+// fieldViolation describes what is wrong with one field of a request.
+func fieldViolation(field string, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	}
+}
+
+// badRequest returns a status error with code for a request with invalid fields, carrying a BadRequest detail that
+// lists them.
+func badRequest(code codes.Code, violations ...*errdetails.BadRequest_FieldViolation) error {
+	descriptions := make([]string, len(violations))
+	for i, v := range violations {
+		descriptions[i] = v.Field + ": " + v.Description
+	}
+
+	s := status.New(code, "invalid request, "+strings.Join(descriptions, "; "))
+	detailed, err := s.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return s.Err()
+	}
+
+	return detailed.Err()
+}
+
+// retryableError returns a status error with code, carrying a RetryInfo detail that asks the client to wait delay
+// before retrying.
+func retryableError(code codes.Code, delay time.Duration, message string) error {
+	s := status.New(code, message)
+	detailed, err := s.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		return s.Err()
+	}
+
+	return detailed.Err()
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/fairness"
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+	"github.com/jamesrobb/ably-takehome/storagetest"
+)
+
+// CONFORMANCE_SOURCE is the script source registered to store a state taken from a source other than a generator.
+const CONFORMANCE_SOURCE = "conformance-script"
+
+func init() {
+	if err := registerSource(CONFORMANCE_SOURCE + "=script:3 1 4 1 5"); err != nil {
+		panic(err)
+	}
+}
+
+// conformanceStates describes the server's State to the conformance checks.
+type conformanceStates struct{}
+
+func (conformanceStates) New(seed uint32, numbersSent uint32, epoch uint64, lastUpdated time.Time) *State {
+	s := conformanceState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(seed), nil, numbersSent, protocol.ChecksumAlgorithm_MD5_LEGACY, lastUpdated)
+	s.epoch = epoch
+
+	return s
+}
+
+func (conformanceStates) Variants(lastUpdated time.Time) []storagetest.Variant[*State] {
+	var variants []storagetest.Variant[*State]
+
+	// Each checksum algorithm has its own chain value or midstate to keep.
+	for i := range protocol.ChecksumAlgorithm_name {
+		algorithm := protocol.ChecksumAlgorithm(i)
+		s := conformanceState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(2596996162), nil, 5, algorithm, lastUpdated)
+		s.epoch = 1<<32 | 7
+		s.deadline = lastUpdated.Add(time.Hour)
+		variants = append(variants, storagetest.Variant[*State]{Name: algorithm.String(), State: s, Continues: true})
+	}
+
+	serverSeed := bytes.Repeat([]byte{0xa5}, fairness.SERVER_SEED_SIZE)
+	s := conformanceState(generator.Name(protocol.PrngAlgorithm_MT19937), fairness.Seed(serverSeed, []byte("client")), nil, 5, protocol.ChecksumAlgorithm_SHA256, lastUpdated)
+	s.serverSeed = serverSeed
+	s.seedNonce = bytes.Repeat([]byte{0x5a}, fairness.NONCE_SIZE)
+	variants = append(variants, storagetest.Variant[*State]{Name: "server seed", State: s, Continues: true})
+
+	s = conformanceState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(7), nil, 40, protocol.ChecksumAlgorithm_SHA256, lastUpdated)
+	for index := uint32(5); index <= 40; index += 5 {
+		s.recordCheckpoint(index, fmt.Sprintf("%064x", index))
+	}
+	variants = append(variants, storagetest.Variant[*State]{Name: "checkpoints", State: s, Continues: true})
+
+	s = conformanceState(generator.Name(protocol.PrngAlgorithm_MT19937), generator.Uint32Seed(7), nil, 3*HASH_ANCHOR_INTERVAL+5, protocol.ChecksumAlgorithm_MD5_LEGACY, lastUpdated)
+	variants = append(variants, storagetest.Variant[*State]{Name: "hash anchors", State: s, Continues: true})
+
+	// Each generator has its own internal state to keep, and each seed width is seeded differently.
+	for i := range protocol.PrngAlgorithm_name {
+		algorithm := protocol.PrngAlgorithm(i)
+		seeds := []generator.Seed{generator.Uint32Seed(7), {0, 0, 0, 0, 0, 0, 0, 7}, bytes.Repeat([]byte{7}, 32)}
+		if !generator.Replayable(algorithm) {
+			seeds = []generator.Seed{nil}
+		}
+		for _, seed := range seeds {
+			variants = append(variants, storagetest.Variant[*State]{
+				Name:  fmt.Sprintf("%s with a %d bit seed", algorithm, seed.Bits()),
+				State: conformanceState(generator.Name(algorithm), seed, nil, 5, protocol.ChecksumAlgorithm_SHA256, lastUpdated),
+				// Only a replayable generator carries on with the same numbers.
+				Continues: generator.Replayable(algorithm),
+			})
+		}
+	}
+
+	// Values of every type, and sample, which has to remember the integers it has moved to know which are left.
+	for _, spec := range []string{"uniform:1:6", "sample:-10:65535", "gaussian:0:1", "exponential:0.5", "poisson:4", "poisson:1000"} {
+		dist, _ := distribution.Parse(spec)
+		variants = append(variants, storagetest.Variant[*State]{
+			Name:      spec,
+			State:     conformanceState(generator.Name(protocol.PrngAlgorithm_XOSHIRO256_PLUS_PLUS), generator.Uint32Seed(7), dist, 5, protocol.ChecksumAlgorithm_SHA256, lastUpdated),
+			Continues: true,
+		})
+	}
+
+	// The script's cursor has to be kept, both for raw numbers and for values drawn from them.
+	for _, spec := range []string{"", "uniform:1:6"} {
+		dist, _ := distribution.Parse(spec)
+		variants = append(variants, storagetest.Variant[*State]{
+			Name:      fmt.Sprintf("script source %q", spec),
+			State:     conformanceState(CONFORMANCE_SOURCE, nil, dist, 7, protocol.ChecksumAlgorithm_SHA256, lastUpdated),
+			Continues: true,
+		})
+	}
+
+	return variants
+}
+
+func (conformanceStates) Advance(s *State, now time.Time) {
+	s.advance()
+	s.lastUpdated = now
+}
+
+func (conformanceStates) NumbersSent(s *State) uint32 {
+	return s.numbersSent
+}
+
+// Compare returns an error describing the first difference between got and want.
+func (conformanceStates) Compare(got *State, want *State) error {
+	switch {
+	case got.sourceName != want.sourceName:
+		return fmt.Errorf("source %q, want %q", got.sourceName, want.sourceName)
+	case !bytes.Equal(got.seed, want.seed):
+		return fmt.Errorf("seed=%x, want %x", []byte(got.seed), []byte(want.seed))
+	case !proto.Equal(got.values.Distribution(), want.values.Distribution()):
+		return fmt.Errorf("distribution %s, want %s", distribution.String(got.values.Distribution()), distribution.String(want.values.Distribution()))
+	case got.numbersSent != want.numbersSent:
+		return fmt.Errorf("numbersSent=%d, want %d", got.numbersSent, want.numbersSent)
+	case got.totalNumbers != want.totalNumbers:
+		return fmt.Errorf("totalNumbers=%d, want %d", got.totalNumbers, want.totalNumbers)
+	case !got.deadline.Equal(want.deadline):
+		return fmt.Errorf("deadline=%s, want %s", got.deadline, want.deadline)
+	case want.source.Replayable() && got.values.Read() != want.values.Read():
+		return fmt.Errorf("source position %d, want %d", got.values.Read(), want.values.Read())
+	case got.nextValue != want.nextValue:
+		return fmt.Errorf("nextValue=%#x, want %#x", got.nextValue, want.nextValue)
+	case !got.lastUpdated.Equal(want.lastUpdated):
+		return fmt.Errorf("lastUpdated=%s, want %s", got.lastUpdated, want.lastUpdated)
+	case got.epoch != want.epoch:
+		return fmt.Errorf("epoch=%d, want %d", got.epoch, want.epoch)
+	case got.hash.Algorithm() != want.hash.Algorithm():
+		return fmt.Errorf("checksum algorithm %s, want %s", got.hash.Algorithm(), want.hash.Algorithm())
+	case got.hash.Sum() != want.hash.Sum():
+		return fmt.Errorf("checksum %s, want %s", got.hash.Sum(), want.hash.Sum())
+	case !bytes.Equal(got.serverSeed, want.serverSeed):
+		return fmt.Errorf("serverSeed=%x, want %x", got.serverSeed, want.serverSeed)
+	case !bytes.Equal(got.seedNonce, want.seedNonce):
+		return fmt.Errorf("seedNonce=%x, want %x", got.seedNonce, want.seedNonce)
+	case fmt.Sprint(got.checkpoints) != fmt.Sprint(want.checkpoints):
+		return fmt.Errorf("checkpoints %v, want %v", got.checkpoints, want.checkpoints)
+	case fmt.Sprint(got.values.Swapped()) != fmt.Sprint(want.values.Swapped()):
+		return fmt.Errorf("sample moved %d integers, want %d", len(got.values.Swapped()), len(want.values.Swapped()))
+	case fmt.Sprint(got.anchors) != fmt.Sprint(want.anchors):
+		return fmt.Errorf("anchors %v, want %v", got.anchors, want.anchors)
+	}
+
+	return nil
+}
+
+// conformanceState returns a state for a sequence of values drawn from dist over the numbers of the source
+// registered as sourceName, checksummed with algorithm, with numbersSent numbers sent.
+func conformanceState(sourceName string, seed generator.Seed, dist *protocol.Distribution, numbersSent uint32, algorithm protocol.ChecksumAlgorithm, lastUpdated time.Time) *State {
+	s := newState(sourceName, seed, dist, DEFAULT_MAX_NUMBERS, algorithm)
+	for s.numbersSent < numbersSent {
+		s.advance()
+	}
+	s.lastUpdated = lastUpdated
+
+	return s
+}
+
+// conformanceConfig returns the configuration the conformance checks run each storage with.
+func conformanceConfig(newStorage func(clock storagetest.Clock) (storagetest.Storage[*State], error)) storagetest.Config[*State] {
+	return storagetest.Config[*State]{
+		NewStorage:  newStorage,
+		States:      conformanceStates{},
+		TTL:         GARBGAGE_TIMEOUT,
+		ErrNotFound: ErrNotFound,
+		ErrExpired:  ErrExpired,
+		ErrFenced:   ErrFenced,
+	}
+}
+
+func TestInMemoryStorageConformance(t *testing.T) {
+	storagetest.Run(t, conformanceConfig(func(clock storagetest.Clock) (storagetest.Storage[*State], error) {
+		return NewInMemoryStorage(InMemoryStorageConfig{
+			Shards:         4,
+			TombstoneTTL:   time.Hour,
+			ExpiryInterval: time.Millisecond,
+			Clock:          clock,
+		}), nil
+	}))
+}
+
+func TestFileStorageConformance(t *testing.T) {
+	storagetest.Run(t, conformanceConfig(func(clock storagetest.Clock) (storagetest.Storage[*State], error) {
+		return NewFileStorage(FileStorageConfig{
+			Dir:            t.TempDir(),
+			Fsync:          FSYNC_NEVER,
+			SnapshotEvery:  16,
+			TombstoneTTL:   time.Hour,
+			ExpiryInterval: time.Millisecond,
+			Clock:          clock,
+		})
+	}))
+}
+
+func TestRedisStorageConformance(t *testing.T) {
+	storagetest.Run(t, conformanceConfig(func(clock storagetest.Clock) (storagetest.Storage[*State], error) {
+		// The fake server has to share the storage's clock for native TTLs to follow it.
+		lis, err := storagetest.StartFakeRedis("localhost:0", clock)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { lis.Close() })
+
+		return NewRedisStorage(RedisStorageConfig{
+			Addr:         lis.Addr().String(),
+			KeyPrefix:    "conformance:",
+			TombstoneTTL: time.Hour,
+			PoolSize:     4,
+			Timeout:      REDIS_TIMEOUT,
+			Clock:        clock,
+		})
+	}))
+}
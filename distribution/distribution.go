@@ -0,0 +1,632 @@
+// Package distribution draws the values of a sequence from the output of its generator, as the Distribution of a
+// NumbersRequest asks. The server and the client both use it, so that a client can draw a sequence again from its
+// seed.
+//
+// Every distribution reads the generator 32 bits at a time:
+//
+//   - A float u in [0, 1) is made from two numbers a and b as ((a >> 5) * 2^26 + (b >> 6)) / 2^53.
+//   - An integer in [0, n) is drawn by rejection, so that every integer is equally likely. For n of at most 2^32 a
+//     number x is drawn until x < 2^32 - (2^32 mod n), and the integer is x mod n. A larger n draws x from two
+//     numbers, the high 32 bits first, with 2^64 in place of 2^32.
+//   - uniform is min plus an integer in [0, max - min].
+//   - sample shuffles the range as it goes, with a Fisher-Yates shuffle that only keeps track of the positions it has
+//     moved. With the range's integers at positions 0 to n - 1, the value at index i (counting from 0) swaps
+//     position i with position i + j, for j an integer in [0, n - i), and is the integer that lands at position i.
+//   - gaussian uses the Box-Muller transform of two floats u and v: mean + stddev * sqrt(-2 ln(1 - u)) * cos(2 pi v).
+//   - exponential is -ln(1 - u) / rate for a float u.
+//   - poisson multiplies floats together until their product is at most exp(-lambda), and is the number of floats
+//     multiplied less one, for lambda below 10. Otherwise it uses the PTRS transformed rejection of Hörmann's "The
+//     transformed rejection method for generating Poisson random variables".
+//
+// Floating point arithmetic is done in IEEE 754 double precision without fused operations, with Go's math package
+// for logarithms, exponentials, square roots and cosines.
+package distribution
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// MAX_LAMBDA is the largest lambda a Poisson distribution may have.
+const MAX_LAMBDA = 1e9
+
+// ENCODED_SIZE is the size in bytes of a distribution encoded by AppendBinary.
+const ENCODED_SIZE = 17
+
+// The kinds of distribution, numbered as the fields of Distribution's kind. NONE is the generator's raw output.
+const (
+	NONE        byte = 0
+	UNIFORM     byte = 1
+	SAMPLE      byte = 2
+	GAUSSIAN    byte = 3
+	EXPONENTIAL byte = 4
+	POISSON     byte = 5
+)
+
+// kind returns the kind of d, NONE for nil or for a Distribution without a kind.
+func kind(d *protocol.Distribution) byte {
+	switch d.GetKind().(type) {
+	case *protocol.Distribution_Uniform:
+		return UNIFORM
+	case *protocol.Distribution_Sample:
+		return SAMPLE
+	case *protocol.Distribution_Gaussian:
+		return GAUSSIAN
+	case *protocol.Distribution_Exponential:
+		return EXPONENTIAL
+	case *protocol.Distribution_Poisson:
+		return POISSON
+	}
+
+	return NONE
+}
+
+// ValueType returns the type of the values d draws, UINT32 when d is nil.
+func ValueType(d *protocol.Distribution) protocol.ValueType {
+	switch kind(d) {
+	case UNIFORM, SAMPLE, POISSON:
+		return protocol.ValueType_INT64
+	case GAUSSIAN, EXPONENTIAL:
+		return protocol.ValueType_FLOAT64
+	}
+
+	return protocol.ValueType_UINT32
+}
+
+// span returns the number of integers in r, where 0 stands for 2^64.
+func span(r *protocol.Range) uint64 {
+	return uint64(r.Max) - uint64(r.Min) + 1
+}
+
+// Check returns an error unless a sequence of total values can be drawn from d over a generator whose numbers
+// replayable says can be generated again. A nil d is the generator's raw output, which always can.
+func Check(d *protocol.Distribution, replayable bool, total uint32) error {
+	if d == nil {
+		return nil
+	}
+
+	finite := func(v float64) bool { return !math.IsNaN(v) && !math.IsInf(v, 0) }
+	switch k := d.Kind.(type) {
+	case *protocol.Distribution_Uniform:
+		if k.Uniform.Min > k.Uniform.Max {
+			return fmt.Errorf("uniform range min %d is greater than max %d", k.Uniform.Min, k.Uniform.Max)
+		}
+	case *protocol.Distribution_Sample:
+		if k.Sample.Min > k.Sample.Max {
+			return fmt.Errorf("sample range min %d is greater than max %d", k.Sample.Min, k.Sample.Max)
+		}
+		if n := span(k.Sample); n != 0 && uint64(total) > n {
+			return fmt.Errorf("cannot sample %d values without replacement from a range of %d", total, n)
+		}
+		// The values left to sample depend on every value drawn so far, which a resumed stream has to draw again.
+		if !replayable {
+			return fmt.Errorf("numbers that can't be generated again can't be sampled without replacement")
+		}
+	case *protocol.Distribution_Gaussian:
+		if !finite(k.Gaussian.Mean) || !finite(k.Gaussian.Stddev) || k.Gaussian.Stddev <= 0 {
+			return fmt.Errorf("gaussian must have a finite mean and a finite stddev greater than 0")
+		}
+	case *protocol.Distribution_Exponential:
+		if !finite(k.Exponential.Rate) || k.Exponential.Rate <= 0 {
+			return fmt.Errorf("exponential must have a finite rate greater than 0")
+		}
+	case *protocol.Distribution_Poisson:
+		if !(k.Poisson.Lambda > 0 && k.Poisson.Lambda <= MAX_LAMBDA) {
+			return fmt.Errorf("poisson must have a lambda greater than 0 and at most %g", float64(MAX_LAMBDA))
+		}
+	default:
+		return fmt.Errorf("must set one of uniform, sample, gaussian, exponential or poisson")
+	}
+
+	return nil
+}
+
+// Source is the generator a Sampler draws from. generator.PRNG is the Source of every generator.
+type Source interface {
+	// Uint32 returns the next number.
+	Uint32() uint32
+	// Skip moves the Source past the next n numbers.
+	Skip(n uint32)
+	// Replayable reports whether the numbers can be generated again.
+	Replayable() bool
+}
+
+// Sampler draws the values of a sequence from its generator, positioned at the start of the sequence when created.
+type Sampler struct {
+	d    *protocol.Distribution
+	prng Source
+	// drawn is the number of values drawn so far.
+	drawn uint64
+	// read is the number of numbers read from prng so far, skipped ones included.
+	read uint64
+	// swapped holds the integers sample has moved, by position, for the positions from drawn on.
+	swapped map[uint64]uint64
+}
+
+// New returns the Sampler of d over the output of prng, which Check must accept.
+func New(d *protocol.Distribution, prng Source) *Sampler {
+	s := &Sampler{d: d, prng: prng}
+	if kind(d) == SAMPLE {
+		s.swapped = make(map[uint64]uint64)
+	}
+
+	return s
+}
+
+// Resume returns the Sampler of d over the output of prng that has drawn drawn values by reading read numbers, as
+// Read returned them. prng must be positioned at the start of its sequence, and is skipped past the numbers read. A
+// prng whose numbers can't be generated again must instead already be positioned after them. For sample, swapped
+// is what Swapped returned, which the Sampler takes over. It is ignored for every other distribution.
+func Resume(d *protocol.Distribution, prng Source, drawn uint64, read uint64, swapped map[uint64]uint64) *Sampler {
+	s := New(d, prng)
+	s.drawn, s.read = drawn, read
+	if s.swapped != nil && swapped != nil {
+		s.swapped = swapped
+	}
+	// Skip takes at most 2^32 - 1 numbers at a time, which a distribution drawing several numbers a value can read
+	// more than.
+	for ; read > math.MaxUint32; read -= math.MaxUint32 {
+		prng.Skip(math.MaxUint32)
+	}
+	prng.Skip(uint32(read))
+
+	return s
+}
+
+// Clone returns a Sampler that draws the rest of the sequence from prng, a copy of the generator s draws from
+// positioned where it is.
+func (s *Sampler) Clone(prng Source) *Sampler {
+	c := &Sampler{d: s.d, prng: prng, drawn: s.drawn, read: s.read}
+	if s.swapped != nil {
+		c.swapped = make(map[uint64]uint64, len(s.swapped))
+		for position, v := range s.swapped {
+			c.swapped[position] = v
+		}
+	}
+
+	return c
+}
+
+// Distribution returns the distribution s draws from, nil for the generator's raw output.
+func (s *Sampler) Distribution() *protocol.Distribution {
+	return s.d
+}
+
+// Read returns the number of numbers s has read from its generator, which is how far a generator created the same
+// way has to be skipped to carry on from where s's is.
+func (s *Sampler) Read() uint64 {
+	return s.read
+}
+
+// Swapped returns the integers sample has moved, by position, which together with Read is all a sample has to
+// remember of the values it has drawn. It is nil for every other distribution, and must not be modified.
+func (s *Sampler) Swapped() map[uint64]uint64 {
+	return s.swapped
+}
+
+// Next draws the next value of the sequence, as the 64 bits the checksum package takes it as.
+func (s *Sampler) Next() uint64 {
+	s.drawn++
+
+	switch k := s.d.GetKind().(type) {
+	case *protocol.Distribution_Uniform:
+		return uint64(k.Uniform.Min) + s.below(span(k.Uniform))
+	case *protocol.Distribution_Sample:
+		return uint64(k.Sample.Min) + s.sample(span(k.Sample))
+	case *protocol.Distribution_Gaussian:
+		r := math.Sqrt(-2 * math.Log(1-s.float()))
+		z := float64(r * math.Cos(float64(2*math.Pi*s.float())))
+		return math.Float64bits(k.Gaussian.Mean + float64(k.Gaussian.Stddev*z))
+	case *protocol.Distribution_Exponential:
+		return math.Float64bits(-math.Log(1-s.float()) / k.Exponential.Rate)
+	case *protocol.Distribution_Poisson:
+		return uint64(s.poisson(k.Poisson.Lambda))
+	}
+
+	return uint64(s.uint32())
+}
+
+// Skip moves s past the next n values of the sequence. Values drawn from numbers that can't be generated again are
+// simply never drawn, so skipping them does nothing.
+func (s *Sampler) Skip(n uint32) {
+	if s.d == nil {
+		s.prng.Skip(n)
+		s.drawn += uint64(n)
+		s.read += uint64(n)
+		return
+	}
+	if !s.prng.Replayable() {
+		s.drawn += uint64(n)
+		return
+	}
+	for i := uint32(0); i < n; i++ {
+		s.Next()
+	}
+}
+
+// uint32 reads the next number from the generator.
+func (s *Sampler) uint32() uint32 {
+	s.read++
+
+	return s.prng.Uint32()
+}
+
+// uint64 draws 64 bits from two numbers, the high 32 bits first.
+func (s *Sampler) uint64() uint64 {
+	high := uint64(s.uint32())
+
+	return high<<32 | uint64(s.uint32())
+}
+
+// float draws a float in [0, 1) with 53 random bits.
+func (s *Sampler) float() float64 {
+	a, b := s.uint32()>>5, s.uint32()>>6
+
+	return float64(uint64(a)<<26|uint64(b)) / (1 << 53)
+}
+
+// below draws an integer in [0, n) without bias, where n of 0 stands for 2^64.
+func (s *Sampler) below(n uint64) uint64 {
+	if n == 0 {
+		return s.uint64()
+	}
+	if n <= 1<<32 {
+		limit := uint64(1)<<32 - (uint64(1)<<32)%n
+		for {
+			if x := uint64(s.uint32()); x < limit {
+				return x % n
+			}
+		}
+	}
+
+	// -n % n is 2^64 mod n, so -r is 2^64 - r unless r is 0, when every draw is accepted.
+	r := -n % n
+	for {
+		if x := s.uint64(); r == 0 || x < -r {
+			return x % n
+		}
+	}
+}
+
+// sample draws the next integer of a shuffle of [0, n), where n of 0 stands for 2^64.
+func (s *Sampler) sample(n uint64) uint64 {
+	i := s.drawn - 1
+	j := i + s.below(n-i)
+
+	at := func(position uint64) uint64 {
+		if v, ok := s.swapped[position]; ok {
+			return v
+		}
+		return position
+	}
+	vi, vj := at(i), at(j)
+	// Position i is never looked at again, so only the integer moved to position j is kept.
+	s.swapped[j] = vi
+	delete(s.swapped, i)
+
+	return vj
+}
+
+// poisson draws from the Poisson distribution with mean lambda.
+func (s *Sampler) poisson(lambda float64) int64 {
+	if lambda < 10 {
+		limit := math.Exp(-lambda)
+		k := int64(0)
+		for p := s.float(); p > limit; p = float64(p * s.float()) {
+			k++
+		}
+		return k
+	}
+
+	slam := math.Sqrt(lambda)
+	loglam := math.Log(lambda)
+	b := 0.931 + float64(2.53*slam)
+	a := -0.059 + float64(0.02483*b)
+	invalpha := 1.1239 + 1.1328/(b-3.4)
+	vr := 0.9277 - 3.6224/(b-2)
+
+	for {
+		u := s.float() - 0.5
+		v := s.float()
+		us := 0.5 - math.Abs(u)
+		k := math.Floor(float64(float64(2*a/us+b)*u) + lambda + 0.43)
+		if us >= 0.07 && v <= vr {
+			return int64(k)
+		}
+		if k < 0 || (us < 0.013 && v > us) {
+			continue
+		}
+		lg, _ := math.Lgamma(k + 1)
+		if math.Log(v)+math.Log(invalpha)-math.Log(a/float64(us*us)+b) <= -lambda+float64(k*loglam)-lg {
+			return int64(k)
+		}
+	}
+}
+
+// Sequence returns the first n values d draws from the numbers algorithm generates from seed.
+func Sequence(algorithm protocol.PrngAlgorithm, seed generator.Seed, d *protocol.Distribution, n uint32) ([]uint64, error) {
+	if !generator.Replayable(algorithm) {
+		return nil, fmt.Errorf("%s can't generate a sequence again", algorithm)
+	}
+	if err := Check(d, true, n); err != nil {
+		return nil, err
+	}
+	p, err := generator.New(algorithm, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	s := New(d, p)
+	values := make([]uint64, n)
+	for i := range values {
+		values[i] = s.Next()
+	}
+
+	return values, nil
+}
+
+// Verify returns an error if values, a sequence received from its first value on, can't have been drawn from d: if
+// an integer is outside of uniform's or sample's range, or sample repeats an integer.
+func Verify(d *protocol.Distribution, values []uint64) error {
+	var r *protocol.Range
+	switch k := d.GetKind().(type) {
+	case *protocol.Distribution_Uniform:
+		r = k.Uniform
+	case *protocol.Distribution_Sample:
+		r = k.Sample
+	default:
+		return nil
+	}
+
+	seen := make(map[int64]uint32)
+	for i, value := range values {
+		v, index := int64(value), uint32(i)+1
+		if v < r.Min || v > r.Max {
+			return fmt.Errorf("value %d at index %d is outside of %s", v, index, String(d))
+		}
+		if first, ok := seen[v]; ok && kind(d) == SAMPLE {
+			return fmt.Errorf("value %d at index %d repeats the value at index %d, but %s draws without replacement", v, index, first, String(d))
+		}
+		seen[v] = index
+	}
+
+	return nil
+}
+
+// Format returns value, of type valueType, in decimal.
+func Format(valueType protocol.ValueType, value uint64) string {
+	switch valueType {
+	case protocol.ValueType_INT64:
+		return strconv.FormatInt(int64(value), 10)
+	case protocol.ValueType_FLOAT64:
+		return strconv.FormatFloat(math.Float64frombits(value), 'g', -1, 64)
+	}
+
+	return strconv.FormatUint(uint64(uint32(value)), 10)
+}
+
+// Values returns the values response carries, of type valueType, whether or not it is a batch.
+func Values(response *protocol.NumberResponse, valueType protocol.ValueType) []uint64 {
+	var values []uint64
+	switch valueType {
+	case protocol.ValueType_INT64:
+		for _, v := range response.IntValues {
+			values = append(values, uint64(v))
+		}
+		if values == nil {
+			values = []uint64{uint64(response.IntValue)}
+		}
+	case protocol.ValueType_FLOAT64:
+		for _, v := range response.FloatValues {
+			values = append(values, math.Float64bits(v))
+		}
+		if values == nil {
+			values = []uint64{math.Float64bits(response.FloatValue)}
+		}
+	default:
+		for _, v := range response.Numbers {
+			values = append(values, uint64(v))
+		}
+		if values == nil {
+			values = []uint64{uint64(response.Number)}
+		}
+	}
+
+	return values
+}
+
+// SetValues sets the values response carries to values, of type valueType, as a batch when batch is set and
+// otherwise as its one value.
+func SetValues(response *protocol.NumberResponse, valueType protocol.ValueType, values []uint64, batch bool) {
+	switch {
+	case valueType == protocol.ValueType_INT64 && batch:
+		response.IntValues = make([]int64, len(values))
+		for i, v := range values {
+			response.IntValues[i] = int64(v)
+		}
+	case valueType == protocol.ValueType_INT64:
+		response.IntValue = int64(values[0])
+	case valueType == protocol.ValueType_FLOAT64 && batch:
+		response.FloatValues = make([]float64, len(values))
+		for i, v := range values {
+			response.FloatValues[i] = math.Float64frombits(v)
+		}
+	case valueType == protocol.ValueType_FLOAT64:
+		response.FloatValue = math.Float64frombits(values[0])
+	case batch:
+		response.Numbers = make([]uint32, len(values))
+		for i, v := range values {
+			response.Numbers[i] = uint32(v)
+		}
+	default:
+		response.Number = uint32(values[0])
+	}
+}
+
+// Count returns the number of values response carries.
+func Count(response *protocol.NumberResponse) uint32 {
+	if n := len(response.Numbers) + len(response.IntValues) + len(response.FloatValues); n > 0 {
+		return uint32(n)
+	}
+
+	return 1
+}
+
+// names are the names of the kinds of distribution, as they are given on the command line.
+var names = map[byte]string{
+	UNIFORM:     "uniform",
+	SAMPLE:      "sample",
+	GAUSSIAN:    "gaussian",
+	EXPONENTIAL: "exponential",
+	POISSON:     "poisson",
+}
+
+// Parse parses a distribution given on the command line, as one of uniform:MIN:MAX, sample:MIN:MAX,
+// gaussian:MEAN:STDDEV, exponential:RATE or poisson:LAMBDA. An empty spec is the generator's raw output, nil.
+func Parse(spec string) (*protocol.Distribution, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(spec, ":")
+	ints := func() (int64, int64, error) {
+		if len(fields) != 3 {
+			return 0, 0, fmt.Errorf("%s takes a min and a max", fields[0])
+		}
+		min, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid min %q", fields[1])
+		}
+		max, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid max %q", fields[2])
+		}
+		return min, max, nil
+	}
+	floats := func(n int) ([]float64, error) {
+		if len(fields) != n+1 {
+			return nil, fmt.Errorf("%s takes %d parameters", fields[0], n)
+		}
+		params := make([]float64, n)
+		for i := range params {
+			v, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parameter %q", fields[i+1])
+			}
+			params[i] = v
+		}
+		return params, nil
+	}
+
+	d := &protocol.Distribution{}
+	switch fields[0] {
+	case names[UNIFORM], names[SAMPLE]:
+		min, max, err := ints()
+		if err != nil {
+			return nil, err
+		}
+		if fields[0] == names[UNIFORM] {
+			d.Kind = &protocol.Distribution_Uniform{Uniform: &protocol.Range{Min: min, Max: max}}
+		} else {
+			d.Kind = &protocol.Distribution_Sample{Sample: &protocol.Range{Min: min, Max: max}}
+		}
+	case names[GAUSSIAN]:
+		params, err := floats(2)
+		if err != nil {
+			return nil, err
+		}
+		d.Kind = &protocol.Distribution_Gaussian{Gaussian: &protocol.Gaussian{Mean: params[0], Stddev: params[1]}}
+	case names[EXPONENTIAL]:
+		params, err := floats(1)
+		if err != nil {
+			return nil, err
+		}
+		d.Kind = &protocol.Distribution_Exponential{Exponential: &protocol.Exponential{Rate: params[0]}}
+	case names[POISSON]:
+		params, err := floats(1)
+		if err != nil {
+			return nil, err
+		}
+		d.Kind = &protocol.Distribution_Poisson{Poisson: &protocol.Poisson{Lambda: params[0]}}
+	default:
+		return nil, fmt.Errorf("unknown distribution %q, expected one of uniform, sample, gaussian, exponential or poisson", fields[0])
+	}
+
+	return d, nil
+}
+
+// String returns d as Parse takes it, or "none" for the generator's raw output.
+func String(d *protocol.Distribution) string {
+	switch k := d.GetKind().(type) {
+	case *protocol.Distribution_Uniform:
+		return fmt.Sprintf("%s:%d:%d", names[UNIFORM], k.Uniform.Min, k.Uniform.Max)
+	case *protocol.Distribution_Sample:
+		return fmt.Sprintf("%s:%d:%d", names[SAMPLE], k.Sample.Min, k.Sample.Max)
+	case *protocol.Distribution_Gaussian:
+		return fmt.Sprintf("%s:%g:%g", names[GAUSSIAN], k.Gaussian.Mean, k.Gaussian.Stddev)
+	case *protocol.Distribution_Exponential:
+		return fmt.Sprintf("%s:%g", names[EXPONENTIAL], k.Exponential.Rate)
+	case *protocol.Distribution_Poisson:
+		return fmt.Sprintf("%s:%g", names[POISSON], k.Poisson.Lambda)
+	}
+
+	return "none"
+}
+
+// AppendBinary appends d to data in ENCODED_SIZE bytes: its kind as a byte, then two parameters of 8 bytes in
+// big-endian order. They are min and max for uniform and sample, mean and stddev for gaussian, and rate or lambda
+// followed by zeros for exponential and poisson. The generator's raw output is all zeros.
+func AppendBinary(data []byte, d *protocol.Distribution) []byte {
+	var a, b uint64
+	switch k := d.GetKind().(type) {
+	case *protocol.Distribution_Uniform:
+		a, b = uint64(k.Uniform.Min), uint64(k.Uniform.Max)
+	case *protocol.Distribution_Sample:
+		a, b = uint64(k.Sample.Min), uint64(k.Sample.Max)
+	case *protocol.Distribution_Gaussian:
+		a, b = math.Float64bits(k.Gaussian.Mean), math.Float64bits(k.Gaussian.Stddev)
+	case *protocol.Distribution_Exponential:
+		a = math.Float64bits(k.Exponential.Rate)
+	case *protocol.Distribution_Poisson:
+		a = math.Float64bits(k.Poisson.Lambda)
+	}
+
+	data = append(data, kind(d))
+	data = binary.BigEndian.AppendUint64(data, a)
+
+	return binary.BigEndian.AppendUint64(data, b)
+}
+
+// Decode decodes a distribution encoded by AppendBinary.
+func Decode(data []byte) (*protocol.Distribution, error) {
+	if len(data) != ENCODED_SIZE {
+		return nil, fmt.Errorf("encoded distribution must be %d bytes, got %d", ENCODED_SIZE, len(data))
+	}
+	a, b := binary.BigEndian.Uint64(data[1:9]), binary.BigEndian.Uint64(data[9:17])
+
+	d := &protocol.Distribution{}
+	switch data[0] {
+	case NONE:
+		return nil, nil
+	case UNIFORM:
+		d.Kind = &protocol.Distribution_Uniform{Uniform: &protocol.Range{Min: int64(a), Max: int64(b)}}
+	case SAMPLE:
+		d.Kind = &protocol.Distribution_Sample{Sample: &protocol.Range{Min: int64(a), Max: int64(b)}}
+	case GAUSSIAN:
+		d.Kind = &protocol.Distribution_Gaussian{Gaussian: &protocol.Gaussian{Mean: math.Float64frombits(a), Stddev: math.Float64frombits(b)}}
+	case EXPONENTIAL:
+		d.Kind = &protocol.Distribution_Exponential{Exponential: &protocol.Exponential{Rate: math.Float64frombits(a)}}
+	case POISSON:
+		d.Kind = &protocol.Distribution_Poisson{Poisson: &protocol.Poisson{Lambda: math.Float64frombits(a)}}
+	default:
+		return nil, fmt.Errorf("unknown distribution kind %d", data[0])
+	}
+
+	return d, nil
+}
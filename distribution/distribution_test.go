@@ -0,0 +1,143 @@
+package distribution
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// script is a Source that returns its numbers in turn.
+type script struct {
+	numbers []uint32
+}
+
+func (s *script) Uint32() uint32 {
+	n := s.numbers[0]
+	s.numbers = s.numbers[1:]
+
+	return n
+}
+
+func (s *script) Skip(n uint32) {
+	s.numbers = s.numbers[n:]
+}
+
+func (s *script) Replayable() bool {
+	return true
+}
+
+// TestNext checks the values each distribution draws from given numbers, worked out by hand from the package
+// documentation, and that every number is read.
+func TestNext(t *testing.T) {
+	const half = 1 << 31 // A float of 0.5 with 0.
+
+	for _, test := range []struct {
+		spec    string
+		numbers []uint32
+		want    []uint64
+	}{
+		{"", []uint32{7, 1<<32 - 1}, []uint64{7, 1<<32 - 1}},
+		// 2^32 - 1 is rejected, as 2^32 mod 6 is 4.
+		{"uniform:1:6", []uint32{1<<32 - 1, 10}, []uint64{5}},
+		{"uniform:-3:-3", []uint32{12345}, []uint64{math.MaxUint64 - 2}},
+		// 2^64 integers read two numbers, the high 32 bits first.
+		{"uniform:-9223372036854775808:9223372036854775807", []uint32{1, 2}, []uint64{1<<63 | 1<<32 | 2}},
+		// Position 0 swaps with 2, position 1 with itself and position 2 is what is left.
+		{"sample:0:2", []uint32{2, 0, 5}, []uint64{2, 1, 0}},
+		{"sample:10:12", []uint32{2, 0, 5}, []uint64{12, 11, 10}},
+		// sqrt(-2 ln 0.5) * cos(0).
+		{"gaussian:0:1", []uint32{half, 0, 0, 0}, []uint64{math.Float64bits(1.1774100225154747)}},
+		{"gaussian:10:2", []uint32{half, 0, 0, 0}, []uint64{math.Float64bits(12.354820045030949)}},
+		// -ln(0.5) / 2.
+		{"exponential:2", []uint32{half, 0}, []uint64{math.Float64bits(0.34657359027997264)}},
+		// 0.5 is above exp(-1), 0.25 isn't.
+		{"poisson:1", []uint32{half, 0, half, 0}, []uint64{1}},
+	} {
+		d, err := Parse(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source := &script{numbers: test.numbers}
+		s := New(d, source)
+		for i, want := range test.want {
+			if got := s.Next(); got != want {
+				t.Errorf("%s: value %d is %#x, want %#x", String(d), i+1, got, want)
+			}
+		}
+		if len(source.numbers) != 0 || s.Read() != uint64(len(test.numbers)) {
+			t.Errorf("%s: read %d numbers, want %d", String(d), s.Read(), len(test.numbers))
+		}
+	}
+}
+
+// TestSequence checks the values drawn from the first number of MT19937 seeded with 5489, 3499211612.
+func TestSequence(t *testing.T) {
+	for _, test := range []struct {
+		spec string
+		want uint64
+	}{
+		{"", 3499211612},
+		{"uniform:1:6", 3},
+		{"uniform:0:999", 612},
+	} {
+		d, _ := Parse(test.spec)
+		values, err := Sequence(protocol.PrngAlgorithm_MT19937, generator.Uint32Seed(5489), d, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values[0] != test.want {
+			t.Errorf("%s: first value is %d, want %d", String(d), values[0], test.want)
+		}
+	}
+}
+
+// TestResume checks that a resumed Sampler draws the values the Sampler it was resumed from would have.
+func TestResume(t *testing.T) {
+	for _, spec := range []string{"", "uniform:1:6", "sample:1:1000", "gaussian:0:1", "poisson:1000"} {
+		d, _ := Parse(spec)
+		want, _ := Sequence(protocol.PrngAlgorithm_MT19937, generator.Uint32Seed(7), d, 200)
+
+		p, _ := generator.New(protocol.PrngAlgorithm_MT19937, generator.Uint32Seed(7))
+		s := New(d, p)
+		for i := 0; i < 100; i++ {
+			s.Next()
+		}
+
+		p, _ = generator.New(protocol.PrngAlgorithm_MT19937, generator.Uint32Seed(7))
+		r := Resume(d, p, 100, s.Read(), s.Swapped())
+		for i := 100; i < 200; i++ {
+			if got := r.Next(); got != want[i] {
+				t.Errorf("%s: value %d of the resumed sampler is %#x, want %#x", String(d), i+1, got, want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{"uniform:1:6", "sample:-10:10", "gaussian:0.5:2", "exponential:0.25", "poisson:4"} {
+		d, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("%s: %s", spec, err)
+		}
+		if got := String(d); got != spec {
+			t.Errorf("Parse(%q) is %s", spec, got)
+		}
+
+		decoded, err := Decode(AppendBinary(nil, d))
+		if err != nil {
+			t.Fatalf("%s: %s", spec, err)
+		}
+		if got := String(decoded); got != spec {
+			t.Errorf("%s decodes as %s", spec, got)
+		}
+	}
+
+	for _, spec := range []string{"uniform:1", "normal:0:1", "poisson:x"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded", spec)
+		}
+	}
+}
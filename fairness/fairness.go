@@ -0,0 +1,83 @@
+// Package fairness implements the commit-reveal scheme the server uses when it picks a stream's seed, so that a
+// client can check the seed was fixed before the first number was sent, and then replay the whole sequence.
+//
+// The server draws a random server seed and nonce and sends the commitment SHA-256(server seed || nonce) with the
+// first number. The PRNG is given the 256 bit seed SHA-256(server seed || client seed), where the client seed is
+// whatever the client chose to contribute (possibly nothing), and the server seed and nonce are revealed with the
+// checksum.
+package fairness
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// SERVER_SEED_SIZE and NONCE_SIZE are the sizes in bytes of the server seed and nonce the server draws.
+const (
+	SERVER_SEED_SIZE = 32
+	NONCE_SIZE       = 16
+)
+
+// MAX_CLIENT_SEED_SIZE is the most bytes a client may contribute to a seed.
+const MAX_CLIENT_SEED_SIZE = 64
+
+// NewServerSeed draws a server seed and the nonce its commitment is made with.
+func NewServerSeed() (serverSeed []byte, nonce []byte, err error) {
+	serverSeed = make([]byte, SERVER_SEED_SIZE)
+	nonce = make([]byte, NONCE_SIZE)
+	if _, err := rand.Read(serverSeed); err != nil {
+		return nil, nil, fmt.Errorf("unable to draw server seed: %s", err)
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("unable to draw nonce: %s", err)
+	}
+
+	return serverSeed, nonce, nil
+}
+
+// Commitment returns the commitment to serverSeed made with nonce.
+func Commitment(serverSeed []byte, nonce []byte) []byte {
+	h := sha256.New()
+	h.Write(serverSeed)
+	h.Write(nonce)
+
+	return h.Sum(nil)
+}
+
+// Seed returns the 256 bit PRNG seed made from serverSeed and clientSeed.
+func Seed(serverSeed []byte, clientSeed []byte) generator.Seed {
+	h := sha256.New()
+	h.Write(serverSeed)
+	h.Write(clientSeed)
+
+	return h.Sum(nil)
+}
+
+// Verify checks that the revealed serverSeed and nonce match commitment, and that values, a sequence received from
+// its first value on, is the one d draws from what algorithm generates from them and clientSeed.
+func Verify(commitment []byte, serverSeed []byte, nonce []byte, clientSeed []byte, algorithm protocol.PrngAlgorithm, d *protocol.Distribution, values []uint64) error {
+	if !bytes.Equal(Commitment(serverSeed, nonce), commitment) {
+		return fmt.Errorf("revealed server seed %x and nonce %x don't match the commitment %x", serverSeed, nonce, commitment)
+	}
+
+	seed := Seed(serverSeed, clientSeed)
+	replayed, err := distribution.Sequence(algorithm, seed, d, uint32(len(values)))
+	if err != nil {
+		return err
+	}
+	valueType := distribution.ValueType(d)
+	for i, want := range replayed {
+		if values[i] != want {
+			return fmt.Errorf("value at index %d is %s, but %s with %s generates %s from seed %x", i+1,
+				distribution.Format(valueType, values[i]), algorithm, distribution.String(d), distribution.Format(valueType, want), seed)
+		}
+	}
+
+	return nil
+}
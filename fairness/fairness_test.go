@@ -0,0 +1,65 @@
+package fairness
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/jamesrobb/ably-takehome/distribution"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+var (
+	testServerSeed = bytes.Repeat([]byte{0xa5}, SERVER_SEED_SIZE)
+	testNonce      = bytes.Repeat([]byte{0x5a}, NONCE_SIZE)
+)
+
+// TestCommitment checks the commitment and seeds against SHA-256 worked out independently of this package.
+func TestCommitment(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"commitment", Commitment(testServerSeed, testNonce), "bc1a8cc6123b16422f1d4dc916c214b851dda5e9d95561186ce8e22b712e607f"},
+		{"seed", Seed(testServerSeed, []byte("client")), "82a6c2ba4395d1e78b4111246a0c98f9e9fc4957c00f25e51bc1e399f1e46ce6"},
+		{"seed without a client seed", Seed(testServerSeed, nil), "fc8b64001c5fdd0f2f40fb67dae4a865a2c5bd17836676d6d5b58b7917e33717"},
+	} {
+		if got := hex.EncodeToString(test.got); got != test.want {
+			t.Errorf("%s is %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+// TestVerify checks that a sequence verifies against what was revealed for it, and doesn't once any part of it is
+// changed.
+func TestVerify(t *testing.T) {
+	d, _ := distribution.Parse("uniform:1:6")
+	algorithm := protocol.PrngAlgorithm_XOSHIRO256_PLUS_PLUS
+	commitment := Commitment(testServerSeed, testNonce)
+	values, err := distribution.Sequence(algorithm, Seed(testServerSeed, []byte("client")), d, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify(commitment, testServerSeed, testNonce, []byte("client"), algorithm, d, values); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := append([]uint64(nil), values...)
+	changed[19] = changed[19]%6 + 1
+	for _, test := range []struct {
+		name       string
+		nonce      []byte
+		clientSeed []byte
+		values     []uint64
+	}{
+		{"nonce", bytes.Repeat([]byte{0}, NONCE_SIZE), []byte("client"), values},
+		{"client seed", testNonce, []byte("other"), values},
+		{"last value", testNonce, []byte("client"), changed},
+	} {
+		if err := Verify(commitment, testServerSeed, test.nonce, test.clientSeed, algorithm, d, test.values); err == nil {
+			t.Errorf("sequence verified with a different %s", test.name)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+// chaCha20 generates the ChaCha20 keystream of a key and nonce, 4 bytes at a time in big-endian order. It is
+// positioned by the number of outputs it has generated, so it can jump to any point of the keystream. The block
+// counter is 32 bits, which limits a sequence to 2^36 numbers.
+type chaCha20 struct {
+	key   [chacha20.KeySize]byte
+	nonce [chacha20.NonceSize]byte
+	// position is the number of outputs generated so far.
+	position uint64
+	// block holds the outputs of keystream block blockIndex, which is -1 until a block has been generated.
+	block      [16]uint32
+	blockIndex int64
+}
+
+func newChaCha20(seed Seed) *chaCha20 {
+	c := &chaCha20{blockIndex: -1}
+	copy(c.key[:], seed)
+	c.nonce[0] = byte(len(seed))
+
+	return c
+}
+
+func (c *chaCha20) Uint32() uint32 {
+	index := int64(c.position / 16)
+	if index != c.blockIndex {
+		cipher, _ := chacha20.NewUnauthenticatedCipher(c.key[:], c.nonce[:])
+		cipher.SetCounter(uint32(index))
+
+		var keystream [64]byte
+		cipher.XORKeyStream(keystream[:], keystream[:])
+		for i := range c.block {
+			c.block[i] = binary.BigEndian.Uint32(keystream[i*4:])
+		}
+		c.blockIndex = index
+	}
+
+	n := c.block[c.position%16]
+	c.position++
+
+	return n
+}
+
+// MarshalBinary encodes the key, nonce and position, in that order.
+func (c *chaCha20) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, len(c.key)+len(c.nonce)+8)
+	data = append(data, c.key[:]...)
+	data = append(data, c.nonce[:]...)
+
+	return binary.BigEndian.AppendUint64(data, c.position), nil
+}
+
+func (c *chaCha20) UnmarshalBinary(data []byte) error {
+	if len(data) != len(c.key)+len(c.nonce)+8 {
+		return fmt.Errorf("ChaCha20 state must be %d bytes, got %d", len(c.key)+len(c.nonce)+8, len(data))
+	}
+
+	copy(c.key[:], data)
+	copy(c.nonce[:], data[len(c.key):])
+	c.position = binary.BigEndian.Uint64(data[len(c.key)+len(c.nonce):])
+	c.blockIndex = -1
+
+	return nil
+}
+
+// cryptoRand takes every output from crypto/rand. It has no state, so nothing it generated can be generated again.
+type cryptoRand struct{}
+
+func (cryptoRand) Uint32() uint32 {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand only fails if the system's source of randomness is broken, which nothing can recover from.
+		panic(fmt.Sprintf("unable to read from crypto/rand: %s", err))
+	}
+
+	return binary.BigEndian.Uint32(b[:])
+}
+
+func (cryptoRand) MarshalBinary() ([]byte, error) {
+	return nil, nil
+}
+
+func (cryptoRand) UnmarshalBinary(data []byte) error {
+	if len(data) != 0 {
+		return fmt.Errorf("crypto/rand has no state, got %d bytes", len(data))
+	}
+
+	return nil
+}
@@ -0,0 +1,292 @@
+// Package generator implements the pseudo-random number generators a sequence can be generated with, and how each
+// is seeded. The server and the client both use it, so that a client can regenerate a sequence from its seed.
+//
+// Every generator but CRYPTO_RAND takes a 32, 64 or 256 bit seed, given as 4, 8 or 32 bytes in big-endian order:
+//
+//   - MT19937 is seeded with Seed for a 32 bit seed, and otherwise with SeedFromKeys and the seed's 32 bit words.
+//   - MT19937_64 is seeded with Seed for a 32 or 64 bit seed, and with SeedFromKeys and the seed's 64 bit words for
+//     a 256 bit seed.
+//   - The xoshiro256 generators are seeded with Seed, which expands the seed with SplitMix64, for a 32 or 64 bit
+//     seed, and take a 256 bit seed as their state, which can't be all zeros.
+//   - CHACHA20 takes the seed, followed by zeros, as its key, and a nonce whose first byte is the seed's length and
+//     whose other bytes are zero.
+//
+// The 64 bit generators give the high 32 bits of each of their outputs.
+package generator
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"gonum.org/v1/gonum/mathext/prng"
+
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// Seed is the seed of a sequence, 4, 8 or 32 bytes in big-endian order for a 32, 64 or 256 bit seed.
+type Seed []byte
+
+// Uint32Seed returns the 32 bit seed v.
+func Uint32Seed(v uint32) Seed {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+// Bits returns the width of the seed in bits.
+func (s Seed) Bits() int {
+	return len(s) * 8
+}
+
+// ParseSeed parses a hex encoded 32, 64 or 256 bit seed.
+func ParseSeed(seedHex string) (Seed, error) {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil || !validSeedLength(len(seed)) {
+		return nil, fmt.Errorf("seed must be 8, 16 or 64 hex digits, got %q", seedHex)
+	}
+
+	return seed, nil
+}
+
+func validSeedLength(n int) bool {
+	return n == 4 || n == 8 || n == 32
+}
+
+// names are the names of the generators, as they are given on the command line.
+var names = map[protocol.PrngAlgorithm]string{
+	protocol.PrngAlgorithm_MT19937:              "mt19937",
+	protocol.PrngAlgorithm_MT19937_64:           "mt19937-64",
+	protocol.PrngAlgorithm_XOSHIRO256_PLUS:      "xoshiro256+",
+	protocol.PrngAlgorithm_XOSHIRO256_PLUS_PLUS: "xoshiro256++",
+	protocol.PrngAlgorithm_XOSHIRO256_STAR_STAR: "xoshiro256**",
+	protocol.PrngAlgorithm_CHACHA20:             "chacha20",
+	protocol.PrngAlgorithm_CRYPTO_RAND:          "crypto",
+}
+
+// Supported reports whether algorithm is implemented.
+func Supported(algorithm protocol.PrngAlgorithm) bool {
+	_, ok := names[algorithm]
+
+	return ok
+}
+
+// Replayable reports whether the numbers generated by algorithm can be generated again from the seed, which is the
+// case for every algorithm but CRYPTO_RAND.
+func Replayable(algorithm protocol.PrngAlgorithm) bool {
+	return algorithm != protocol.PrngAlgorithm_CRYPTO_RAND
+}
+
+// Name returns the command line name of algorithm.
+func Name(algorithm protocol.PrngAlgorithm) string {
+	if name, ok := names[algorithm]; ok {
+		return name
+	}
+
+	return algorithm.String()
+}
+
+// Parse returns the algorithm with the command line name name.
+func Parse(name string) (protocol.PrngAlgorithm, error) {
+	for algorithm, n := range names {
+		if n == name {
+			return algorithm, nil
+		}
+	}
+
+	known := make([]string, len(protocol.PrngAlgorithm_name))
+	for i := range known {
+		known[i] = names[protocol.PrngAlgorithm(i)]
+	}
+
+	return 0, fmt.Errorf("unknown PRNG %q, expected one of %s", name, strings.Join(known, ", "))
+}
+
+// CheckSeed returns an error unless algorithm can be seeded with seed. CRYPTO_RAND takes no seed.
+func CheckSeed(algorithm protocol.PrngAlgorithm, seed Seed) error {
+	if !Supported(algorithm) {
+		return fmt.Errorf("unsupported PRNG %s", algorithm)
+	}
+	if !Replayable(algorithm) {
+		if len(seed) > 0 {
+			return fmt.Errorf("%s can't be seeded", algorithm)
+		}
+		return nil
+	}
+	if !validSeedLength(len(seed)) {
+		return fmt.Errorf("seed must be 32, 64 or 256 bits, got %d", seed.Bits())
+	}
+
+	switch algorithm {
+	case protocol.PrngAlgorithm_XOSHIRO256_PLUS, protocol.PrngAlgorithm_XOSHIRO256_PLUS_PLUS, protocol.PrngAlgorithm_XOSHIRO256_STAR_STAR:
+		if len(seed) == 32 && allZero(seed) {
+			return fmt.Errorf("a 256 bit seed of all zeros isn't a valid %s state", algorithm)
+		}
+	}
+
+	return nil
+}
+
+func allZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// source is a generator's own implementation.
+type source interface {
+	Uint32() uint32
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(data []byte) error
+}
+
+// PRNG generates a sequence with one of the algorithms, positioned at the start of the sequence when created.
+type PRNG struct {
+	algorithm protocol.PrngAlgorithm
+	src       source
+}
+
+// New returns the generator of the sequence algorithm generates from seed, which CheckSeed must accept.
+func New(algorithm protocol.PrngAlgorithm, seed Seed) (*PRNG, error) {
+	if err := CheckSeed(algorithm, seed); err != nil {
+		return nil, err
+	}
+
+	p := &PRNG{algorithm: algorithm}
+	switch algorithm {
+	case protocol.PrngAlgorithm_MT19937:
+		src := prng.NewMT19937()
+		if len(seed) == 4 {
+			src.Seed(uint64(binary.BigEndian.Uint32(seed)))
+		} else {
+			keys := make([]uint32, len(seed)/4)
+			for i := range keys {
+				keys[i] = binary.BigEndian.Uint32(seed[i*4:])
+			}
+			src.SeedFromKeys(keys)
+		}
+		p.src = src
+	case protocol.PrngAlgorithm_MT19937_64:
+		src := prng.NewMT19937_64()
+		switch len(seed) {
+		case 4:
+			src.Seed(uint64(binary.BigEndian.Uint32(seed)))
+		case 8:
+			src.Seed(binary.BigEndian.Uint64(seed))
+		default:
+			keys := make([]uint64, len(seed)/8)
+			for i := range keys {
+				keys[i] = binary.BigEndian.Uint64(seed[i*8:])
+			}
+			src.SeedFromKeys(keys)
+		}
+		p.src = high32{src}
+	case protocol.PrngAlgorithm_XOSHIRO256_PLUS:
+		p.src = high32{seedXoshiro(&prng.Xoshiro256plus{}, seed)}
+	case protocol.PrngAlgorithm_XOSHIRO256_PLUS_PLUS:
+		p.src = high32{seedXoshiro(&prng.Xoshiro256plusplus{}, seed)}
+	case protocol.PrngAlgorithm_XOSHIRO256_STAR_STAR:
+		p.src = high32{seedXoshiro(&prng.Xoshiro256starstar{}, seed)}
+	case protocol.PrngAlgorithm_CHACHA20:
+		p.src = newChaCha20(seed)
+	case protocol.PrngAlgorithm_CRYPTO_RAND:
+		p.src = cryptoRand{}
+	}
+
+	return p, nil
+}
+
+// Algorithm returns the algorithm p generates numbers with.
+func (p *PRNG) Algorithm() protocol.PrngAlgorithm {
+	return p.algorithm
+}
+
+// Replayable reports whether the numbers p generates can be generated again from its seed.
+func (p *PRNG) Replayable() bool {
+	return Replayable(p.algorithm)
+}
+
+// Uint32 returns the next number of the sequence.
+func (p *PRNG) Uint32() uint32 {
+	return p.src.Uint32()
+}
+
+// Skip moves p past the next n numbers of the sequence. Numbers that can't be generated again are simply never
+// generated, so skipping them does nothing.
+func (p *PRNG) Skip(n uint32) {
+	switch src := p.src.(type) {
+	case *prng.MT19937:
+		skipMT19937(src, n)
+	case *chaCha20:
+		src.position += uint64(n)
+	case cryptoRand:
+	default:
+		for i := uint32(0); i < n; i++ {
+			src.Uint32()
+		}
+	}
+}
+
+// MarshalBinary returns the internal state of p, which is empty for CRYPTO_RAND.
+func (p *PRNG) MarshalBinary() ([]byte, error) {
+	return p.src.MarshalBinary()
+}
+
+// UnmarshalBinary sets the internal state of p to data, as returned by MarshalBinary for the same algorithm.
+func (p *PRNG) UnmarshalBinary(data []byte) error {
+	return p.src.UnmarshalBinary(data)
+}
+
+// Sequence returns the first n numbers algorithm generates from seed.
+func Sequence(algorithm protocol.PrngAlgorithm, seed Seed, n uint32) ([]uint32, error) {
+	if !Replayable(algorithm) {
+		return nil, fmt.Errorf("%s can't generate a sequence again", algorithm)
+	}
+	p, err := New(algorithm, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]uint32, n)
+	for i := range numbers {
+		numbers[i] = p.Uint32()
+	}
+
+	return numbers, nil
+}
+
+// source64 is a generator with 64 bit outputs.
+type source64 interface {
+	Seed(seed uint64)
+	Uint64() uint64
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(data []byte) error
+}
+
+// high32 gives the high 32 bits of each of a 64 bit generator's outputs, which for the xoshiro256 generators are
+// better distributed than the low bits.
+type high32 struct {
+	source64
+}
+
+func (h high32) Uint32() uint32 {
+	return uint32(h.Uint64() >> 32)
+}
+
+// seedXoshiro seeds src, a xoshiro256 generator, with seed.
+func seedXoshiro(src source64, seed Seed) source64 {
+	switch len(seed) {
+	case 4:
+		src.Seed(uint64(binary.BigEndian.Uint32(seed)))
+	case 8:
+		src.Seed(binary.BigEndian.Uint64(seed))
+	default:
+		// The state is four 64 bit words in big-endian order, which is how gonum encodes it.
+		src.UnmarshalBinary(seed)
+	}
+
+	return src
+}
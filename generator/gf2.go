@@ -0,0 +1,177 @@
+package generator
+
+import "math/bits"
+
+// gf2Poly is a polynomial over GF(2). Bit i of the words, counting from the lowest bit of the first word, is the
+// coefficient of x^i.
+type gf2Poly []uint64
+
+func newGF2Poly(degree int) gf2Poly {
+	return make(gf2Poly, degree/64+1)
+}
+
+func (p gf2Poly) bit(i int) bool {
+	return i>>6 < len(p) && p[i>>6]>>(i&63)&0x1 != 0
+}
+
+func (p gf2Poly) flip(i int) {
+	p[i>>6] ^= 1 << (i & 63)
+}
+
+// degree returns the degree of p, or -1 if p is zero.
+func (p gf2Poly) degree() int {
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i] != 0 {
+			return i*64 + 63 - bits.LeadingZeros64(p[i])
+		}
+	}
+
+	return -1
+}
+
+// addShifted adds q * x^shift to p, dropping any terms past the end of p.
+func (p gf2Poly) addShifted(q gf2Poly, shift int) {
+	words, offset := shift>>6, shift&63
+	for i, v := range q {
+		if v == 0 || i+words >= len(p) {
+			continue
+		}
+		p[i+words] ^= v << offset
+		if offset != 0 && i+words+1 < len(p) {
+			p[i+words+1] ^= v >> (64 - offset)
+		}
+	}
+}
+
+// window returns the 64 coefficients of p from x^i up, as the words of p hold them.
+func (p gf2Poly) window(i int) uint64 {
+	words, offset := i>>6, i&63
+	var v uint64
+	if words < len(p) {
+		v = p[words] >> offset
+	}
+	if offset != 0 && words+1 < len(p) {
+		v |= p[words+1] << (64 - offset)
+	}
+
+	return v
+}
+
+// minimalPolynomial returns the minimal polynomial of the linear recurrence generating the sequence of bits s, using
+// the Berlekamp-Massey algorithm. s has to be at least twice as long as the degree of the polynomial.
+func minimalPolynomial(s []bool) gf2Poly {
+	n := len(s)
+
+	// The algorithm sums terms of the sequence counting back from the n-th, which are in order in s reversed.
+	reversed := newGF2Poly(n)
+	for i, b := range s {
+		if b {
+			reversed.flip(n - 1 - i)
+		}
+	}
+
+	// connection is the polynomial whose coefficients, from x^1 up, generate each term from the ones before it.
+	connection, previous := newGF2Poly(n), newGF2Poly(n)
+	connection[0], previous[0] = 1, 1
+	length, shift := 0, 1
+	for i := range s {
+		var discrepancy uint64
+		for w := 0; w <= length>>6; w++ {
+			discrepancy ^= connection[w] & reversed.window(n-1-i+w*64)
+		}
+		if bits.OnesCount64(discrepancy)%2 == 0 {
+			shift++
+			continue
+		}
+
+		if 2*length <= i {
+			last := append(gf2Poly(nil), connection...)
+			connection.addShifted(previous, shift)
+			length = i + 1 - length
+			previous = last
+			shift = 1
+		} else {
+			connection.addShifted(previous, shift)
+			shift++
+		}
+	}
+
+	// The minimal polynomial is the connection polynomial with its coefficients reversed.
+	minimal := newGF2Poly(length)
+	for i := 0; i <= length; i++ {
+		if connection.bit(i) {
+			minimal.flip(length - i)
+		}
+	}
+
+	return minimal
+}
+
+// gf2Modulus does arithmetic on polynomials over GF(2) modulo a polynomial.
+type gf2Modulus struct {
+	degree int
+	// shifted holds the modulus multiplied by x^0 to x^63, so it can be subtracted at any offset a word at a time.
+	shifted [64]gf2Poly
+}
+
+func newGF2Modulus(modulus gf2Poly) *gf2Modulus {
+	m := &gf2Modulus{degree: modulus.degree()}
+	for i := range m.shifted {
+		m.shifted[i] = newGF2Poly(m.degree + 64)
+		m.shifted[i].addShifted(modulus, i)
+	}
+
+	return m
+}
+
+// reduce reduces p modulo m in place and returns its terms below the degree of m.
+func (m *gf2Modulus) reduce(p gf2Poly) gf2Poly {
+	for i := len(p)*64 - 1; i >= m.degree; i-- {
+		if p.bit(i) {
+			shift := i - m.degree
+			p[shift>>6:].addShifted(m.shifted[shift&63], 0)
+		}
+	}
+
+	return p[:m.degree/64+1]
+}
+
+// square returns p squared modulo m. Squaring a polynomial over GF(2) only spreads its coefficients out, as the
+// cross terms cancel.
+func (m *gf2Modulus) square(p gf2Poly) gf2Poly {
+	squared := make(gf2Poly, 2*len(p))
+	for i, v := range p {
+		squared[2*i] = spreadBits(uint32(v))
+		squared[2*i+1] = spreadBits(uint32(v >> 32))
+	}
+
+	return m.reduce(squared)
+}
+
+// xPow returns x^e modulo m.
+func (m *gf2Modulus) xPow(e uint64) gf2Poly {
+	result := newGF2Poly(m.degree)
+	result[0] = 1
+	for i := 63 - bits.LeadingZeros64(e); i >= 0; i-- {
+		result = m.square(result)
+		if e>>i&0x1 != 0 {
+			shifted := newGF2Poly(m.degree)
+			shifted.addShifted(result, 1)
+			result = m.reduce(shifted)
+		}
+	}
+
+	return result
+}
+
+// spreadBits moves bit i of v to bit 2i.
+func spreadBits(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+
+	return x
+}
@@ -0,0 +1,45 @@
+package generator
+
+import "testing"
+
+// lfsr is x^5 + x^2 + 1, a primitive polynomial, so the sequence it generates repeats every 2^5 - 1 bits.
+var lfsr = gf2Poly{1<<5 | 1<<2 | 1}
+
+// TestMinimalPolynomial checks that the minimal polynomial of the sequence a linear recurrence generates is the
+// recurrence's characteristic polynomial.
+func TestMinimalPolynomial(t *testing.T) {
+	// s[i+5] = s[i+2] + s[i], from any state but zero.
+	s := []bool{true, false, false, true, true}
+	for len(s) < 20 {
+		n := len(s)
+		s = append(s, s[n-3] != s[n-5])
+	}
+
+	got := minimalPolynomial(s)
+	if got.degree() != 5 || got[0] != lfsr[0] {
+		t.Errorf("minimal polynomial is %b, want %b", got[0], lfsr[0])
+	}
+}
+
+// TestXPow checks x^e modulo a polynomial against multiplying by x e times, and that x has the order of the
+// multiplicative group modulo a primitive polynomial.
+func TestXPow(t *testing.T) {
+	m := newGF2Modulus(lfsr)
+
+	want := newGF2Poly(5)
+	want[0] = 1
+	for e := uint64(0); e < 100; e++ {
+		if got := m.xPow(e); got[0] != want[0] {
+			t.Errorf("x^%d mod %b is %b, want %b", e, lfsr[0], got[0], want[0])
+		}
+
+		want[0] <<= 1
+		if want[0]&(1<<5) != 0 {
+			want[0] ^= lfsr[0]
+		}
+	}
+
+	if got := m.xPow(31); got[0] != 1 {
+		t.Errorf("x^31 mod %b is %b, want 1", lfsr[0], got[0])
+	}
+}
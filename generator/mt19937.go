@@ -0,0 +1,184 @@
+package generator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"gonum.org/v1/gonum/mathext/prng"
+)
+
+// These mirror the unexported constants of gonum's MT19937.
+const (
+	mt19937N         = 624
+	mt19937M         = 397
+	mt19937MatrixA   = 0x9908b0df
+	mt19937UpperMask = 0x80000000
+	mt19937LowerMask = 0x7fffffff
+)
+
+// mt19937JumpTwists is the number of twists of the state array from which skipMT19937 jumps ahead rather than
+// twisting the array that many times. Below it, twisting is faster.
+const mt19937JumpTwists = 20000
+
+// skipMT19937 advances src past the next n outputs without producing them.
+//
+// MT19937 generates its outputs 624 at a time by "twisting" its state array, then tempers each word as it is
+// handed out. Skipping a few outputs only has to twist the array once per 624 outputs and move the read position;
+// nothing is tempered. Skipping many more jumps the array ahead in GF(2) instead, which takes the same time however
+// far it jumps.
+func skipMT19937(src *prng.MT19937, n uint32) {
+	if n == 0 {
+		return
+	}
+
+	state, _ := src.MarshalBinary()
+
+	var mt [mt19937N]uint32
+	for i := range mt {
+		mt[i] = binary.BigEndian.Uint32(state[i*4:])
+	}
+	mti := binary.BigEndian.Uint32(state[mt19937N*4:])
+
+	if mti > mt19937N {
+		// The generator was never seeded, let it apply its default seed.
+		src.Uint32()
+		skipMT19937(src, n-1)
+		return
+	}
+
+	// The array is only twisted when an output is needed past its end, so a read position of 624 is left as it is.
+	position := uint64(mti) + uint64(n)
+	if position > mt19937N {
+		twists := (position - 1) / mt19937N
+		if twists < mt19937JumpTwists {
+			for i := uint64(0); i < twists; i++ {
+				twistMT19937(&mt)
+			}
+		} else {
+			jumpMT19937(&mt, twists)
+		}
+		position -= twists * mt19937N
+	}
+	mti = uint32(position)
+
+	for i := range mt {
+		binary.BigEndian.PutUint32(state[i*4:], mt[i])
+	}
+	binary.BigEndian.PutUint32(state[mt19937N*4:], mti)
+	src.UnmarshalBinary(state)
+}
+
+// twistMT19937 generates the next 624 untempered words of the state array, as gonum's MT19937.Uint32 does.
+func twistMT19937(mt *[mt19937N]uint32) {
+	mag01 := [2]uint32{0x0, mt19937MatrixA}
+
+	var y uint32
+	var kk int
+	for ; kk < mt19937N-mt19937M; kk++ {
+		y = (mt[kk] & mt19937UpperMask) | (mt[kk+1] & mt19937LowerMask)
+		mt[kk] = mt[kk+mt19937M] ^ (y >> 1) ^ mag01[y&0x1]
+	}
+	for ; kk < mt19937N-1; kk++ {
+		y = (mt[kk] & mt19937UpperMask) | (mt[kk+1] & mt19937LowerMask)
+		mt[kk] = mt[kk+(mt19937M-mt19937N)] ^ (y >> 1) ^ mag01[y&0x1]
+	}
+	y = (mt[mt19937N-1] & mt19937UpperMask) | (mt[0] & mt19937LowerMask)
+	mt[mt19937N-1] = mt[mt19937M-1] ^ (y >> 1) ^ mag01[y&0x1]
+}
+
+// mtWindow is the last 624 untempered words MT19937 generated, from which it generates the next one. The words are
+// kept in a ring so stepping the window doesn't move them.
+type mtWindow struct {
+	words [mt19937N]uint32
+	start int
+}
+
+// at returns the i-th oldest word of w.
+func (w *mtWindow) at(i int) uint32 {
+	return w.words[(w.start+i)%mt19937N]
+}
+
+// step replaces the oldest word of w with the next word of the sequence.
+func (w *mtWindow) step() {
+	y := (w.at(0) & mt19937UpperMask) | (w.at(1) & mt19937LowerMask)
+	next := w.at(mt19937M) ^ (y >> 1)
+	if y&0x1 != 0 {
+		next ^= mt19937MatrixA
+	}
+	w.words[w.start] = next
+	w.start = (w.start + 1) % mt19937N
+}
+
+// add adds the words of v to the words of w in GF(2).
+func (w *mtWindow) add(v *mtWindow) {
+	for i := 0; i < mt19937N; i++ {
+		w.words[(w.start+i)%mt19937N] ^= v.at(i)
+	}
+}
+
+// jumpMT19937 sets mt to the state array twisting it the given number of times would leave.
+//
+// Stepping the window is linear in GF(2), and once stepped the window stays in the subspace of its states whose
+// first word's lower 31 bits are the ones the twist produced. On that subspace the characteristic polynomial φ of a
+// step annihilates the step, so taking J steps is the same as applying x^(J-1) mod φ to the window stepped once,
+// which takes at most 19937 steps and additions of windows.
+func jumpMT19937(mt *[mt19937N]uint32, twists uint64) {
+	stepped := mtWindow{words: *mt}
+	stepped.step()
+
+	jump := mt19937Jump(twists*mt19937N - 1)
+
+	// Horner's method: step the sum so far, then add the stepped window for each term of the polynomial.
+	var w mtWindow
+	for i := mt19937Degree - 1; i >= 0; i-- {
+		w.step()
+		if jump.bit(i) {
+			w.add(&stepped)
+		}
+	}
+
+	for i := range mt {
+		mt[i] = w.at(i)
+	}
+}
+
+// mt19937Degree is the degree of the characteristic polynomial of a step of MT19937's window, the number of bits of
+// its state that matter.
+const mt19937Degree = 19937
+
+var (
+	mt19937PolynomialOnce sync.Once
+	// mt19937Polynomial is the characteristic polynomial of a step of MT19937's window.
+	mt19937Polynomial *gf2Modulus
+)
+
+// mt19937Jump returns x^steps mod the characteristic polynomial of a step of MT19937's window.
+func mt19937Jump(steps uint64) gf2Poly {
+	mt19937PolynomialOnce.Do(func() {
+		// The characteristic polynomial is irreducible, so it is also the minimal polynomial of any sequence of bits
+		// taken from the stepped window that isn't all zeros, like the lowest bit of each word it generates.
+		src := prng.NewMT19937()
+		src.Seed(5489)
+		state, _ := src.MarshalBinary()
+
+		var w mtWindow
+		for i := range w.words {
+			w.words[i] = binary.BigEndian.Uint32(state[i*4:])
+		}
+
+		bits := make([]bool, 2*mt19937Degree)
+		for i := range bits {
+			w.step()
+			bits[i] = w.at(mt19937N-1)&0x1 != 0
+		}
+
+		polynomial := minimalPolynomial(bits)
+		if polynomial.degree() != mt19937Degree {
+			panic(fmt.Sprintf("MT19937 characteristic polynomial has degree %d", polynomial.degree()))
+		}
+		mt19937Polynomial = newGF2Modulus(polynomial)
+	})
+
+	return mt19937Polynomial.xPow(steps)
+}
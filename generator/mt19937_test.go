@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"testing"
+
+	"gonum.org/v1/gonum/mathext/prng"
+
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// TestSkipMT19937 checks that skipping n outputs leaves MT19937 where generating them would, for skips that end
+// around a twist of its state array and for skips either side of where it starts jumping ahead instead.
+func TestSkipMT19937(t *testing.T) {
+	jump := uint32(mt19937JumpTwists * mt19937N)
+	tests := []struct {
+		// read is the number of outputs read before skipping.
+		read uint32
+		n    uint32
+	}{
+		{0, 0}, {0, 1}, {0, 623}, {0, 624}, {0, 625}, {0, 1248}, {0, 1249},
+		{1, 622}, {1, 623}, {1, 624}, {623, 1}, {624, 0}, {624, 1}, {624, 624}, {700, 548}, {700, 549},
+		{0, jump - mt19937N}, {0, jump - mt19937N + 1}, {0, jump}, {0, jump + 1},
+		{300, jump - 300}, {300, jump - 299}, {624, jump}, {624, jump + mt19937N + 1},
+	}
+	for _, test := range tests {
+		skipped, stepped := prng.NewMT19937(), prng.NewMT19937()
+		skipped.Seed(7)
+		stepped.Seed(7)
+		for i := uint32(0); i < test.read; i++ {
+			skipped.Uint32()
+			stepped.Uint32()
+		}
+
+		skipMT19937(skipped, test.n)
+		for i := uint32(0); i < test.n; i++ {
+			stepped.Uint32()
+		}
+
+		// Enough outputs to take the array through two more twists.
+		for i := 0; i < 2*mt19937N+1; i++ {
+			if got, want := skipped.Uint32(), stepped.Uint32(); got != want {
+				t.Errorf("after reading %d and skipping %d, output %d is %d, want %d", test.read, test.n, i, got, want)
+				break
+			}
+		}
+	}
+}
+
+// TestSkipMT19937Unseeded checks that skipping from a generator that was never seeded applies the default seed
+// first, as generating would.
+func TestSkipMT19937Unseeded(t *testing.T) {
+	skipped, stepped := prng.NewMT19937(), prng.NewMT19937()
+	skipMT19937(skipped, 1000)
+	for i := 0; i < 1000; i++ {
+		stepped.Uint32()
+	}
+	if got, want := skipped.Uint32(), stepped.Uint32(); got != want {
+		t.Errorf("output after skipping 1000 is %d, want %d", got, want)
+	}
+}
+
+// TestMT19937KnownAnswer checks the 10000th output of MT19937 seeded with 5489, which the C++ standard requires of
+// std::mt19937, both generated and skipped to.
+func TestMT19937KnownAnswer(t *testing.T) {
+	const want = 4123659995
+
+	generated, _ := Sequence(protocol.PrngAlgorithm_MT19937, Uint32Seed(5489), 10000)
+	if generated[9999] != want {
+		t.Errorf("10000th output is %d, want %d", generated[9999], uint32(want))
+	}
+
+	p, _ := New(protocol.PrngAlgorithm_MT19937, Uint32Seed(5489))
+	p.Skip(9999)
+	if got := p.Uint32(); got != want {
+		t.Errorf("10000th output after skipping is %d, want %d", got, uint32(want))
+	}
+}
@@ -0,0 +1,257 @@
+// Package merkle builds and verifies Merkle trees over a sequence of numbers, so that a client holding only part of
+// a sequence can check it against the root of the whole. The tree is the one of RFC 6962, with each number (as 4
+// bytes in big-endian order) a leaf. The sequence is split into chunks of a power of two numbers, which makes every
+// chunk a subtree of its own. The server sends the root of each chunk with the path from it to the root of the tree,
+// and the client checks a chunk once it has all of its numbers, without needing the numbers of any other chunk.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// The prefixes that keep leaf and interior node hashes apart, as in RFC 6962.
+const (
+	leafPrefix byte = 0
+	nodePrefix byte = 1
+)
+
+// Proof proves that a chunk belongs to the tree of a sequence.
+type Proof struct {
+	// Chunk is the position of the chunk in the sequence, the first chunk is 0.
+	Chunk uint32 `json:"chunk"`
+	// Root is the root of the chunk's subtree.
+	Root []byte `json:"root"`
+	// Path is the audit path from the chunk's root to the root of the tree, lowest sibling first.
+	Path [][]byte `json:"path"`
+}
+
+func leafHash(number uint32) []byte {
+	var leaf [5]byte
+	leaf[0] = leafPrefix
+	binary.BigEndian.PutUint32(leaf[1:], number)
+	sum := sha256.Sum256(leaf[:])
+
+	return sum[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+
+	return h.Sum(nil)
+}
+
+// split returns the size of the left subtree of a tree with n > 1 leaves, the largest power of two less than n.
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// Root returns the root of the tree over numbers, which must not be empty.
+func Root(numbers []uint32) []byte {
+	if len(numbers) == 1 {
+		return leafHash(numbers[0])
+	}
+	k := split(len(numbers))
+
+	return nodeHash(Root(numbers[:k]), Root(numbers[k:]))
+}
+
+// ValidChunkSize reports whether a sequence can be split into chunks of chunkSize numbers, which must be a power of
+// two.
+func ValidChunkSize(chunkSize uint32) bool {
+	return chunkSize != 0 && chunkSize&(chunkSize-1) == 0
+}
+
+// Chunks returns the number of chunks a sequence of total numbers is split into.
+func Chunks(total uint32, chunkSize uint32) uint32 {
+	return uint32((uint64(total) + uint64(chunkSize) - 1) / uint64(chunkSize))
+}
+
+// ChunkOf returns the chunk holding the number at index, where the first number of the sequence has index 1.
+func ChunkOf(index uint32, chunkSize uint32) uint32 {
+	return (index - 1) / chunkSize
+}
+
+// ChunkBounds returns the indexes of the first and last numbers in chunk, for a sequence of total numbers.
+func ChunkBounds(total uint32, chunkSize uint32, chunk uint32) (first uint32, last uint32) {
+	first = chunk*chunkSize + 1
+	last = first + chunkSize - 1
+	if last > total || last < first {
+		last = total
+	}
+
+	return first, last
+}
+
+// Tree is the Merkle tree of a whole sequence, from which the proof of any chunk can be taken.
+type Tree struct {
+	total     uint32
+	chunkSize uint32
+	// levels[0] holds the root of every chunk and levels[l] the root of every complete subtree of 2^l chunks, which
+	// is all a proof needs besides the subtrees along the right edge of the tree.
+	levels [][][]byte
+	root   []byte
+}
+
+// New builds the tree of numbers, the whole of a sequence, split into chunks of chunkSize numbers.
+func New(numbers []uint32, chunkSize uint32) (*Tree, error) {
+	i := 0
+	return Build(uint32(len(numbers)), chunkSize, func() uint32 {
+		i++
+		return numbers[i-1]
+	})
+}
+
+// Build builds the tree of a sequence of total numbers split into chunks of chunkSize numbers, taking the numbers
+// from next in order. Only one chunk's numbers are held at a time, so the tree of a long sequence takes the space of
+// its chunks' roots rather than of its numbers.
+func Build(total uint32, chunkSize uint32, next func() uint32) (*Tree, error) {
+	if !ValidChunkSize(chunkSize) {
+		return nil, fmt.Errorf("chunk size %d is not a power of two", chunkSize)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("cannot build the tree of an empty sequence")
+	}
+
+	t := &Tree{total: total, chunkSize: chunkSize}
+
+	chunks := make([][]byte, Chunks(total, chunkSize))
+	numbers := make([]uint32, 0, chunkSize)
+	if total < chunkSize {
+		numbers = make([]uint32, 0, total)
+	}
+	for chunk := range chunks {
+		first, last := ChunkBounds(total, chunkSize, uint32(chunk))
+		numbers = numbers[:0]
+		for index := first; index <= last; index++ {
+			numbers = append(numbers, next())
+		}
+		chunks[chunk] = Root(numbers)
+	}
+	t.levels = append(t.levels, chunks)
+
+	for level := chunks; len(level) > 1; {
+		next := make([][]byte, len(level)/2)
+		for i := range next {
+			next[i] = nodeHash(level[2*i], level[2*i+1])
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+
+	t.root = t.subtree(0, len(chunks))
+
+	return t, nil
+}
+
+// Root returns the root of the tree.
+func (t *Tree) Root() []byte {
+	return t.root
+}
+
+// ChunkOf returns the chunk holding the number at index.
+func (t *Tree) ChunkOf(index uint32) uint32 {
+	return ChunkOf(index, t.chunkSize)
+}
+
+// ChunkBounds returns the indexes of the first and last numbers in chunk.
+func (t *Tree) ChunkBounds(chunk uint32) (first uint32, last uint32) {
+	return ChunkBounds(t.total, t.chunkSize, chunk)
+}
+
+// subtree returns the root of the subtree over chunks [start, end).
+func (t *Tree) subtree(start, end int) []byte {
+	n := end - start
+	if n&(n-1) == 0 && start%n == 0 {
+		level := 0
+		for 1<<level < n {
+			level++
+		}
+		return t.levels[level][start/n]
+	}
+	k := split(n)
+
+	return nodeHash(t.subtree(start, start+k), t.subtree(start+k, end))
+}
+
+// Proof returns the proof of chunk, which must be in the tree.
+func (t *Tree) Proof(chunk uint32) Proof {
+	return Proof{
+		Chunk: chunk,
+		Root:  t.levels[0][chunk],
+		Path:  t.path(int(chunk), 0, len(t.levels[0])),
+	}
+}
+
+// path returns the audit path of the chunk m places into the subtree over chunks [start, end), as in RFC 6962.
+func (t *Tree) path(m, start, end int) [][]byte {
+	n := end - start
+	if n == 1 {
+		return nil
+	}
+	k := split(n)
+	if m < k {
+		return append(t.path(m, start, start+k), t.subtree(start+k, end))
+	}
+
+	return append(t.path(m-k, start+k, end), t.subtree(start, start+k))
+}
+
+// VerifyChunk checks numbers, all of the numbers of the chunk proof is for, against root, the root of the tree of a
+// sequence of total numbers split into chunks of chunkSize numbers.
+func VerifyChunk(root []byte, total uint32, chunkSize uint32, proof Proof, numbers []uint32) error {
+	if !ValidChunkSize(chunkSize) {
+		return fmt.Errorf("chunk size %d is not a power of two", chunkSize)
+	}
+	chunks := Chunks(total, chunkSize)
+	if proof.Chunk >= chunks {
+		return fmt.Errorf("chunk %d is past the last chunk, %d", proof.Chunk, chunks-1)
+	}
+	first, last := ChunkBounds(total, chunkSize, proof.Chunk)
+	if uint32(len(numbers)) != last-first+1 {
+		return fmt.Errorf("chunk %d has %d numbers, got %d", proof.Chunk, last-first+1, len(numbers))
+	}
+
+	if !bytes.Equal(Root(numbers), proof.Root) {
+		return fmt.Errorf("numbers of chunk %d (indexes %d to %d) don't match its root", proof.Chunk, first, last)
+	}
+	if !verifyPath(root, proof.Chunk, chunks, proof.Root, proof.Path) {
+		return fmt.Errorf("root of chunk %d isn't part of the tree", proof.Chunk)
+	}
+
+	return nil
+}
+
+// verifyPath checks the audit path of the leaf at index in a tree of size leaves, as in section 2.1.3.2 of RFC 9162.
+func verifyPath(root []byte, index uint32, size uint32, leaf []byte, path [][]byte) bool {
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range path {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(r, root)
+}
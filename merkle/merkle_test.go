@@ -0,0 +1,81 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// TestRoot checks roots worked out independently of this package from RFC 6962's definition of the tree.
+func TestRoot(t *testing.T) {
+	for _, test := range []struct {
+		numbers []uint32
+		want    string
+	}{
+		{[]uint32{1}, "15f2f1a4339f5f2a313b95015cad8124d054a171ac2f31cf529dda7cfb6a38b4"},
+		{[]uint32{1, 2, 3, 4, 5}, "1d8cf170f9e3088c2eca4344e3759825ee54ca035efc125ae070cae88694ca5f"},
+	} {
+		if got := hex.EncodeToString(Root(test.numbers)); got != test.want {
+			t.Errorf("root of %v is %s, want %s", test.numbers, got, test.want)
+		}
+	}
+}
+
+// TestProof checks that a tree built a chunk at a time has the root of its numbers, and that the proof of every
+// chunk verifies against it while a chunk with a changed number doesn't.
+func TestProof(t *testing.T) {
+	for _, test := range []struct {
+		total     uint32
+		chunkSize uint32
+	}{
+		{1, 1}, {1, 4}, {5, 1}, {5, 2}, {7, 4}, {8, 4}, {9, 4}, {100, 8}, {100, 128},
+	} {
+		numbers := make([]uint32, test.total)
+		for i := range numbers {
+			numbers[i] = uint32(i) * 2654435761
+		}
+		tree, err := New(numbers, test.chunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := hex.EncodeToString(tree.Root()), hex.EncodeToString(Root(numbers)); got != want {
+			t.Errorf("%d numbers in chunks of %d: root is %s, want %s", test.total, test.chunkSize, got, want)
+		}
+
+		for chunk := uint32(0); chunk < Chunks(test.total, test.chunkSize); chunk++ {
+			first, last := tree.ChunkBounds(chunk)
+			proof := tree.Proof(chunk)
+			if err := VerifyChunk(tree.Root(), test.total, test.chunkSize, proof, numbers[first-1:last]); err != nil {
+				t.Errorf("%d numbers in chunks of %d: %s", test.total, test.chunkSize, err)
+			}
+
+			changed := append([]uint32(nil), numbers[first-1:last]...)
+			changed[len(changed)-1]++
+			if err := VerifyChunk(tree.Root(), test.total, test.chunkSize, proof, changed); err == nil {
+				t.Errorf("%d numbers in chunks of %d: chunk %d verified with a changed number", test.total, test.chunkSize, chunk)
+			}
+		}
+	}
+}
+
+// TestSegmentSlice checks that a sliced segment keeps the proofs of the chunks it holds all of, and verifies them.
+func TestSegmentSlice(t *testing.T) {
+	numbers := []uint32{3, 1, 4, 1, 5, 9, 2, 6, 5, 3}
+	tree, _ := New(numbers, 2)
+	s := &Segment{Root: tree.Root(), Total: 10, ChunkSize: 2, FirstIndex: 1, Numbers: numbers}
+	for chunk := uint32(0); chunk < 5; chunk++ {
+		s.Proofs = append(s.Proofs, tree.Proof(chunk))
+	}
+
+	sliced, err := s.Slice(2, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified, err := sliced.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Indexes 2 to 7 hold all of chunks 1 (indexes 3 and 4) and 2 (5 and 6).
+	if len(verified) != 2 || verified[0] != 1 || verified[1] != 2 {
+		t.Errorf("verified chunks %v, want [1 2]", verified)
+	}
+}
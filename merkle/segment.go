@@ -0,0 +1,73 @@
+package merkle
+
+import (
+	"fmt"
+)
+
+// Segment is a run of consecutive numbers from a sequence, along with the root of the sequence's tree and the proofs
+// the server sent, so that it can be verified offline.
+type Segment struct {
+	Root      []byte `json:"root"`
+	Total     uint32 `json:"total"`
+	ChunkSize uint32 `json:"chunk_size"`
+	// FirstIndex is the index of the first number in Numbers.
+	FirstIndex uint32   `json:"first_index"`
+	Numbers    []uint32 `json:"numbers"`
+	Proofs     []Proof  `json:"proofs"`
+}
+
+// Slice returns the part of s from index from to index to inclusive, with the proofs of the chunks it still holds
+// all of. It is how a segment is cut down to what a client that joined or left part way through would have had.
+func (s *Segment) Slice(from uint32, to uint32) (*Segment, error) {
+	last := s.FirstIndex + uint32(len(s.Numbers)) - 1
+	if len(s.Numbers) == 0 || from < s.FirstIndex || to > last || from > to {
+		return nil, fmt.Errorf("indexes %d to %d are not within the segment's indexes %d to %d", from, to, s.FirstIndex, last)
+	}
+
+	sliced := &Segment{
+		Root:       s.Root,
+		Total:      s.Total,
+		ChunkSize:  s.ChunkSize,
+		FirstIndex: from,
+		Numbers:    s.Numbers[from-s.FirstIndex : to-s.FirstIndex+1],
+	}
+	for _, proof := range s.Proofs {
+		if _, ok := sliced.chunk(proof.Chunk); ok {
+			sliced.Proofs = append(sliced.Proofs, proof)
+		}
+	}
+
+	return sliced, nil
+}
+
+// chunk returns the numbers of chunk, if the segment holds all of them.
+func (s *Segment) chunk(chunk uint32) ([]uint32, bool) {
+	first, last := ChunkBounds(s.Total, s.ChunkSize, chunk)
+	if first < s.FirstIndex || last >= s.FirstIndex+uint32(len(s.Numbers)) || last < first {
+		return nil, false
+	}
+
+	return s.Numbers[first-s.FirstIndex : last-s.FirstIndex+1], true
+}
+
+// Verify checks every chunk the segment has a proof for against the root, and returns the chunks it verified. A
+// proof for a chunk the segment doesn't hold all the numbers of is skipped, as there is nothing to check it with.
+func (s *Segment) Verify() ([]uint32, error) {
+	if !ValidChunkSize(s.ChunkSize) {
+		return nil, fmt.Errorf("chunk size %d is not a power of two", s.ChunkSize)
+	}
+
+	var verified []uint32
+	for _, proof := range s.Proofs {
+		numbers, ok := s.chunk(proof.Chunk)
+		if !ok {
+			continue
+		}
+		if err := VerifyChunk(s.Root, s.Total, s.ChunkSize, proof, numbers); err != nil {
+			return verified, err
+		}
+		verified = append(verified, proof.Chunk)
+	}
+
+	return verified, nil
+}
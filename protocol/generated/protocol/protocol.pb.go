@@ -20,35 +20,1253 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// How the checksum of a sequence is calculated. Apart from MD5_LEGACY, every algorithm chains the numbers of the
+// sequence with the hash H, starting from a version byte of 2: H_0 = H(2) and H_i = H(H_{i-1} || n_i), with each
+// number n_i as 4 bytes in big-endian order. A sequence of INT64 or FLOAT64 values starts from H_0 = H(2 || t),
+// where t is the ValueType as a byte, and chains each value as 8 bytes in big-endian order: an int64 in two's
+// complement and a float64 as its IEEE 754 bits. The checksum is the hex encoded H_n, with CRC32C and XXHASH64
+// digests in big-endian order.
+type ChecksumAlgorithm int32
+
+const (
+	// md5 of the numbers' decimal strings concatenated with no separator. Kept for compatibility only, as
+	// different sequences can have the same encoding (1, 23 and 12, 3 for instance), and only used for UINT32
+	// values.
+	ChecksumAlgorithm_MD5_LEGACY ChecksumAlgorithm = 0
+	ChecksumAlgorithm_SHA256     ChecksumAlgorithm = 1
+	// BLAKE2b with a 256 bit digest.
+	ChecksumAlgorithm_BLAKE2B_256 ChecksumAlgorithm = 2
+	ChecksumAlgorithm_CRC32C      ChecksumAlgorithm = 3
+	ChecksumAlgorithm_XXHASH64    ChecksumAlgorithm = 4
+)
+
+// Enum value maps for ChecksumAlgorithm.
+var (
+	ChecksumAlgorithm_name = map[int32]string{
+		0: "MD5_LEGACY",
+		1: "SHA256",
+		2: "BLAKE2B_256",
+		3: "CRC32C",
+		4: "XXHASH64",
+	}
+	ChecksumAlgorithm_value = map[string]int32{
+		"MD5_LEGACY":  0,
+		"SHA256":      1,
+		"BLAKE2B_256": 2,
+		"CRC32C":      3,
+		"XXHASH64":    4,
+	}
+)
+
+func (x ChecksumAlgorithm) Enum() *ChecksumAlgorithm {
+	p := new(ChecksumAlgorithm)
+	*p = x
+	return p
+}
+
+func (x ChecksumAlgorithm) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChecksumAlgorithm) Descriptor() protoreflect.EnumDescriptor {
+	return file_protocol_protocol_proto_enumTypes[0].Descriptor()
+}
+
+func (ChecksumAlgorithm) Type() protoreflect.EnumType {
+	return &file_protocol_protocol_proto_enumTypes[0]
+}
+
+func (x ChecksumAlgorithm) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChecksumAlgorithm.Descriptor instead.
+func (ChecksumAlgorithm) EnumDescriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{0}
+}
+
+// The pseudo-random number generator a sequence is generated with. Every generator but CRYPTO_RAND takes a 32, 64
+// or 256 bit seed, and generators with 64 bit outputs give the high 32 bits of each output. How each generator is
+// seeded is documented in the generator package.
+type PrngAlgorithm int32
+
+const (
+	// MT19937 as implemented by gonum, the only generator before the generator could be chosen.
+	PrngAlgorithm_MT19937              PrngAlgorithm = 0
+	PrngAlgorithm_MT19937_64           PrngAlgorithm = 1
+	PrngAlgorithm_XOSHIRO256_PLUS      PrngAlgorithm = 2
+	PrngAlgorithm_XOSHIRO256_PLUS_PLUS PrngAlgorithm = 3
+	PrngAlgorithm_XOSHIRO256_STAR_STAR PrngAlgorithm = 4
+	// The ChaCha20 keystream, 4 bytes at a time in big-endian order. Unlike the others it is cryptographically
+	// secure, so its numbers can't be predicted without the seed.
+	PrngAlgorithm_CHACHA20 PrngAlgorithm = 5
+	// Every number is read from the server's crypto/rand. Nothing can be regenerated, so it takes no seed, its
+	// streams can't be acknowledged, resumed from a resume token or from before the server's position, and it has no
+	// Merkle proofs.
+	PrngAlgorithm_CRYPTO_RAND PrngAlgorithm = 6
+)
+
+// Enum value maps for PrngAlgorithm.
+var (
+	PrngAlgorithm_name = map[int32]string{
+		0: "MT19937",
+		1: "MT19937_64",
+		2: "XOSHIRO256_PLUS",
+		3: "XOSHIRO256_PLUS_PLUS",
+		4: "XOSHIRO256_STAR_STAR",
+		5: "CHACHA20",
+		6: "CRYPTO_RAND",
+	}
+	PrngAlgorithm_value = map[string]int32{
+		"MT19937":              0,
+		"MT19937_64":           1,
+		"XOSHIRO256_PLUS":      2,
+		"XOSHIRO256_PLUS_PLUS": 3,
+		"XOSHIRO256_STAR_STAR": 4,
+		"CHACHA20":             5,
+		"CRYPTO_RAND":          6,
+	}
+)
+
+func (x PrngAlgorithm) Enum() *PrngAlgorithm {
+	p := new(PrngAlgorithm)
+	*p = x
+	return p
+}
+
+func (x PrngAlgorithm) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PrngAlgorithm) Descriptor() protoreflect.EnumDescriptor {
+	return file_protocol_protocol_proto_enumTypes[1].Descriptor()
+}
+
+func (PrngAlgorithm) Type() protoreflect.EnumType {
+	return &file_protocol_protocol_proto_enumTypes[1]
+}
+
+func (x PrngAlgorithm) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PrngAlgorithm.Descriptor instead.
+func (PrngAlgorithm) EnumDescriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{1}
+}
+
+// The type of the values of a sequence.
+type ValueType int32
+
+const (
+	// The generator's raw output, in number and numbers.
+	ValueType_UINT32 ValueType = 0
+	// In int_value and int_values.
+	ValueType_INT64 ValueType = 1
+	// In float_value and float_values.
+	ValueType_FLOAT64 ValueType = 2
+)
+
+// Enum value maps for ValueType.
+var (
+	ValueType_name = map[int32]string{
+		0: "UINT32",
+		1: "INT64",
+		2: "FLOAT64",
+	}
+	ValueType_value = map[string]int32{
+		"UINT32":  0,
+		"INT64":   1,
+		"FLOAT64": 2,
+	}
+)
+
+func (x ValueType) Enum() *ValueType {
+	p := new(ValueType)
+	*p = x
+	return p
+}
+
+func (x ValueType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ValueType) Descriptor() protoreflect.EnumDescriptor {
+	return file_protocol_protocol_proto_enumTypes[2].Descriptor()
+}
+
+func (ValueType) Type() protoreflect.EnumType {
+	return &file_protocol_protocol_proto_enumTypes[2]
+}
+
+func (x ValueType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ValueType.Descriptor instead.
+func (ValueType) EnumDescriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{2}
+}
+
+// An inclusive range of integers.
+type Range struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Min int64 `protobuf:"varint,1,opt,name=min,proto3" json:"min,omitempty"`
+	Max int64 `protobuf:"varint,2,opt,name=max,proto3" json:"max,omitempty"`
+}
+
+func (x *Range) Reset() {
+	*x = Range{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Range) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Range) ProtoMessage() {}
+
+func (x *Range) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Range.ProtoReflect.Descriptor instead.
+func (*Range) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Range) GetMin() int64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *Range) GetMax() int64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+type Gaussian struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Mean float64 `protobuf:"fixed64,1,opt,name=mean,proto3" json:"mean,omitempty"`
+	// Must be greater than 0.
+	Stddev float64 `protobuf:"fixed64,2,opt,name=stddev,proto3" json:"stddev,omitempty"`
+}
+
+func (x *Gaussian) Reset() {
+	*x = Gaussian{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Gaussian) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Gaussian) ProtoMessage() {}
+
+func (x *Gaussian) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Gaussian.ProtoReflect.Descriptor instead.
+func (*Gaussian) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Gaussian) GetMean() float64 {
+	if x != nil {
+		return x.Mean
+	}
+	return 0
+}
+
+func (x *Gaussian) GetStddev() float64 {
+	if x != nil {
+		return x.Stddev
+	}
+	return 0
+}
+
+type Exponential struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Must be greater than 0.
+	Rate float64 `protobuf:"fixed64,1,opt,name=rate,proto3" json:"rate,omitempty"`
+}
+
+func (x *Exponential) Reset() {
+	*x = Exponential{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Exponential) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Exponential) ProtoMessage() {}
+
+func (x *Exponential) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Exponential.ProtoReflect.Descriptor instead.
+func (*Exponential) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Exponential) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+type Poisson struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Must be greater than 0 and at most 1e9.
+	Lambda float64 `protobuf:"fixed64,1,opt,name=lambda,proto3" json:"lambda,omitempty"`
+}
+
+func (x *Poisson) Reset() {
+	*x = Poisson{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Poisson) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Poisson) ProtoMessage() {}
+
+func (x *Poisson) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Poisson.ProtoReflect.Descriptor instead.
+func (*Poisson) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Poisson) GetLambda() float64 {
+	if x != nil {
+		return x.Lambda
+	}
+	return 0
+}
+
+// How each value of a sequence is drawn from the generator's output. The algorithms are documented in the
+// distribution package, so that a client can draw the values again from the seed.
+type Distribution struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Kind:
+	//	*Distribution_Uniform
+	//	*Distribution_Sample
+	//	*Distribution_Gaussian
+	//	*Distribution_Exponential
+	//	*Distribution_Poisson
+	Kind isDistribution_Kind `protobuf_oneof:"kind"`
+}
+
+func (x *Distribution) Reset() {
+	*x = Distribution{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Distribution) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Distribution) ProtoMessage() {}
+
+func (x *Distribution) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Distribution.ProtoReflect.Descriptor instead.
+func (*Distribution) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{4}
+}
+
+func (m *Distribution) GetKind() isDistribution_Kind {
+	if m != nil {
+		return m.Kind
+	}
+	return nil
+}
+
+func (x *Distribution) GetUniform() *Range {
+	if x, ok := x.GetKind().(*Distribution_Uniform); ok {
+		return x.Uniform
+	}
+	return nil
+}
+
+func (x *Distribution) GetSample() *Range {
+	if x, ok := x.GetKind().(*Distribution_Sample); ok {
+		return x.Sample
+	}
+	return nil
+}
+
+func (x *Distribution) GetGaussian() *Gaussian {
+	if x, ok := x.GetKind().(*Distribution_Gaussian); ok {
+		return x.Gaussian
+	}
+	return nil
+}
+
+func (x *Distribution) GetExponential() *Exponential {
+	if x, ok := x.GetKind().(*Distribution_Exponential); ok {
+		return x.Exponential
+	}
+	return nil
+}
+
+func (x *Distribution) GetPoisson() *Poisson {
+	if x, ok := x.GetKind().(*Distribution_Poisson); ok {
+		return x.Poisson
+	}
+	return nil
+}
+
+type isDistribution_Kind interface {
+	isDistribution_Kind()
+}
+
+type Distribution_Uniform struct {
+	// Integers spread evenly over the range, with no modulo bias. INT64 values.
+	Uniform *Range `protobuf:"bytes,1,opt,name=uniform,proto3,oneof"`
+}
+
+type Distribution_Sample struct {
+	// Integers from the range without repeats, like dealing from a shuffled deck. The range must hold at least
+	// num_numbers integers. INT64 values.
+	Sample *Range `protobuf:"bytes,2,opt,name=sample,proto3,oneof"`
+}
+
+type Distribution_Gaussian struct {
+	// FLOAT64 values.
+	Gaussian *Gaussian `protobuf:"bytes,3,opt,name=gaussian,proto3,oneof"`
+}
+
+type Distribution_Exponential struct {
+	// FLOAT64 values.
+	Exponential *Exponential `protobuf:"bytes,4,opt,name=exponential,proto3,oneof"`
+}
+
+type Distribution_Poisson struct {
+	// INT64 values.
+	Poisson *Poisson `protobuf:"bytes,5,opt,name=poisson,proto3,oneof"`
+}
+
+func (*Distribution_Uniform) isDistribution_Kind() {}
+
+func (*Distribution_Sample) isDistribution_Kind() {}
+
+func (*Distribution_Gaussian) isDistribution_Kind() {}
+
+func (*Distribution_Exponential) isDistribution_Kind() {}
+
+func (*Distribution_Poisson) isDistribution_Kind() {}
+
 type NumbersRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// UUIDv4 identifying the requesting client.
-	ClientId   []byte `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
-	NumNumbers uint32 `protobuf:"varint,2,opt,name=num_numbers,json=numNumbers,proto3" json:"num_numbers,omitempty"`
-	// Used for debugging/testing purposes. Specifies the seed for the server's PRNG.
-	Seed uint32 `protobuf:"varint,3,opt,name=seed,proto3" json:"seed,omitempty"`
+	// UUIDv4 identifying the requesting client, it must be exactly 16 bytes. When the server issues session IDs a
+	// new stream is requested with client_id left empty, and resumed with the session_id the server sent back.
+	ClientId []byte `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	// The length of the sequence, which the server clamps to its configured maximum. 0 leaves the length to
+	// duration_ms or unbounded, one of which must then be set.
+	NumNumbers uint32 `protobuf:"varint,2,opt,name=num_numbers,json=numNumbers,proto3" json:"num_numbers,omitempty"`
+	// Used for debugging/testing purposes. Specifies the seed for the server's PRNG. When neither it nor wide_seed is set
+	// the server picks the seed itself, commits to it in the first NumberResponse and reveals it with the checksum,
+	// see server_seed.
+	Seed uint32 `protobuf:"varint,3,opt,name=seed,proto3" json:"seed,omitempty"`
+	// Requested emission rate in numbers per second. Mutually exclusive with interval_ms.
+	Rate uint32 `protobuf:"varint,4,opt,name=rate,proto3" json:"rate,omitempty"`
+	// Requested delay in milliseconds between numbers. Mutually exclusive with rate.
+	// When neither rate nor interval_ms is set the server sends one number per second.
+	IntervalMs uint32 `protobuf:"varint,5,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`
+	// Index of the last NumberResponse the client received, 0 if it has not received any.
+	// When resuming, the server continues from the number following this index.
+	LastIndex uint32 `protobuf:"varint,6,opt,name=last_index,json=lastIndex,proto3" json:"last_index,omitempty"`
+	// Opt-in batching. When batch_size is greater than 1 the server packs up to batch_size numbers into the
+	// numbers field of each NumberResponse.
+	BatchSize uint32 `protobuf:"varint,7,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+	// Longest time in milliseconds a number may wait in a partially filled batch before it is sent.
+	// 0 means batches are only sent once full (or when the sequence ends).
+	FlushIntervalMs uint32 `protobuf:"varint,8,opt,name=flush_interval_ms,json=flushIntervalMs,proto3" json:"flush_interval_ms,omitempty"`
+	// The latest resume_token received on the stream being resumed. It lets a server without the stream's state
+	// resume it, provided the server holds the key that signed it.
+	ResumeToken []byte `protobuf:"bytes,9,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	// Checksum algorithms the client accepts, most preferred first. The server uses the first one it supports, or
+	// MD5_LEGACY if there are none. A resumed stream keeps the algorithm it was started with.
+	ChecksumAlgorithms []ChecksumAlgorithm `protobuf:"varint,10,rep,packed,name=checksum_algorithms,json=checksumAlgorithms,proto3,enum=protocol.ChecksumAlgorithm" json:"checksum_algorithms,omitempty"`
+	// Opt-in Merkle proofs. When set the sequence is split into chunks of merkle_chunk_size numbers, which must be a
+	// power of two of at least 16, and the server sends the root of the sequence's Merkle tree and a proof for every
+	// chunk. The tree is the one of RFC 6962 with SHA-256, and each number as 4 bytes in big-endian order a leaf.
+	MerkleChunkSize uint32 `protobuf:"varint,11,opt,name=merkle_chunk_size,json=merkleChunkSize,proto3" json:"merkle_chunk_size,omitempty"`
+	// Optional bytes, at most 64 of them, that the server mixes into the seed it picks, so that the sequence depends
+	// on a value of the client's choosing. Only valid when seed is 0, and ignored when resuming.
+	ClientSeed []byte `protobuf:"bytes,12,opt,name=client_seed,json=clientSeed,proto3" json:"client_seed,omitempty"`
+	// The generator to generate the sequence with. A resumed stream keeps the generator it was started with.
+	PrngAlgorithm PrngAlgorithm `protobuf:"varint,13,opt,name=prng_algorithm,json=prngAlgorithm,proto3,enum=protocol.PrngAlgorithm" json:"prng_algorithm,omitempty"`
+	// A 64 or 256 bit seed, as 8 or 32 bytes in big-endian order, used like seed. Mutually exclusive with seed. When
+	// the server picks the seed itself, the seed is 256 bits.
+	WideSeed []byte `protobuf:"bytes,14,opt,name=wide_seed,json=wideSeed,proto3" json:"wide_seed,omitempty"`
+	// How each value is drawn from the generator. When unset the values are the generator's raw UINT32 output. A
+	// resumed stream keeps the distribution it was started with. The checksum of a sequence of INT64 or FLOAT64
+	// values is never MD5_LEGACY: when the client accepts no other algorithm the server uses SHA256. Merkle proofs
+	// are only sent for UINT32 values.
+	Distribution *Distribution `protobuf:"bytes,15,opt,name=distribution,proto3" json:"distribution,omitempty"`
+	// The name of the source to take the numbers from, in place of prng_algorithm. Every generator is a source,
+	// named as the client's -prng option names it (e.g. "chacha20"), and a server may register others, such as a
+	// recorded sequence to replay or a device to read entropy from. Only a source that is a generator takes a seed.
+	// When empty the numbers are generated with prng_algorithm. A resumed stream keeps the source it was started
+	// with.
+	Source string `protobuf:"bytes,16,opt,name=source,proto3" json:"source,omitempty"`
+	// Bounds the stream by wall-clock time: the number sent once duration_ms milliseconds have passed since the
+	// stream was started is the last, and carries the checksum. When num_numbers is also set the stream ends at
+	// whichever bound it reaches first. The server clamps it to its configured maximum duration, which also bounds
+	// streams that didn't ask for one. A resumed stream keeps the bounds it was started with.
+	DurationMs uint32 `protobuf:"varint,17,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	// Asks for a stream with no length or duration, which runs until the client cancels it or it reaches the limits
+	// the server is configured with. Its last index can be at most 4294967295. Mutually exclusive with num_numbers
+	// and duration_ms.
+	Unbounded bool `protobuf:"varint,18,opt,name=unbounded,proto3" json:"unbounded,omitempty"`
+	// Opt-in checkpoints. The server sends a Checkpoint on every checkpoint_messages-th NumberResponse of the
+	// stream, and on the first NumberResponse sent once checkpoint_interval_ms milliseconds have passed since the
+	// last checkpoint (or the stream's start). Either may be 0 to leave that trigger out.
+	CheckpointMessages   uint32 `protobuf:"varint,19,opt,name=checkpoint_messages,json=checkpointMessages,proto3" json:"checkpoint_messages,omitempty"`
+	CheckpointIntervalMs uint32 `protobuf:"varint,20,opt,name=checkpoint_interval_ms,json=checkpointIntervalMs,proto3" json:"checkpoint_interval_ms,omitempty"`
+	// When resuming from a checkpoint, the checkpoint's checksum, with last_index set to the checkpoint's index. The
+	// server compares it with the checksum it sent at that index, and refuses the request with INVALID_ARGUMENT if it
+	// doesn't match. Only the last 4 checkpoints sent on a stream are kept, and for a stream resumed from a resume
+	// token only the last one sent before the token was issued, so resuming from an older checkpoint fails with
+	// FAILED_PRECONDITION; the stream can still be resumed from the same last_index without checkpoint_checksum.
+	// Ignored by GetAckedNumbers, which resumes from the last acknowledged number.
+	CheckpointChecksum string `protobuf:"bytes,21,opt,name=checkpoint_checksum,json=checkpointChecksum,proto3" json:"checkpoint_checksum,omitempty"`
+}
+
+func (x *NumbersRequest) Reset() {
+	*x = NumbersRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NumbersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NumbersRequest) ProtoMessage() {}
+
+func (x *NumbersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NumbersRequest.ProtoReflect.Descriptor instead.
+func (*NumbersRequest) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *NumbersRequest) GetClientId() []byte {
+	if x != nil {
+		return x.ClientId
+	}
+	return nil
+}
+
+func (x *NumbersRequest) GetNumNumbers() uint32 {
+	if x != nil {
+		return x.NumNumbers
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetSeed() uint32 {
+	if x != nil {
+		return x.Seed
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetRate() uint32 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetIntervalMs() uint32 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetLastIndex() uint32 {
+	if x != nil {
+		return x.LastIndex
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetBatchSize() uint32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetFlushIntervalMs() uint32 {
+	if x != nil {
+		return x.FlushIntervalMs
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetResumeToken() []byte {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return nil
+}
+
+func (x *NumbersRequest) GetChecksumAlgorithms() []ChecksumAlgorithm {
+	if x != nil {
+		return x.ChecksumAlgorithms
+	}
+	return nil
+}
+
+func (x *NumbersRequest) GetMerkleChunkSize() uint32 {
+	if x != nil {
+		return x.MerkleChunkSize
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetClientSeed() []byte {
+	if x != nil {
+		return x.ClientSeed
+	}
+	return nil
+}
+
+func (x *NumbersRequest) GetPrngAlgorithm() PrngAlgorithm {
+	if x != nil {
+		return x.PrngAlgorithm
+	}
+	return PrngAlgorithm_MT19937
+}
+
+func (x *NumbersRequest) GetWideSeed() []byte {
+	if x != nil {
+		return x.WideSeed
+	}
+	return nil
+}
+
+func (x *NumbersRequest) GetDistribution() *Distribution {
+	if x != nil {
+		return x.Distribution
+	}
+	return nil
+}
+
+func (x *NumbersRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *NumbersRequest) GetDurationMs() uint32 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetUnbounded() bool {
+	if x != nil {
+		return x.Unbounded
+	}
+	return false
+}
+
+func (x *NumbersRequest) GetCheckpointMessages() uint32 {
+	if x != nil {
+		return x.CheckpointMessages
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetCheckpointIntervalMs() uint32 {
+	if x != nil {
+		return x.CheckpointIntervalMs
+	}
+	return 0
+}
+
+func (x *NumbersRequest) GetCheckpointChecksum() string {
+	if x != nil {
+		return x.CheckpointChecksum
+	}
+	return ""
+}
+
+// The checksum of the sequence up to the last number of a NumberResponse, so that a stream with no known end can
+// still be checked, and resumed from, as it goes.
+type Checkpoint struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Index of the last number the checkpoint covers, which is the last number of the NumberResponse.
+	Index uint32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	// The checksum of the numbers at indexes 1 to index, calculated with checksum_algorithm as the final checksum
+	// is.
+	Checksum string `protobuf:"bytes,2,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	// When the server has a signing key, its signature of the statement that it sent index numbers with this
+	// checksum, signed as the final checksum is. seed_commitment is set alongside it.
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *Checkpoint) Reset() {
+	*x = Checkpoint{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Checkpoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Checkpoint) ProtoMessage() {}
+
+func (x *Checkpoint) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Checkpoint.ProtoReflect.Descriptor instead.
+func (*Checkpoint) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Checkpoint) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Checkpoint) GetChecksum() string {
+	if x != nil {
+		return x.Checksum
+	}
+	return ""
+}
+
+func (x *Checkpoint) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// Proves that a chunk of the sequence belongs to the Merkle tree whose root is merkle_root.
+type ChunkProof struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Position of the chunk in the sequence, the first chunk is 0 and holds the numbers at indexes 1 to
+	// merkle_chunk_size.
+	Chunk uint32 `protobuf:"varint,1,opt,name=chunk,proto3" json:"chunk,omitempty"`
+	// Root of the chunk's subtree.
+	Root []byte `protobuf:"bytes,2,opt,name=root,proto3" json:"root,omitempty"`
+	// Audit path from the chunk's root to merkle_root, lowest sibling first.
+	Path [][]byte `protobuf:"bytes,3,rep,name=path,proto3" json:"path,omitempty"`
+}
+
+func (x *ChunkProof) Reset() {
+	*x = ChunkProof{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ChunkProof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkProof) ProtoMessage() {}
+
+func (x *ChunkProof) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkProof.ProtoReflect.Descriptor instead.
+func (*ChunkProof) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ChunkProof) GetChunk() uint32 {
+	if x != nil {
+		return x.Chunk
+	}
+	return 0
+}
+
+func (x *ChunkProof) GetRoot() []byte {
+	if x != nil {
+		return x.Root
+	}
+	return nil
+}
+
+func (x *ChunkProof) GetPath() [][]byte {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+type NumberResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Unused in batch mode, see numbers, and for values of any type but UINT32, see int_value and float_value.
+	Number uint32 `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	// When a NumberResponse is the last message for a NumbersRequest the checkum is set, otherwise it is an empty string.
+	// The last message is the one with the last number of the sequence, or the one sent once the stream's duration
+	// has passed.
+	Checksum string `protobuf:"bytes,2,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	// Position of the number in the sequence, the first number has index 1.
+	// In batch mode this is the index of the first number in numbers.
+	Index uint32 `protobuf:"varint,3,opt,name=index,proto3" json:"index,omitempty"`
+	// The numbers of a batch, in sequence order. Only set in batch mode.
+	Numbers []uint32 `protobuf:"varint,4,rep,packed,name=numbers,proto3" json:"numbers,omitempty"`
+	// Only set on the first NumberResponse of a stream the server issued a session ID for. The client resumes the
+	// stream by sending it back as client_id.
+	SessionId []byte `protobuf:"bytes,5,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Set when the server issues resume tokens, on a NumberResponse at most once per the server's token interval.
+	// The token is sealed by the server, so the client can neither read nor alter it, and is valid until the stream
+	// would have expired.
+	ResumeToken []byte `protobuf:"bytes,6,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	// The algorithm of the checksum and of chain.
+	ChecksumAlgorithm ChecksumAlgorithm `protobuf:"varint,7,opt,name=checksum_algorithm,json=checksumAlgorithm,proto3,enum=protocol.ChecksumAlgorithm" json:"checksum_algorithm,omitempty"`
+	// H_i for the last number in the NumberResponse, where i is its index, so that the client can check each
+	// NumberResponse as it arrives. Not set for MD5_LEGACY, which isn't chained.
+	Chain []byte `protobuf:"bytes,8,opt,name=chain,proto3" json:"chain,omitempty"`
+	// When merkle_chunk_size was requested, the root of the Merkle tree of the whole sequence. Only set on the first
+	// NumberResponse of a stream.
+	MerkleRoot []byte `protobuf:"bytes,9,opt,name=merkle_root,json=merkleRoot,proto3" json:"merkle_root,omitempty"`
+	// When merkle_chunk_size was requested, a proof for each chunk whose last number is in the NumberResponse.
+	ChunkProofs []*ChunkProof `protobuf:"bytes,10,rep,name=chunk_proofs,json=chunkProofs,proto3" json:"chunk_proofs,omitempty"`
+	// The commitment to the sequence's seed. When the server picked the seed it is SHA-256(server_seed ||
+	// seed_nonce), set on the first NumberResponse of every stream and alongside the checksum. Otherwise it is a
+	// SHA-256 of the seed, only set alongside the checksum when the server has a signing key.
+	SeedCommitment []byte `protobuf:"bytes,11,opt,name=seed_commitment,json=seedCommitment,proto3" json:"seed_commitment,omitempty"`
+	// When the server has a signing key, set alongside the checksum: the server's Ed25519 signature of the
+	// client_id, seed_commitment, number of numbers in the sequence, checksum_algorithm and checksum. The signed
+	// encoding is documented in the signing package.
+	Signature []byte `protobuf:"bytes,12,opt,name=signature,proto3" json:"signature,omitempty"`
+	// When the server picked the seed, set alongside the checksum: the server seed and the nonce seed_commitment was
+	// made with. The PRNG was given the 256 bit seed SHA-256(server_seed || client_seed), so the client can check
+	// the commitment and replay the whole sequence.
+	ServerSeed []byte `protobuf:"bytes,13,opt,name=server_seed,json=serverSeed,proto3" json:"server_seed,omitempty"`
+	SeedNonce  []byte `protobuf:"bytes,14,opt,name=seed_nonce,json=seedNonce,proto3" json:"seed_nonce,omitempty"`
+	// Set on the first NumberResponse of every stream: the generator the sequence is generated with, and the width
+	// of its seed in bits, which is 0 for CRYPTO_RAND.
+	PrngAlgorithm PrngAlgorithm `protobuf:"varint,15,opt,name=prng_algorithm,json=prngAlgorithm,proto3,enum=protocol.PrngAlgorithm" json:"prng_algorithm,omitempty"`
+	SeedBits      uint32        `protobuf:"varint,16,opt,name=seed_bits,json=seedBits,proto3" json:"seed_bits,omitempty"`
+	// The value of an INT64 or FLOAT64 sequence outside of batch mode, in place of number.
+	IntValue   int64   `protobuf:"varint,17,opt,name=int_value,json=intValue,proto3" json:"int_value,omitempty"`
+	FloatValue float64 `protobuf:"fixed64,18,opt,name=float_value,json=floatValue,proto3" json:"float_value,omitempty"`
+	// The values of a batch of an INT64 or FLOAT64 sequence, in place of numbers.
+	IntValues   []int64   `protobuf:"varint,19,rep,packed,name=int_values,json=intValues,proto3" json:"int_values,omitempty"`
+	FloatValues []float64 `protobuf:"fixed64,20,rep,packed,name=float_values,json=floatValues,proto3" json:"float_values,omitempty"`
+	// Set on the first NumberResponse of every stream whose values are drawn from a distribution.
+	Distribution *Distribution `protobuf:"bytes,21,opt,name=distribution,proto3" json:"distribution,omitempty"`
+	// Set on the first NumberResponse of every stream: the name of the source the numbers are taken from.
+	// prng_algorithm is only meaningful when the source is a generator.
+	Source string `protobuf:"bytes,22,opt,name=source,proto3" json:"source,omitempty"`
+	// Set when checkpoints were requested and this NumberResponse is due one. The last NumberResponse carries the
+	// checksum instead.
+	Checkpoint *Checkpoint `protobuf:"bytes,23,opt,name=checkpoint,proto3" json:"checkpoint,omitempty"`
+}
+
+func (x *NumberResponse) Reset() {
+	*x = NumberResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NumberResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NumberResponse) ProtoMessage() {}
+
+func (x *NumberResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NumberResponse.ProtoReflect.Descriptor instead.
+func (*NumberResponse) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *NumberResponse) GetNumber() uint32 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *NumberResponse) GetChecksum() string {
+	if x != nil {
+		return x.Checksum
+	}
+	return ""
+}
+
+func (x *NumberResponse) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *NumberResponse) GetNumbers() []uint32 {
+	if x != nil {
+		return x.Numbers
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetSessionId() []byte {
+	if x != nil {
+		return x.SessionId
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetResumeToken() []byte {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetChecksumAlgorithm() ChecksumAlgorithm {
+	if x != nil {
+		return x.ChecksumAlgorithm
+	}
+	return ChecksumAlgorithm_MD5_LEGACY
+}
+
+func (x *NumberResponse) GetChain() []byte {
+	if x != nil {
+		return x.Chain
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetMerkleRoot() []byte {
+	if x != nil {
+		return x.MerkleRoot
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetChunkProofs() []*ChunkProof {
+	if x != nil {
+		return x.ChunkProofs
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetSeedCommitment() []byte {
+	if x != nil {
+		return x.SeedCommitment
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetServerSeed() []byte {
+	if x != nil {
+		return x.ServerSeed
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetSeedNonce() []byte {
+	if x != nil {
+		return x.SeedNonce
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetPrngAlgorithm() PrngAlgorithm {
+	if x != nil {
+		return x.PrngAlgorithm
+	}
+	return PrngAlgorithm_MT19937
+}
+
+func (x *NumberResponse) GetSeedBits() uint32 {
+	if x != nil {
+		return x.SeedBits
+	}
+	return 0
+}
+
+func (x *NumberResponse) GetIntValue() int64 {
+	if x != nil {
+		return x.IntValue
+	}
+	return 0
+}
+
+func (x *NumberResponse) GetFloatValue() float64 {
+	if x != nil {
+		return x.FloatValue
+	}
+	return 0
+}
+
+func (x *NumberResponse) GetIntValues() []int64 {
+	if x != nil {
+		return x.IntValues
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetFloatValues() []float64 {
+	if x != nil {
+		return x.FloatValues
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetDistribution() *Distribution {
+	if x != nil {
+		return x.Distribution
+	}
+	return nil
+}
+
+func (x *NumberResponse) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *NumberResponse) GetCheckpoint() *Checkpoint {
+	if x != nil {
+		return x.Checkpoint
+	}
+	return nil
+}
+
+type PublicKeyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *PublicKeyRequest) Reset() {
+	*x = PublicKeyRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
 }
 
-func (x *NumbersRequest) Reset() {
-	*x = NumbersRequest{}
+func (x *PublicKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublicKeyRequest) ProtoMessage() {}
+
+func (x *PublicKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublicKeyRequest.ProtoReflect.Descriptor instead.
+func (*PublicKeyRequest) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{9}
+}
+
+type PublicKeyResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The Ed25519 public key the server signs the final NumberResponse of each stream with.
+	Ed25519PublicKey []byte `protobuf:"bytes,1,opt,name=ed25519_public_key,json=ed25519PublicKey,proto3" json:"ed25519_public_key,omitempty"`
+}
+
+func (x *PublicKeyResponse) Reset() {
+	*x = PublicKeyResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_protocol_protocol_proto_msgTypes[0]
+		mi := &file_protocol_protocol_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *NumbersRequest) String() string {
+func (x *PublicKeyResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*NumbersRequest) ProtoMessage() {}
+func (*PublicKeyResponse) ProtoMessage() {}
 
-func (x *NumbersRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_protocol_protocol_proto_msgTypes[0]
+func (x *PublicKeyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -59,59 +1277,94 @@ func (x *NumbersRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use NumbersRequest.ProtoReflect.Descriptor instead.
-func (*NumbersRequest) Descriptor() ([]byte, []int) {
-	return file_protocol_protocol_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use PublicKeyResponse.ProtoReflect.Descriptor instead.
+func (*PublicKeyResponse) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *NumbersRequest) GetClientId() []byte {
+func (x *PublicKeyResponse) GetEd25519PublicKey() []byte {
 	if x != nil {
-		return x.ClientId
+		return x.Ed25519PublicKey
 	}
 	return nil
 }
 
-func (x *NumbersRequest) GetNumNumbers() uint32 {
-	if x != nil {
-		return x.NumNumbers
+// Acknowledges every number up to and including index as processed by the client.
+type Ack struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Index uint32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+}
+
+func (x *Ack) Reset() {
+	*x = Ack{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_protocol_protocol_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return 0
 }
 
-func (x *NumbersRequest) GetSeed() uint32 {
+func (x *Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ack) ProtoMessage() {}
+
+func (x *Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ack.ProtoReflect.Descriptor instead.
+func (*Ack) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Ack) GetIndex() uint32 {
 	if x != nil {
-		return x.Seed
+		return x.Index
 	}
 	return 0
 }
 
-type NumberResponse struct {
+type AckedNumbersRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Number uint32 `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
-	// When a NumberResponse is the last message for a NumbersRequest the checkum is set, otherwise it is an empty string.
-	Checksum string `protobuf:"bytes,2,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	// Types that are assignable to Message:
+	//	*AckedNumbersRequest_Request
+	//	*AckedNumbersRequest_Ack
+	Message isAckedNumbersRequest_Message `protobuf_oneof:"message"`
 }
 
-func (x *NumberResponse) Reset() {
-	*x = NumberResponse{}
+func (x *AckedNumbersRequest) Reset() {
+	*x = AckedNumbersRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_protocol_protocol_proto_msgTypes[1]
+		mi := &file_protocol_protocol_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *NumberResponse) String() string {
+func (x *AckedNumbersRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*NumberResponse) ProtoMessage() {}
+func (*AckedNumbersRequest) ProtoMessage() {}
 
-func (x *NumberResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_protocol_protocol_proto_msgTypes[1]
+func (x *AckedNumbersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protocol_protocol_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -122,51 +1375,252 @@ func (x *NumberResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use NumberResponse.ProtoReflect.Descriptor instead.
-func (*NumberResponse) Descriptor() ([]byte, []int) {
-	return file_protocol_protocol_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use AckedNumbersRequest.ProtoReflect.Descriptor instead.
+func (*AckedNumbersRequest) Descriptor() ([]byte, []int) {
+	return file_protocol_protocol_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *NumberResponse) GetNumber() uint32 {
-	if x != nil {
-		return x.Number
+func (m *AckedNumbersRequest) GetMessage() isAckedNumbersRequest_Message {
+	if m != nil {
+		return m.Message
 	}
-	return 0
+	return nil
 }
 
-func (x *NumberResponse) GetChecksum() string {
-	if x != nil {
-		return x.Checksum
+func (x *AckedNumbersRequest) GetRequest() *NumbersRequest {
+	if x, ok := x.GetMessage().(*AckedNumbersRequest_Request); ok {
+		return x.Request
 	}
-	return ""
+	return nil
+}
+
+func (x *AckedNumbersRequest) GetAck() *Ack {
+	if x, ok := x.GetMessage().(*AckedNumbersRequest_Ack); ok {
+		return x.Ack
+	}
+	return nil
+}
+
+type isAckedNumbersRequest_Message interface {
+	isAckedNumbersRequest_Message()
 }
 
+type AckedNumbersRequest_Request struct {
+	// Must be the first message sent on a GetAckedNumbers stream.
+	Request *NumbersRequest `protobuf:"bytes,1,opt,name=request,proto3,oneof"`
+}
+
+type AckedNumbersRequest_Ack struct {
+	Ack *Ack `protobuf:"bytes,2,opt,name=ack,proto3,oneof"`
+}
+
+func (*AckedNumbersRequest_Request) isAckedNumbersRequest_Message() {}
+
+func (*AckedNumbersRequest_Ack) isAckedNumbersRequest_Message() {}
+
 var File_protocol_protocol_proto protoreflect.FileDescriptor
 
 var file_protocol_protocol_proto_rawDesc = []byte{
 	0x0a, 0x17, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
 	0x63, 0x6f, 0x6c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x63, 0x6f, 0x6c, 0x22, 0x62, 0x0a, 0x0e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f,
-	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74,
-	0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x6e, 0x75, 0x6d, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72,
-	0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x6e, 0x75, 0x6d, 0x4e, 0x75, 0x6d, 0x62,
-	0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x65, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28,
-	0x0d, 0x52, 0x04, 0x73, 0x65, 0x65, 0x64, 0x22, 0x44, 0x0a, 0x0e, 0x4e, 0x75, 0x6d, 0x62, 0x65,
-	0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d,
-	0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65,
-	0x72, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x32, 0x4d, 0x0a,
-	0x07, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x42, 0x0a, 0x0a, 0x47, 0x65, 0x74, 0x4e,
-	0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x18, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f,
-	0x6c, 0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x1a, 0x18, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x4e, 0x75, 0x6d, 0x62,
-	0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x40, 0x5a, 0x3e,
-	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x61, 0x6d, 0x65, 0x73,
-	0x72, 0x6f, 0x62, 0x62, 0x2f, 0x61, 0x62, 0x6c, 0x79, 0x2d, 0x74, 0x61, 0x6b, 0x65, 0x68, 0x6f,
-	0x6d, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2f, 0x67, 0x65, 0x6e, 0x65,
-	0x72, 0x61, 0x74, 0x65, 0x64, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x63, 0x6f, 0x6c, 0x22, 0x2b, 0x0a, 0x05, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x10, 0x0a, 0x03,
+	0x6d, 0x69, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x6d, 0x69, 0x6e, 0x12, 0x10,
+	0x0a, 0x03, 0x6d, 0x61, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x6d, 0x61, 0x78,
+	0x22, 0x36, 0x0a, 0x08, 0x47, 0x61, 0x75, 0x73, 0x73, 0x69, 0x61, 0x6e, 0x12, 0x12, 0x0a, 0x04,
+	0x6d, 0x65, 0x61, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x6d, 0x65, 0x61, 0x6e,
+	0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x64, 0x64, 0x65, 0x76, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x06, 0x73, 0x74, 0x64, 0x64, 0x65, 0x76, 0x22, 0x21, 0x0a, 0x0b, 0x45, 0x78, 0x70, 0x6f,
+	0x6e, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x61, 0x74, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x72, 0x61, 0x74, 0x65, 0x22, 0x21, 0x0a, 0x07, 0x50,
+	0x6f, 0x69, 0x73, 0x73, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x61, 0x6d, 0x62, 0x64, 0x61,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x6c, 0x61, 0x6d, 0x62, 0x64, 0x61, 0x22, 0x8a,
+	0x02, 0x0a, 0x0c, 0x44, 0x69, 0x73, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x2b, 0x0a, 0x07, 0x75, 0x6e, 0x69, 0x66, 0x6f, 0x72, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x52, 0x61, 0x6e, 0x67,
+	0x65, 0x48, 0x00, 0x52, 0x07, 0x75, 0x6e, 0x69, 0x66, 0x6f, 0x72, 0x6d, 0x12, 0x29, 0x0a, 0x06,
+	0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x48, 0x00, 0x52,
+	0x06, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x12, 0x30, 0x0a, 0x08, 0x67, 0x61, 0x75, 0x73, 0x73,
+	0x69, 0x61, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x47, 0x61, 0x75, 0x73, 0x73, 0x69, 0x61, 0x6e, 0x48, 0x00, 0x52,
+	0x08, 0x67, 0x61, 0x75, 0x73, 0x73, 0x69, 0x61, 0x6e, 0x12, 0x39, 0x0a, 0x0b, 0x65, 0x78, 0x70,
+	0x6f, 0x6e, 0x65, 0x6e, 0x74, 0x69, 0x61, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x6e, 0x65,
+	0x6e, 0x74, 0x69, 0x61, 0x6c, 0x48, 0x00, 0x52, 0x0b, 0x65, 0x78, 0x70, 0x6f, 0x6e, 0x65, 0x6e,
+	0x74, 0x69, 0x61, 0x6c, 0x12, 0x2d, 0x0a, 0x07, 0x70, 0x6f, 0x69, 0x73, 0x73, 0x6f, 0x6e, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c,
+	0x2e, 0x50, 0x6f, 0x69, 0x73, 0x73, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x07, 0x70, 0x6f, 0x69, 0x73,
+	0x73, 0x6f, 0x6e, 0x42, 0x06, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x22, 0xc7, 0x06, 0x0a, 0x0e,
+	0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b,
+	0x0a, 0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x6e,
+	0x75, 0x6d, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x0a, 0x6e, 0x75, 0x6d, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04,
+	0x73, 0x65, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x73, 0x65, 0x65, 0x64,
+	0x12, 0x12, 0x0a, 0x04, 0x72, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04,
+	0x72, 0x61, 0x74, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c,
+	0x5f, 0x6d, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x69, 0x6e, 0x74, 0x65, 0x72,
+	0x76, 0x61, 0x6c, 0x4d, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x69, 0x6e,
+	0x64, 0x65, 0x78, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x6c, 0x61, 0x73, 0x74, 0x49,
+	0x6e, 0x64, 0x65, 0x78, 0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x61, 0x74, 0x63, 0x68, 0x5f, 0x73, 0x69,
+	0x7a, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x62, 0x61, 0x74, 0x63, 0x68, 0x53,
+	0x69, 0x7a, 0x65, 0x12, 0x2a, 0x0a, 0x11, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x5f, 0x69, 0x6e, 0x74,
+	0x65, 0x72, 0x76, 0x61, 0x6c, 0x5f, 0x6d, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0f,
+	0x66, 0x6c, 0x75, 0x73, 0x68, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x4d, 0x73, 0x12,
+	0x21, 0x0a, 0x0c, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x54, 0x6f, 0x6b,
+	0x65, 0x6e, 0x12, 0x4c, 0x0a, 0x13, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x5f, 0x61,
+	0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x0e, 0x32,
+	0x1b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x43, 0x68, 0x65, 0x63, 0x6b,
+	0x73, 0x75, 0x6d, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x12, 0x63, 0x68,
+	0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x73,
+	0x12, 0x2a, 0x0a, 0x11, 0x6d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x5f, 0x63, 0x68, 0x75, 0x6e, 0x6b,
+	0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0f, 0x6d, 0x65, 0x72,
+	0x6b, 0x6c, 0x65, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x1f, 0x0a, 0x0b,
+	0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x73, 0x65, 0x65, 0x64, 0x18, 0x0c, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x0a, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x53, 0x65, 0x65, 0x64, 0x12, 0x3e, 0x0a,
+	0x0e, 0x70, 0x72, 0x6e, 0x67, 0x5f, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18,
+	0x0d, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c,
+	0x2e, 0x50, 0x72, 0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x0d,
+	0x70, 0x72, 0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x1b, 0x0a,
+	0x09, 0x77, 0x69, 0x64, 0x65, 0x5f, 0x73, 0x65, 0x65, 0x64, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x08, 0x77, 0x69, 0x64, 0x65, 0x53, 0x65, 0x65, 0x64, 0x12, 0x3a, 0x0a, 0x0c, 0x64, 0x69,
+	0x73, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x16, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x44, 0x69, 0x73, 0x74,
+	0x72, 0x69, 0x62, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0c, 0x64, 0x69, 0x73, 0x74, 0x72, 0x69,
+	0x62, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
+	0x18, 0x10, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x1f,
+	0x0a, 0x0b, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6d, 0x73, 0x18, 0x11, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0a, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4d, 0x73, 0x12,
+	0x1c, 0x0a, 0x09, 0x75, 0x6e, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x65, 0x64, 0x18, 0x12, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x09, 0x75, 0x6e, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x65, 0x64, 0x12, 0x2f, 0x0a,
+	0x13, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x5f, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x73, 0x18, 0x13, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x12, 0x63, 0x68, 0x65, 0x63,
+	0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x12, 0x34,
+	0x0a, 0x16, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x5f, 0x69, 0x6e, 0x74,
+	0x65, 0x72, 0x76, 0x61, 0x6c, 0x5f, 0x6d, 0x73, 0x18, 0x14, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x14,
+	0x63, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76,
+	0x61, 0x6c, 0x4d, 0x73, 0x12, 0x2f, 0x0a, 0x13, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x5f, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18, 0x15, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x12, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x43, 0x68, 0x65,
+	0x63, 0x6b, 0x73, 0x75, 0x6d, 0x22, 0x5c, 0x0a, 0x0a, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f,
+	0x69, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x68, 0x65,
+	0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x68, 0x65,
+	0x63, 0x6b, 0x73, 0x75, 0x6d, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74,
+	0x75, 0x72, 0x65, 0x22, 0x4a, 0x0a, 0x0a, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x50, 0x72, 0x6f, 0x6f,
+	0x66, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x05, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70,
+	0x61, 0x74, 0x68, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x22,
+	0xe0, 0x06, 0x0a, 0x0e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x68,
+	0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x68,
+	0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x18, 0x0a, 0x07,
+	0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0d, 0x52, 0x07, 0x6e,
+	0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x5f,
+	0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x72, 0x65, 0x73,
+	0x75, 0x6d, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x4a, 0x0a, 0x12, 0x63, 0x68, 0x65, 0x63,
+	0x6b, 0x73, 0x75, 0x6d, 0x5f, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x07,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e,
+	0x43, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68,
+	0x6d, 0x52, 0x11, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x41, 0x6c, 0x67, 0x6f, 0x72,
+	0x69, 0x74, 0x68, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x65,
+	0x72, 0x6b, 0x6c, 0x65, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x0a, 0x6d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x52, 0x6f, 0x6f, 0x74, 0x12, 0x37, 0x0a, 0x0c, 0x63,
+	0x68, 0x75, 0x6e, 0x6b, 0x5f, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x43, 0x68, 0x75,
+	0x6e, 0x6b, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x52, 0x0b, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x50, 0x72,
+	0x6f, 0x6f, 0x66, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x73, 0x65, 0x65, 0x64, 0x5f, 0x63, 0x6f, 0x6d,
+	0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0e, 0x73,
+	0x65, 0x65, 0x64, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x1c, 0x0a,
+	0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x73,
+	0x65, 0x72, 0x76, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x65, 0x64, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x0a, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x53, 0x65, 0x65, 0x64, 0x12, 0x1d, 0x0a, 0x0a,
+	0x73, 0x65, 0x65, 0x64, 0x5f, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x09, 0x73, 0x65, 0x65, 0x64, 0x4e, 0x6f, 0x6e, 0x63, 0x65, 0x12, 0x3e, 0x0a, 0x0e, 0x70,
+	0x72, 0x6e, 0x67, 0x5f, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x0f, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x50,
+	0x72, 0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x0d, 0x70, 0x72,
+	0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x1b, 0x0a, 0x09, 0x73,
+	0x65, 0x65, 0x64, 0x5f, 0x62, 0x69, 0x74, 0x73, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08,
+	0x73, 0x65, 0x65, 0x64, 0x42, 0x69, 0x74, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x69, 0x6e, 0x74, 0x5f,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x11, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x69, 0x6e, 0x74,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x66, 0x6c, 0x6f, 0x61, 0x74, 0x5f, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x12, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0a, 0x66, 0x6c, 0x6f, 0x61,
+	0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x69, 0x6e, 0x74, 0x5f, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x73, 0x18, 0x13, 0x20, 0x03, 0x28, 0x03, 0x52, 0x09, 0x69, 0x6e, 0x74, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x66, 0x6c, 0x6f, 0x61, 0x74, 0x5f, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x14, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0b, 0x66, 0x6c, 0x6f,
+	0x61, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x12, 0x3a, 0x0a, 0x0c, 0x64, 0x69, 0x73, 0x74,
+	0x72, 0x69, 0x62, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x15, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x44, 0x69, 0x73, 0x74, 0x72, 0x69,
+	0x62, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0c, 0x64, 0x69, 0x73, 0x74, 0x72, 0x69, 0x62, 0x75,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x16,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x34, 0x0a, 0x0a,
+	0x63, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x17, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x14, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x43, 0x68, 0x65, 0x63,
+	0x6b, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x52, 0x0a, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x22, 0x12, 0x0a, 0x10, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x41, 0x0a, 0x11, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63,
+	0x4b, 0x65, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x12, 0x65,
+	0x64, 0x32, 0x35, 0x35, 0x31, 0x39, 0x5f, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x6b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x10, 0x65, 0x64, 0x32, 0x35, 0x35, 0x31, 0x39,
+	0x50, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x22, 0x1b, 0x0a, 0x03, 0x41, 0x63, 0x6b,
+	0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x22, 0x79, 0x0a, 0x13, 0x41, 0x63, 0x6b, 0x65, 0x64, 0x4e,
+	0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x34, 0x0a,
+	0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x07, 0x72, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x21, 0x0a, 0x03, 0x61, 0x63, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0d, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x41, 0x63, 0x6b, 0x48,
+	0x00, 0x52, 0x03, 0x61, 0x63, 0x6b, 0x42, 0x09, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x2a, 0x5a, 0x0a, 0x11, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x41, 0x6c, 0x67,
+	0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x0e, 0x0a, 0x0a, 0x4d, 0x44, 0x35, 0x5f, 0x4c, 0x45,
+	0x47, 0x41, 0x43, 0x59, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36,
+	0x10, 0x01, 0x12, 0x0f, 0x0a, 0x0b, 0x42, 0x4c, 0x41, 0x4b, 0x45, 0x32, 0x42, 0x5f, 0x32, 0x35,
+	0x36, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x52, 0x43, 0x33, 0x32, 0x43, 0x10, 0x03, 0x12,
+	0x0c, 0x0a, 0x08, 0x58, 0x58, 0x48, 0x41, 0x53, 0x48, 0x36, 0x34, 0x10, 0x04, 0x2a, 0x94, 0x01,
+	0x0a, 0x0d, 0x50, 0x72, 0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12,
+	0x0b, 0x0a, 0x07, 0x4d, 0x54, 0x31, 0x39, 0x39, 0x33, 0x37, 0x10, 0x00, 0x12, 0x0e, 0x0a, 0x0a,
+	0x4d, 0x54, 0x31, 0x39, 0x39, 0x33, 0x37, 0x5f, 0x36, 0x34, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f,
+	0x58, 0x4f, 0x53, 0x48, 0x49, 0x52, 0x4f, 0x32, 0x35, 0x36, 0x5f, 0x50, 0x4c, 0x55, 0x53, 0x10,
+	0x02, 0x12, 0x18, 0x0a, 0x14, 0x58, 0x4f, 0x53, 0x48, 0x49, 0x52, 0x4f, 0x32, 0x35, 0x36, 0x5f,
+	0x50, 0x4c, 0x55, 0x53, 0x5f, 0x50, 0x4c, 0x55, 0x53, 0x10, 0x03, 0x12, 0x18, 0x0a, 0x14, 0x58,
+	0x4f, 0x53, 0x48, 0x49, 0x52, 0x4f, 0x32, 0x35, 0x36, 0x5f, 0x53, 0x54, 0x41, 0x52, 0x5f, 0x53,
+	0x54, 0x41, 0x52, 0x10, 0x04, 0x12, 0x0c, 0x0a, 0x08, 0x43, 0x48, 0x41, 0x43, 0x48, 0x41, 0x32,
+	0x30, 0x10, 0x05, 0x12, 0x0f, 0x0a, 0x0b, 0x43, 0x52, 0x59, 0x50, 0x54, 0x4f, 0x5f, 0x52, 0x41,
+	0x4e, 0x44, 0x10, 0x06, 0x2a, 0x2f, 0x0a, 0x09, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x0a, 0x0a, 0x06, 0x55, 0x49, 0x4e, 0x54, 0x33, 0x32, 0x10, 0x00, 0x12, 0x09, 0x0a,
+	0x05, 0x49, 0x4e, 0x54, 0x36, 0x34, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x46, 0x4c, 0x4f, 0x41,
+	0x54, 0x36, 0x34, 0x10, 0x02, 0x32, 0xe6, 0x01, 0x0a, 0x07, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72,
+	0x73, 0x12, 0x42, 0x0a, 0x0a, 0x47, 0x65, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12,
+	0x18, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x4e, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x41, 0x63, 0x6b, 0x65,
+	0x64, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x1d, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x63, 0x6f, 0x6c, 0x2e, 0x41, 0x63, 0x6b, 0x65, 0x64, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63,
+	0x6f, 0x6c, 0x2e, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x28, 0x01, 0x30, 0x01, 0x12, 0x47, 0x0a, 0x0c, 0x47, 0x65, 0x74, 0x50, 0x75, 0x62, 0x6c,
+	0x69, 0x63, 0x4b, 0x65, 0x79, 0x12, 0x1a, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c,
+	0x2e, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x50, 0x75, 0x62,
+	0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x40,
+	0x5a, 0x3e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x61, 0x6d,
+	0x65, 0x73, 0x72, 0x6f, 0x62, 0x62, 0x2f, 0x61, 0x62, 0x6c, 0x79, 0x2d, 0x74, 0x61, 0x6b, 0x65,
+	0x68, 0x6f, 0x6d, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2f, 0x67, 0x65,
+	0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x64, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -181,19 +1635,53 @@ func file_protocol_protocol_proto_rawDescGZIP() []byte {
 	return file_protocol_protocol_proto_rawDescData
 }
 
-var file_protocol_protocol_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_protocol_protocol_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_protocol_protocol_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_protocol_protocol_proto_goTypes = []interface{}{
-	(*NumbersRequest)(nil), // 0: protocol.NumbersRequest
-	(*NumberResponse)(nil), // 1: protocol.NumberResponse
+	(ChecksumAlgorithm)(0),      // 0: protocol.ChecksumAlgorithm
+	(PrngAlgorithm)(0),          // 1: protocol.PrngAlgorithm
+	(ValueType)(0),              // 2: protocol.ValueType
+	(*Range)(nil),               // 3: protocol.Range
+	(*Gaussian)(nil),            // 4: protocol.Gaussian
+	(*Exponential)(nil),         // 5: protocol.Exponential
+	(*Poisson)(nil),             // 6: protocol.Poisson
+	(*Distribution)(nil),        // 7: protocol.Distribution
+	(*NumbersRequest)(nil),      // 8: protocol.NumbersRequest
+	(*Checkpoint)(nil),          // 9: protocol.Checkpoint
+	(*ChunkProof)(nil),          // 10: protocol.ChunkProof
+	(*NumberResponse)(nil),      // 11: protocol.NumberResponse
+	(*PublicKeyRequest)(nil),    // 12: protocol.PublicKeyRequest
+	(*PublicKeyResponse)(nil),   // 13: protocol.PublicKeyResponse
+	(*Ack)(nil),                 // 14: protocol.Ack
+	(*AckedNumbersRequest)(nil), // 15: protocol.AckedNumbersRequest
 }
 var file_protocol_protocol_proto_depIdxs = []int32{
-	0, // 0: protocol.Numbers.GetNumbers:input_type -> protocol.NumbersRequest
-	1, // 1: protocol.Numbers.GetNumbers:output_type -> protocol.NumberResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	3,  // 0: protocol.Distribution.uniform:type_name -> protocol.Range
+	3,  // 1: protocol.Distribution.sample:type_name -> protocol.Range
+	4,  // 2: protocol.Distribution.gaussian:type_name -> protocol.Gaussian
+	5,  // 3: protocol.Distribution.exponential:type_name -> protocol.Exponential
+	6,  // 4: protocol.Distribution.poisson:type_name -> protocol.Poisson
+	0,  // 5: protocol.NumbersRequest.checksum_algorithms:type_name -> protocol.ChecksumAlgorithm
+	1,  // 6: protocol.NumbersRequest.prng_algorithm:type_name -> protocol.PrngAlgorithm
+	7,  // 7: protocol.NumbersRequest.distribution:type_name -> protocol.Distribution
+	0,  // 8: protocol.NumberResponse.checksum_algorithm:type_name -> protocol.ChecksumAlgorithm
+	10, // 9: protocol.NumberResponse.chunk_proofs:type_name -> protocol.ChunkProof
+	1,  // 10: protocol.NumberResponse.prng_algorithm:type_name -> protocol.PrngAlgorithm
+	7,  // 11: protocol.NumberResponse.distribution:type_name -> protocol.Distribution
+	9,  // 12: protocol.NumberResponse.checkpoint:type_name -> protocol.Checkpoint
+	8,  // 13: protocol.AckedNumbersRequest.request:type_name -> protocol.NumbersRequest
+	14, // 14: protocol.AckedNumbersRequest.ack:type_name -> protocol.Ack
+	8,  // 15: protocol.Numbers.GetNumbers:input_type -> protocol.NumbersRequest
+	15, // 16: protocol.Numbers.GetAckedNumbers:input_type -> protocol.AckedNumbersRequest
+	12, // 17: protocol.Numbers.GetPublicKey:input_type -> protocol.PublicKeyRequest
+	11, // 18: protocol.Numbers.GetNumbers:output_type -> protocol.NumberResponse
+	11, // 19: protocol.Numbers.GetAckedNumbers:output_type -> protocol.NumberResponse
+	13, // 20: protocol.Numbers.GetPublicKey:output_type -> protocol.PublicKeyResponse
+	18, // [18:21] is the sub-list for method output_type
+	15, // [15:18] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
 }
 
 func init() { file_protocol_protocol_proto_init() }
@@ -203,7 +1691,7 @@ func file_protocol_protocol_proto_init() {
 	}
 	if !protoimpl.UnsafeEnabled {
 		file_protocol_protocol_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*NumbersRequest); i {
+			switch v := v.(*Range); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -215,6 +1703,90 @@ func file_protocol_protocol_proto_init() {
 			}
 		}
 		file_protocol_protocol_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Gaussian); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Exponential); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Poisson); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Distribution); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NumbersRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Checkpoint); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ChunkProof); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*NumberResponse); i {
 			case 0:
 				return &v.state
@@ -226,19 +1798,79 @@ func file_protocol_protocol_proto_init() {
 				return nil
 			}
 		}
+		file_protocol_protocol_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PublicKeyRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PublicKeyResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Ack); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_protocol_protocol_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AckedNumbersRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_protocol_protocol_proto_msgTypes[4].OneofWrappers = []interface{}{
+		(*Distribution_Uniform)(nil),
+		(*Distribution_Sample)(nil),
+		(*Distribution_Gaussian)(nil),
+		(*Distribution_Exponential)(nil),
+		(*Distribution_Poisson)(nil),
+	}
+	file_protocol_protocol_proto_msgTypes[12].OneofWrappers = []interface{}{
+		(*AckedNumbersRequest_Request)(nil),
+		(*AckedNumbersRequest_Ack)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_protocol_protocol_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      3,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_protocol_protocol_proto_goTypes,
 		DependencyIndexes: file_protocol_protocol_proto_depIdxs,
+		EnumInfos:         file_protocol_protocol_proto_enumTypes,
 		MessageInfos:      file_protocol_protocol_proto_msgTypes,
 	}.Build()
 	File_protocol_protocol_proto = out.File
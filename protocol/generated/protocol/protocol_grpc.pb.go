@@ -23,6 +23,12 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type NumbersClient interface {
 	GetNumbers(ctx context.Context, in *NumbersRequest, opts ...grpc.CallOption) (Numbers_GetNumbersClient, error)
+	// Like GetNumbers, but the server only persists progress up to the last acknowledged index.
+	// Numbers that were not acknowledged are sent again when the client resumes, so the client
+	// should discard any number whose index it has already processed.
+	GetAckedNumbers(ctx context.Context, opts ...grpc.CallOption) (Numbers_GetAckedNumbersClient, error)
+	// Returns the key the server signs with, or fails with NOT_FOUND if the server doesn't sign.
+	GetPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*PublicKeyResponse, error)
 }
 
 type numbersClient struct {
@@ -65,11 +71,57 @@ func (x *numbersGetNumbersClient) Recv() (*NumberResponse, error) {
 	return m, nil
 }
 
+func (c *numbersClient) GetAckedNumbers(ctx context.Context, opts ...grpc.CallOption) (Numbers_GetAckedNumbersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Numbers_ServiceDesc.Streams[1], "/protocol.Numbers/GetAckedNumbers", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &numbersGetAckedNumbersClient{stream}
+	return x, nil
+}
+
+type Numbers_GetAckedNumbersClient interface {
+	Send(*AckedNumbersRequest) error
+	Recv() (*NumberResponse, error)
+	grpc.ClientStream
+}
+
+type numbersGetAckedNumbersClient struct {
+	grpc.ClientStream
+}
+
+func (x *numbersGetAckedNumbersClient) Send(m *AckedNumbersRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *numbersGetAckedNumbersClient) Recv() (*NumberResponse, error) {
+	m := new(NumberResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *numbersClient) GetPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*PublicKeyResponse, error) {
+	out := new(PublicKeyResponse)
+	err := c.cc.Invoke(ctx, "/protocol.Numbers/GetPublicKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NumbersServer is the server API for Numbers service.
 // All implementations must embed UnimplementedNumbersServer
 // for forward compatibility
 type NumbersServer interface {
 	GetNumbers(*NumbersRequest, Numbers_GetNumbersServer) error
+	// Like GetNumbers, but the server only persists progress up to the last acknowledged index.
+	// Numbers that were not acknowledged are sent again when the client resumes, so the client
+	// should discard any number whose index it has already processed.
+	GetAckedNumbers(Numbers_GetAckedNumbersServer) error
+	// Returns the key the server signs with, or fails with NOT_FOUND if the server doesn't sign.
+	GetPublicKey(context.Context, *PublicKeyRequest) (*PublicKeyResponse, error)
 	mustEmbedUnimplementedNumbersServer()
 }
 
@@ -80,6 +132,12 @@ type UnimplementedNumbersServer struct {
 func (UnimplementedNumbersServer) GetNumbers(*NumbersRequest, Numbers_GetNumbersServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetNumbers not implemented")
 }
+func (UnimplementedNumbersServer) GetAckedNumbers(Numbers_GetAckedNumbersServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetAckedNumbers not implemented")
+}
+func (UnimplementedNumbersServer) GetPublicKey(context.Context, *PublicKeyRequest) (*PublicKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPublicKey not implemented")
+}
 func (UnimplementedNumbersServer) mustEmbedUnimplementedNumbersServer() {}
 
 // UnsafeNumbersServer may be embedded to opt out of forward compatibility for this service.
@@ -114,19 +172,74 @@ func (x *numbersGetNumbersServer) Send(m *NumberResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Numbers_GetAckedNumbers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(NumbersServer).GetAckedNumbers(&numbersGetAckedNumbersServer{stream})
+}
+
+type Numbers_GetAckedNumbersServer interface {
+	Send(*NumberResponse) error
+	Recv() (*AckedNumbersRequest, error)
+	grpc.ServerStream
+}
+
+type numbersGetAckedNumbersServer struct {
+	grpc.ServerStream
+}
+
+func (x *numbersGetAckedNumbersServer) Send(m *NumberResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *numbersGetAckedNumbersServer) Recv() (*AckedNumbersRequest, error) {
+	m := new(AckedNumbersRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Numbers_GetPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublicKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NumbersServer).GetPublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protocol.Numbers/GetPublicKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NumbersServer).GetPublicKey(ctx, req.(*PublicKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Numbers_ServiceDesc is the grpc.ServiceDesc for Numbers service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var Numbers_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "protocol.Numbers",
 	HandlerType: (*NumbersServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPublicKey",
+			Handler:    _Numbers_GetPublicKey_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "GetNumbers",
 			Handler:       _Numbers_GetNumbers_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "GetAckedNumbers",
+			Handler:       _Numbers_GetAckedNumbers_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "protocol/protocol.proto",
 }
@@ -0,0 +1,113 @@
+// Package signing signs and verifies the statement a server makes in the last NumberResponse of a stream, so that a
+// client can prove to anyone holding the server's public key that the sequence it received came from the server.
+// The server and the client both use it, so that they always encode a statement the same way.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// CONTEXT starts every signed statement, so that a signature over a statement can't be passed off as a signature
+// over anything else the key might sign.
+const CONTEXT = "ably-takehome final checksum v1\x00"
+
+// Statement is what the server signs at the end of a stream: that it sent the client Count numbers, generated from
+// the seed (or taken from the source) committed to by SeedCommitment, whose checksum with Algorithm is Checksum.
+type Statement struct {
+	ClientID       uuid.UUID
+	SeedCommitment []byte
+	Count          uint32
+	Algorithm      protocol.ChecksumAlgorithm
+	Checksum       string
+}
+
+// encode lays the statement out as follows, with integers in big-endian order:
+//
+//	context            CONTEXT
+//	client ID          [16]byte
+//	commitment length  u8, followed by the seed commitment
+//	count              u32
+//	algorithm          u8
+//	checksum length    u8, followed by the hex encoded checksum
+func (s *Statement) encode() []byte {
+	data := make([]byte, 0, len(CONTEXT)+16+1+len(s.SeedCommitment)+4+1+1+len(s.Checksum))
+	data = append(data, CONTEXT...)
+	data = append(data, s.ClientID[:]...)
+	data = append(data, byte(len(s.SeedCommitment)))
+	data = append(data, s.SeedCommitment...)
+	data = binary.BigEndian.AppendUint32(data, s.Count)
+	data = append(data, byte(s.Algorithm))
+	data = append(data, byte(len(s.Checksum)))
+	data = append(data, s.Checksum...)
+
+	return data
+}
+
+// Sign returns the signature of the statement by key.
+func (s *Statement) Sign(key ed25519.PrivateKey) []byte {
+	return ed25519.Sign(key, s.encode())
+}
+
+// Verify returns an error unless signature is the signature of the statement by the private half of key.
+func (s *Statement) Verify(key ed25519.PublicKey, signature []byte) error {
+	if !ed25519.Verify(key, s.encode(), signature) {
+		return fmt.Errorf("signature %x is not key %x's signature of the statement that clientID=%s was sent %d numbers with %s checksum %s",
+			signature, []byte(key), s.ClientID, s.Count, s.Algorithm, s.Checksum)
+	}
+
+	return nil
+}
+
+// SeedCommitment returns the commitment to seed, for a sequence generated by algorithm, that a statement is signed
+// with. It identifies the sequence without stating the seed, though it doesn't keep a 32 or 64 bit seed from anyone
+// willing to hash every one.
+func SeedCommitment(algorithm protocol.PrngAlgorithm, seed []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("ably-takehome seed v2\x00"))
+	h.Write([]byte{byte(algorithm), byte(len(seed))})
+	h.Write(seed)
+
+	return h.Sum(nil)
+}
+
+// SourceCommitment returns the commitment that a statement is signed with for a sequence taken from the source a
+// server registered as name, rather than generated from a seed. content is a digest of the numbers the source
+// emits, or empty for a source that never emits the same numbers twice.
+func SourceCommitment(name string, content []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("ably-takehome source v1\x00"))
+	h.Write([]byte{byte(len(name))})
+	h.Write([]byte(name))
+	h.Write([]byte{byte(len(content))})
+	h.Write(content)
+
+	return h.Sum(nil)
+}
+
+// ParsePrivateKey parses a hex encoded 32 byte Ed25519 private key seed.
+func ParsePrivateKey(keyHex string) (ed25519.PrivateKey, error) {
+	seed, err := hex.DecodeString(keyHex)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key must be a hex encoded %d byte Ed25519 seed", ed25519.SeedSize)
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ParsePublicKey parses a hex encoded Ed25519 public key.
+func ParsePublicKey(keyHex string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be a hex encoded %d byte Ed25519 public key", ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(key), nil
+}
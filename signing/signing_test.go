@@ -0,0 +1,81 @@
+package signing
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/jamesrobb/ably-takehome/generator"
+	"github.com/jamesrobb/ably-takehome/protocol/generated/protocol"
+)
+
+// The key of the first test vector of RFC 8032.
+const (
+	testPrivateKey = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+	testPublicKey  = "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+)
+
+// testStatement is for the SHA256 checksum of the numbers 1, 2 and 4294967295 generated by MT19937 from 5489.
+func testStatement() *Statement {
+	return &Statement{
+		ClientID:       uuid.MustParse("00112233-4455-6677-8899-aabbccddeeff"),
+		SeedCommitment: SeedCommitment(protocol.PrngAlgorithm_MT19937, generator.Uint32Seed(5489)),
+		Count:          3,
+		Algorithm:      protocol.ChecksumAlgorithm_SHA256,
+		Checksum:       "af0369d13298e1ed0e2b3acc40169a394afff3118a12c34a38b410aead621a04",
+	}
+}
+
+// TestCommitments checks commitments worked out independently of this package from their documented encoding.
+func TestCommitments(t *testing.T) {
+	if got, want := hex.EncodeToString(SeedCommitment(protocol.PrngAlgorithm_MT19937, generator.Uint32Seed(5489))),
+		"640f781365aae88c00df79a9c2e6dff92a67f0af9adb26225756dc1ddbf3c012"; got != want {
+		t.Errorf("seed commitment is %s, want %s", got, want)
+	}
+	if got, want := hex.EncodeToString(SourceCommitment("script", nil)),
+		"2dd791b90bb491fd8403daf29751a1da658dd4589639989be739c77a28a9c57e"; got != want {
+		t.Errorf("source commitment is %s, want %s", got, want)
+	}
+}
+
+// TestSign checks the signature of a statement, worked out independently of this package from the statement's
+// encoding, and that it only verifies for that statement.
+func TestSign(t *testing.T) {
+	const want = "f1fee7d970999a6267581fcd0cdb355af8528bccfa8141dba2b7e27b2a2b5886" +
+		"3ee30f512cf680961dd7a1bf440e1fb4512a1119b3bad63a1ac7c60ce7ddfd04"
+
+	key, err := ParsePrivateKey(testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := ParsePublicKey(testPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := testStatement()
+	signature := s.Sign(key)
+	if got := hex.EncodeToString(signature); got != want {
+		t.Errorf("signature is %s, want %s", got, want)
+	}
+	if err := s.Verify(public, signature); err != nil {
+		t.Error(err)
+	}
+
+	s.Count++
+	if err := s.Verify(public, signature); err == nil {
+		t.Error("signature verified for a statement with a different count")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	for _, keyHex := range []string{"", "9d61", testPrivateKey + "00", "zz" + testPrivateKey[2:]} {
+		if _, err := ParsePrivateKey(keyHex); err == nil {
+			t.Errorf("ParsePrivateKey(%q) succeeded", keyHex)
+		}
+		if _, err := ParsePublicKey(keyHex); err == nil {
+			t.Errorf("ParsePublicKey(%q) succeeded", keyHex)
+		}
+	}
+}
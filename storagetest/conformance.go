@@ -0,0 +1,517 @@
+// Package storagetest checks that a client state storage behaves as the number server expects, and provides what
+// storages are tested with: a clock that only moves when told to and a fake of the Redis protocol server.
+//
+// The checks don't know what a state holds, the server's tests describe that with States.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clock tells a storage the time, which its states expire by.
+type Clock interface {
+	Now() time.Time
+}
+
+// ManualClock is a Clock that only moves when it is told to.
+type ManualClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Storage is a client state storage holding states of type S.
+type Storage[S any] interface {
+	GetState(ctx context.Context, clientID uuid.UUID) (S, error)
+	SetState(ctx context.Context, clientID uuid.UUID, state S) error
+	DeleteState(ctx context.Context, clientID uuid.UUID, epoch uint64) error
+}
+
+// States makes, moves on and compares the states the checks store.
+type States[S any] interface {
+	// New returns the state of a stream for seed with numbersSent numbers sent, written in epoch and last updated at
+	// lastUpdated. Calls with the same arguments return equal states.
+	New(seed uint32, numbersSent uint32, epoch uint64, lastUpdated time.Time) S
+	// Variants returns states last updated at lastUpdated that between them hold every kind of value a state can,
+	// each of which must round trip exactly.
+	Variants(lastUpdated time.Time) []Variant[S]
+	// Advance moves s on by one number, updating it at now.
+	Advance(s S, now time.Time)
+	NumbersSent(s S) uint32
+	// Compare returns an error describing the first difference between got and want, or nil if they are equal.
+	Compare(got S, want S) error
+}
+
+// Variant is a state the round trip check stores.
+type Variant[S any] struct {
+	Name  string
+	State S
+	// Continues is set if the stored state must carry on with the same numbers as the original, which a state whose
+	// generator can't be replayed doesn't.
+	Continues bool
+}
+
+type Config[S any] struct {
+	// NewStorage creates an empty storage for each check, which must take the time from clock so that the checks can
+	// move it past TTL without waiting. Storages that implement io.Closer are closed after each check.
+	NewStorage func(clock Clock) (Storage[S], error)
+	States     States[S]
+	// TTL is how long after its last update a state expires.
+	TTL time.Duration
+	// ErrNotFound, ErrExpired and ErrFenced are the errors the storage returns for an unknown client, an expired
+	// client and a write from an older epoch.
+	ErrNotFound error
+	ErrExpired  error
+	ErrFenced   error
+}
+
+// check is one behaviour every storage must have. Each check is given a new storage.
+type check[S any] struct {
+	name string
+	run  func(c *checker[S], ctx context.Context, storage Storage[S], clock *ManualClock) error
+}
+
+// checker holds the configuration the checks run with.
+type checker[S any] struct {
+	Config[S]
+}
+
+// Run checks that the storages config creates behave as the number server expects: states round trip exactly, are
+// kept separate per client, are deleted and expire as they should, return the documented errors, refuse writes from
+// older epochs, and survive concurrent use. Each check runs as a subtest of t.
+func Run[S any](t *testing.T, config Config[S]) {
+	checks := []check[S]{
+		{"unknown client", (*checker[S]).checkUnknownClient},
+		{"round trip", (*checker[S]).checkRoundTrip},
+		{"stored state is a snapshot", (*checker[S]).checkSnapshot},
+		{"overwrite", (*checker[S]).checkOverwrite},
+		{"delete", (*checker[S]).checkDelete},
+		{"expiry", (*checker[S]).checkExpiry},
+		{"update postpones expiry", (*checker[S]).checkExpiryPostponed},
+		{"expired client can't be written", (*checker[S]).checkExpiredWrite},
+		{"writes are fenced by epoch", (*checker[S]).checkFencing},
+		{"concurrent writers to separate clients", (*checker[S]).checkConcurrentClients},
+		{"concurrent writers to one client", (*checker[S]).checkConcurrentWriters},
+	}
+
+	c := &checker[S]{config}
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			clock := NewManualClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+			storage, err := c.NewStorage(clock)
+			if err != nil {
+				t.Fatalf("unable to create storage: %s", err)
+			}
+			if closer, ok := storage.(io.Closer); ok {
+				defer func() {
+					if err := closer.Close(); err != nil {
+						t.Errorf("unable to close storage: %s", err)
+					}
+				}()
+			}
+
+			if err := check.run(c, context.Background(), storage, clock); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// state returns the state for seed with numbersSent numbers sent in epoch 0, last updated at the clock's time.
+func (c *checker[S]) state(seed uint32, numbersSent uint32, clock Clock) S {
+	return c.States.New(seed, numbersSent, 0, clock.Now())
+}
+
+func (c *checker[S]) checkUnknownClient(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	clientID := uuid.New()
+
+	if _, err := storage.GetState(ctx, clientID); !errors.Is(err, c.ErrNotFound) {
+		return fmt.Errorf("GetState of an unknown client returned %v, want ErrNotFound", err)
+	}
+
+	return nil
+}
+
+func (c *checker[S]) checkRoundTrip(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	for _, variant := range c.States.Variants(clock.Now()) {
+		clientID := uuid.New()
+		want := variant.State
+
+		if err := storage.SetState(ctx, clientID, want); err != nil {
+			return fmt.Errorf("%s: SetState: %s", variant.Name, err)
+		}
+		got, err := storage.GetState(ctx, clientID)
+		if err != nil {
+			return fmt.Errorf("%s: GetState: %s", variant.Name, err)
+		}
+		if err := c.States.Compare(got, want); err != nil {
+			return fmt.Errorf("%s: %s", variant.Name, err)
+		}
+
+		// The stream must carry on from where it was.
+		if !variant.Continues {
+			continue
+		}
+		for i := 0; i < 3; i++ {
+			c.States.Advance(got, clock.Now())
+			c.States.Advance(want, clock.Now())
+			if err := c.States.Compare(got, want); err != nil {
+				return fmt.Errorf("%s: after advancing %d times: %s", variant.Name, i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *checker[S]) checkSnapshot(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	clientID := uuid.New()
+	s := c.state(1, 2, clock)
+	want := c.state(1, 2, clock)
+
+	if err := storage.SetState(ctx, clientID, s); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+	c.States.Advance(s, clock.Now())
+
+	got, err := storage.GetState(ctx, clientID)
+	if err != nil {
+		return fmt.Errorf("GetState: %s", err)
+	}
+	if err := c.States.Compare(got, want); err != nil {
+		return fmt.Errorf("stored state changed with the caller's copy: %s", err)
+	}
+
+	return nil
+}
+
+func (c *checker[S]) checkOverwrite(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	clientID := uuid.New()
+	otherID := uuid.New()
+
+	if err := storage.SetState(ctx, clientID, c.state(1, 1, clock)); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+	if err := storage.SetState(ctx, otherID, c.state(2, 7, clock)); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+	want := c.state(1, 4, clock)
+	if err := storage.SetState(ctx, clientID, want); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+
+	got, err := storage.GetState(ctx, clientID)
+	if err != nil {
+		return fmt.Errorf("GetState: %s", err)
+	}
+	if err := c.States.Compare(got, want); err != nil {
+		return err
+	}
+
+	got, err = storage.GetState(ctx, otherID)
+	if err != nil {
+		return fmt.Errorf("GetState of another client: %s", err)
+	}
+	if err := c.States.Compare(got, c.state(2, 7, clock)); err != nil {
+		return fmt.Errorf("another client's state changed: %s", err)
+	}
+
+	return nil
+}
+
+func (c *checker[S]) checkDelete(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	clientID := uuid.New()
+	otherID := uuid.New()
+
+	if err := storage.DeleteState(ctx, uuid.New(), 0); err != nil {
+		return fmt.Errorf("DeleteState of an unknown client: %s", err)
+	}
+
+	if err := storage.SetState(ctx, clientID, c.state(1, 1, clock)); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+	if err := storage.SetState(ctx, otherID, c.state(2, 1, clock)); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+	if err := storage.DeleteState(ctx, clientID, 0); err != nil {
+		return fmt.Errorf("DeleteState: %s", err)
+	}
+
+	if _, err := storage.GetState(ctx, clientID); !errors.Is(err, c.ErrNotFound) {
+		return fmt.Errorf("GetState of a deleted client returned %v, want ErrNotFound", err)
+	}
+	if _, err := storage.GetState(ctx, otherID); err != nil {
+		return fmt.Errorf("GetState of another client: %s", err)
+	}
+
+	// A finished stream's client isn't expired, even once its state would have expired.
+	clock.Advance(c.TTL + time.Second)
+	if _, err := storage.GetState(ctx, clientID); !errors.Is(err, c.ErrNotFound) {
+		return fmt.Errorf("GetState of a deleted client returned %v after the TTL, want ErrNotFound", err)
+	}
+
+	return nil
+}
+
+func (c *checker[S]) checkExpiry(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	clientID := uuid.New()
+
+	if err := storage.SetState(ctx, clientID, c.state(1, 1, clock)); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+
+	clock.Advance(c.TTL - time.Second)
+	if _, err := storage.GetState(ctx, clientID); err != nil {
+		return fmt.Errorf("GetState before the TTL: %s", err)
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, err := storage.GetState(ctx, clientID); !errors.Is(err, c.ErrExpired) {
+		return fmt.Errorf("GetState after the TTL returned %v, want ErrExpired", err)
+	}
+	// Looking the client up again mustn't forget that it expired.
+	if _, err := storage.GetState(ctx, clientID); !errors.Is(err, c.ErrExpired) {
+		return fmt.Errorf("second GetState after the TTL returned %v, want ErrExpired", err)
+	}
+
+	return nil
+}
+
+func (c *checker[S]) checkExpiryPostponed(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	clientID := uuid.New()
+
+	if err := storage.SetState(ctx, clientID, c.state(1, 1, clock)); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+	clock.Advance(c.TTL - time.Second)
+	if err := storage.SetState(ctx, clientID, c.state(1, 2, clock)); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+
+	// Past the first state's expiry but not the second's.
+	clock.Advance(2 * time.Second)
+	if _, err := storage.GetState(ctx, clientID); err != nil {
+		return fmt.Errorf("GetState after an earlier state would have expired: %s", err)
+	}
+
+	return nil
+}
+
+func (c *checker[S]) checkExpiredWrite(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	clientID := uuid.New()
+
+	if err := storage.SetState(ctx, clientID, c.state(1, 1, clock)); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+
+	// Storing the state again mustn't extend its expiry once it has passed, however long ago that was, so the write
+	// is tried both straight away and once a storage that removes expired states in the background has had time to.
+	clock.Advance(c.TTL + time.Second)
+	if err := storage.SetState(ctx, clientID, c.state(1, 2, clock)); !errors.Is(err, c.ErrExpired) {
+		return fmt.Errorf("SetState after the TTL returned %v, want ErrExpired", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := storage.SetState(ctx, clientID, c.state(1, 3, clock)); !errors.Is(err, c.ErrExpired) {
+		return fmt.Errorf("second SetState after the TTL returned %v, want ErrExpired", err)
+	}
+	if _, err := storage.GetState(ctx, clientID); !errors.Is(err, c.ErrExpired) {
+		return fmt.Errorf("GetState after writing an expired client returned %v, want ErrExpired", err)
+	}
+
+	return nil
+}
+
+func (c *checker[S]) checkFencing(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	clientID := uuid.New()
+	atEpoch := func(numbersSent uint32, epoch uint64) S {
+		return c.States.New(1, numbersSent, epoch, clock.Now())
+	}
+
+	want := atEpoch(3, 5)
+	if err := storage.SetState(ctx, clientID, want); err != nil {
+		return fmt.Errorf("SetState: %s", err)
+	}
+
+	if err := storage.SetState(ctx, clientID, atEpoch(4, 4)); !errors.Is(err, c.ErrFenced) {
+		return fmt.Errorf("SetState from an older epoch returned %v, want ErrFenced", err)
+	}
+	if err := storage.DeleteState(ctx, clientID, 4); !errors.Is(err, c.ErrFenced) {
+		return fmt.Errorf("DeleteState from an older epoch returned %v, want ErrFenced", err)
+	}
+	got, err := storage.GetState(ctx, clientID)
+	if err != nil {
+		return fmt.Errorf("GetState: %s", err)
+	}
+	if err := c.States.Compare(got, want); err != nil {
+		return fmt.Errorf("fenced write changed the state: %s", err)
+	}
+
+	// The same epoch may keep writing, and a newer one takes over.
+	if err := storage.SetState(ctx, clientID, atEpoch(4, 5)); err != nil {
+		return fmt.Errorf("SetState from the same epoch: %s", err)
+	}
+	if err := storage.SetState(ctx, clientID, atEpoch(2, 6)); err != nil {
+		return fmt.Errorf("SetState from a newer epoch: %s", err)
+	}
+	if err := storage.SetState(ctx, clientID, atEpoch(5, 5)); !errors.Is(err, c.ErrFenced) {
+		return fmt.Errorf("SetState from a superseded epoch returned %v, want ErrFenced", err)
+	}
+	if err := storage.DeleteState(ctx, clientID, 6); err != nil {
+		return fmt.Errorf("DeleteState from the current epoch: %s", err)
+	}
+	if _, err := storage.GetState(ctx, clientID); !errors.Is(err, c.ErrNotFound) {
+		return fmt.Errorf("GetState after DeleteState returned %v, want ErrNotFound", err)
+	}
+
+	return nil
+}
+
+// concurrentErrors collects the errors of concurrently running goroutines.
+type concurrentErrors struct {
+	lock sync.Mutex
+	errs []string
+}
+
+func (ce *concurrentErrors) add(format string, args ...any) {
+	ce.lock.Lock()
+	defer ce.lock.Unlock()
+
+	ce.errs = append(ce.errs, fmt.Sprintf(format, args...))
+}
+
+func (ce *concurrentErrors) err() error {
+	if len(ce.errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%s", strings.Join(ce.errs, "; "))
+}
+
+func (c *checker[S]) checkConcurrentClients(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	const clients = 16
+	const updates = 20
+
+	var errs concurrentErrors
+	var wg sync.WaitGroup
+
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(seed uint32) {
+			defer wg.Done()
+
+			clientID := uuid.New()
+			s := c.state(seed, 0, clock)
+			for i := 0; i < updates; i++ {
+				c.States.Advance(s, clock.Now())
+				if err := storage.SetState(ctx, clientID, s); err != nil {
+					errs.add("SetState: %s", err)
+					return
+				}
+
+				got, err := storage.GetState(ctx, clientID)
+				if err != nil {
+					errs.add("GetState: %s", err)
+					return
+				}
+				if err := c.States.Compare(got, s); err != nil {
+					errs.add("seed %d: %s", seed, err)
+					return
+				}
+			}
+
+			if err := storage.DeleteState(ctx, clientID, 0); err != nil {
+				errs.add("DeleteState: %s", err)
+				return
+			}
+			if _, err := storage.GetState(ctx, clientID); !errors.Is(err, c.ErrNotFound) {
+				errs.add("GetState of a deleted client returned %v, want ErrNotFound", err)
+			}
+		}(uint32(i + 1))
+	}
+	wg.Wait()
+
+	return errs.err()
+}
+
+func (c *checker[S]) checkConcurrentWriters(ctx context.Context, storage Storage[S], clock *ManualClock) error {
+	const writers = 8
+	const updates = 20
+
+	clientID := uuid.New()
+	// Writer w stores the state with w+1 numbers sent, every state read must be one of them intact.
+	states := make([]S, writers)
+	for w := range states {
+		states[w] = c.state(1, uint32(w+1), clock)
+	}
+	checkRead := func() error {
+		got, err := storage.GetState(ctx, clientID)
+		if err != nil {
+			return fmt.Errorf("GetState: %s", err)
+		}
+		numbersSent := c.States.NumbersSent(got)
+		if numbersSent < 1 || numbersSent > writers {
+			return fmt.Errorf("read numbersSent=%d, which was never written", numbersSent)
+		}
+		if err := c.States.Compare(got, states[numbersSent-1]); err != nil {
+			return fmt.Errorf("read a torn state: %s", err)
+		}
+
+		return nil
+	}
+
+	var errs concurrentErrors
+	var wg sync.WaitGroup
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			// Each writer works on its own copy, so the states compared against stay untouched.
+			s := c.state(1, uint32(w+1), clock)
+			for i := 0; i < updates; i++ {
+				if err := storage.SetState(ctx, clientID, s); err != nil {
+					errs.add("SetState: %s", err)
+					return
+				}
+				if err := checkRead(); err != nil {
+					errs.add("%s", err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if err := checkRead(); err != nil {
+		errs.add("after all writes: %s", err)
+	}
+
+	return errs.err()
+}
@@ -0,0 +1,250 @@
+package storagetest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeRedis is a small in-process server that speaks enough of the Redis protocol (PING, GET, SET with PX, DEL,
+// EXISTS, and WATCH/MULTI/EXEC transactions) for RedisStorage, so that the storage can be exercised without a real
+// Redis.
+type fakeRedis struct {
+	clock Clock
+	lock  sync.Mutex
+	keys  map[string]fakeRedisValue
+	// lastVersion is the version given to the most recent write, a WATCHed key has changed if its version has.
+	lastVersion uint64
+}
+
+type fakeRedisValue struct {
+	data []byte
+	// expires is zero for a key without a TTL.
+	expires time.Time
+	version uint64
+}
+
+// fakeRedisSession is the transaction state of one connection.
+type fakeRedisSession struct {
+	// watched maps each WATCHed key to its version when it was watched, 0 if it didn't exist.
+	watched map[string]uint64
+	// queued holds the commands of an open MULTI, it is nil outside of one.
+	queued [][]string
+}
+
+// StartFakeRedis listens on addr and serves connections in the background until the returned listener is closed.
+// Keys expire by clock, which should be the clock of the storage using the server.
+func StartFakeRedis(addr string, clock Clock) (net.Listener, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	fr := &fakeRedis{
+		clock: clock,
+		keys:  make(map[string]fakeRedisValue),
+	}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go fr.serve(conn)
+		}
+	}()
+
+	return lis, nil
+}
+
+func (fr *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	session := &fakeRedisSession{}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if errors.Is(err, errMalformedCommand) {
+				writer.WriteString("-ERR expected a command array of bulk strings\r\n")
+				writer.Flush()
+			}
+			return
+		}
+
+		fr.lock.Lock()
+		fr.handle(writer, session, args)
+		fr.lock.Unlock()
+
+		// Flush once the pipelined commands have all been answered.
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handle runs a command in the context of a connection's transaction state. The caller holds fr.lock.
+func (fr *fakeRedis) handle(w *bufio.Writer, session *fakeRedisSession, args []string) {
+	cmd := strings.ToUpper(args[0])
+
+	if session.queued != nil && cmd != "EXEC" && cmd != "DISCARD" {
+		session.queued = append(session.queued, args)
+		w.WriteString("+QUEUED\r\n")
+		return
+	}
+
+	switch {
+	case cmd == "WATCH" && len(args) >= 2:
+		if session.watched == nil {
+			session.watched = make(map[string]uint64)
+		}
+		for _, key := range args[1:] {
+			value, _ := fr.get(key)
+			session.watched[key] = value.version
+		}
+		w.WriteString("+OK\r\n")
+	case cmd == "UNWATCH":
+		session.watched = nil
+		w.WriteString("+OK\r\n")
+	case cmd == "MULTI":
+		session.queued = [][]string{}
+		w.WriteString("+OK\r\n")
+	case cmd == "DISCARD" && session.queued != nil:
+		session.queued = nil
+		session.watched = nil
+		w.WriteString("+OK\r\n")
+	case cmd == "EXEC" && session.queued != nil:
+		queued := session.queued
+		watched := session.watched
+		session.queued = nil
+		session.watched = nil
+
+		for key, version := range watched {
+			if value, _ := fr.get(key); value.version != version {
+				w.WriteString("*-1\r\n")
+				return
+			}
+		}
+
+		fmt.Fprintf(w, "*%d\r\n", len(queued))
+		for _, args := range queued {
+			fr.execute(w, args)
+		}
+	default:
+		fr.execute(w, args)
+	}
+}
+
+// execute runs a command that reads or writes keys. The caller holds fr.lock.
+func (fr *fakeRedis) execute(w *bufio.Writer, args []string) {
+	switch cmd := strings.ToUpper(args[0]); {
+	case cmd == "PING":
+		w.WriteString("+PONG\r\n")
+	case cmd == "GET" && len(args) == 2:
+		value, ok := fr.get(args[1])
+		if !ok {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value.data), value.data)
+	case cmd == "SET" && (len(args) == 3 || len(args) == 5):
+		fr.lastVersion++
+		value := fakeRedisValue{data: []byte(args[2]), version: fr.lastVersion}
+		if len(args) == 5 {
+			ms, err := strconv.ParseInt(args[4], 10, 64)
+			if strings.ToUpper(args[3]) != "PX" || err != nil || ms <= 0 {
+				w.WriteString("-ERR syntax error\r\n")
+				return
+			}
+			value.expires = fr.clock.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		fr.keys[args[1]] = value
+		w.WriteString("+OK\r\n")
+	case (cmd == "DEL" || cmd == "EXISTS") && len(args) >= 2:
+		count := 0
+		for _, key := range args[1:] {
+			if _, ok := fr.get(key); ok {
+				count++
+				if cmd == "DEL" {
+					delete(fr.keys, key)
+				}
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", count)
+	default:
+		fmt.Fprintf(w, "-ERR unsupported command %q\r\n", args[0])
+	}
+}
+
+// get returns the value of key, deleting it instead if it has expired.
+func (fr *fakeRedis) get(key string) (fakeRedisValue, bool) {
+	value, ok := fr.keys[key]
+	if ok && !value.expires.IsZero() && !fr.clock.Now().Before(value.expires) {
+		delete(fr.keys, key)
+		return fakeRedisValue{}, false
+	}
+
+	return value, ok
+}
+
+var errMalformedCommand = errors.New("malformed command")
+
+// readCommand reads a command, which clients send as an array of bulk strings. It returns errMalformedCommand for
+// anything else, after which the connection can't be read any further.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	n, err := readLength(r, '*')
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, errMalformedCommand
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		size, err := readLength(r, '$')
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errMalformedCommand
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if string(data[size:]) != "\r\n" {
+			return nil, errMalformedCommand
+		}
+		args[i] = string(data[:size])
+	}
+
+	return args, nil
+}
+
+// readLength reads a line holding the length of an array or bulk string, which starts with kind.
+func readLength(r *bufio.Reader, kind byte) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	if len(line) < 4 || line[0] != kind || line[len(line)-2] != '\r' {
+		return 0, errMalformedCommand
+	}
+
+	n, err := strconv.Atoi(line[1 : len(line)-2])
+	if err != nil {
+		return 0, errMalformedCommand
+	}
+
+	return n, nil
+}
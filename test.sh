@@ -1,3 +1,4 @@
 #!/bin/sh
 
-go run ./cmd/client/... -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true
\ No newline at end of file
+go run ./cmd/client/... -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true
+go run ./cmd/client/... -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -checksum=sha256 -testChecksum=b1d0d355537a5c5d7e3305ce8d0bec215eb5131cec51bfa8707d57b774f33b74 -testMode=true
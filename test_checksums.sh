@@ -0,0 +1,26 @@
+#!/bin/sh
+
+# Runs the test mode's scenario once with each checksum algorithm. The expected checksums pin the canonical encoding
+# of the sequence, so a change to how any algorithm hashes the numbers fails here.
+
+dir=$(mktemp -d)
+trap 'kill $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+$dir/server &
+server=$!
+sleep 1
+
+for expected in \
+	md5:6d5e187e2b5c76831b6affd8ff83bea4 \
+	sha256:b1d0d355537a5c5d7e3305ce8d0bec215eb5131cec51bfa8707d57b774f33b74 \
+	blake2b:2cfaf989d907b7f44afa632e70cd35f187fc5b34132da35f228f859b3b04ca56 \
+	crc32c:56be886b \
+	xxhash:e0f68ff582e38284
+do
+	algorithm=${expected%%:*}
+	checksum=${expected#*:}
+	$dir/client -checksum=$algorithm -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=$checksum -testMode=true || exit 1
+done
@@ -0,0 +1,5 @@
+#!/bin/sh
+
+# Runs the storage conformance checks against every storage the server has, with the race detector on.
+
+go test -race -count=1 -run Conformance ./cmd/server/...
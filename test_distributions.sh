@@ -0,0 +1,56 @@
+#!/bin/sh
+
+# Runs the test mode's scenario, which resumes the stream part way through, with every distribution, over both the
+# GetNumbers and the GetAckedNumbers RPCs. The expected checksums pin how each distribution draws its values, so a
+# change to any of them fails here. A sample is then resumed after a server restart, which has to replay the values
+# it already drew, and drawn in standard operation, where the client replays it from the revealed seed.
+
+dir=$(mktemp -d)
+trap 'kill -9 $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+$dir/server -storage=file -storageDir=$dir/state &
+server=$!
+sleep 1
+
+while read distribution checksum; do
+	for acked in false true; do
+		$dir/client -distribution=$distribution -acked=$acked -prng=xoshiro256++ -testSeed=2596996162 -numMessages=10 -testPause=100ms -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testChecksum=$checksum -testMode=true > $dir/out || { cat $dir/out; exit 1; }
+	done
+done <<CHECKSUMS
+uniform:1:6 f1e0f63328e7be6aa10285f5a07f420b3abad684e24225da84ad4b0d4977945a
+uniform:-9223372036854775808:9223372036854775807 5bc1fa144d0864796a77e611f8d30debccdb935251dc5b96ce090a3c9228402c
+sample:1:49 5b90ecc6021c7d988119c6fa95d682ec186f69c0f1d98deb5f9b1e9434f931db
+gaussian:0:1 decab6ef3436902f1a6c25fe064b380092294705652510ea3891a88163d67b9c
+exponential:2 73858d9ba8fef037149353b072ebdc60796e808b4a616476e647b76f993d636f
+poisson:4 ff3635368a814be01ade4222cefec5e74b8eef00d52e3b09c051e320af0fee3e
+poisson:1000 31db539e9db9f67d5feaa11c42aaf266849ba3eb85707c325d9d2c7cb119bdd5
+CHECKSUMS
+
+# The server is killed in the second half of the stream, so the sample is restored from storage.
+$dir/client -distribution=sample:1:49 -prng=xoshiro256++ -testSeed=2596996162 -numMessages=10 -testPause=100ms -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abd -testChecksum=5b90ecc6021c7d988119c6fa95d682ec186f69c0f1d98deb5f9b1e9434f931db -testMode=true -reconnect=true > $dir/out &
+client=$!
+sleep 7
+echo "killing server"
+kill -9 $server
+wait $server 2>/dev/null
+$dir/server -storage=file -storageDir=$dir/state &
+server=$!
+wait $client || { cat $dir/out; exit 1; }
+grep SUCCESS $dir/out
+
+# In standard operation the server picks the seed, and the client replays the sample from it.
+$dir/client -distribution=sample:1:1000 -prng=chacha20 -clientSeed=distributions -intervalMs=10 -numMessages=100 > $dir/out || { cat $dir/out; exit 1; }
+grep "revealed server seed" $dir/out || { cat $dir/out; exit 1; }
+
+# A sample can't be longer than its range, and the values of a distribution have no Merkle proofs.
+if $dir/client -distribution=sample:1:5 -numMessages=10 > $dir/out; then
+	echo "FAILURE: a sample was longer than its range"
+	exit 1
+fi
+if $dir/client -distribution=uniform:1:6 -merkleChunkSize=16 -numMessages=10 > $dir/out; then
+	echo "FAILURE: a distribution was sent with Merkle proofs"
+	exit 1
+fi
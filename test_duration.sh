@@ -0,0 +1,73 @@
+#!/bin/sh
+
+# Runs streams that aren't bounded by their length. A stream bounded by its duration is received in standard
+# operation, and the test mode's scenario is run with checkpoints, which it resumes from, over both RPCs and with
+# signed checkpoints. Unbounded streams are then cut short by a server's -maxDuration, and received from a server
+# without limits until the client is stopped, checking every checkpoint on the way.
+
+dir=$(mktemp -d)
+trap 'kill $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+start_server() {
+	$dir/server "$@" &
+	server=$!
+	sleep 1
+}
+stop_server() {
+	kill $server
+	wait $server 2>/dev/null
+}
+
+start_server -signingKey=000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f
+public_key=$($dir/client -printPublicKey) || exit 1
+
+# At 10ms a number, 2s is about 200 numbers.
+$dir/client -duration=2s -intervalMs=10 > $dir/out || { cat $dir/out; exit 1; }
+count=$(grep -c '^[0-9]*$' $dir/out)
+if [ $count -lt 100 ] || [ $count -gt 200 ]; then
+	echo "FAILURE: a 2s stream sent $count numbers"
+	exit 1
+fi
+grep success $dir/out
+
+# The first half of the stream is cut off after its last checkpoint at index 45, which is where the stream resumes.
+for acked in false true; do
+	$dir/client -acked=$acked -intervalMs=10 -checkpointMessages=15 -serverPublicKey=$public_key -numMessages=100 -testPause=100ms -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=d0844b4b8f8861a94405f4f84efa5cad -testMode=true > $dir/out || { cat $dir/out; exit 1; }
+	grep -c "verified checkpoint" $dir/out
+	grep SUCCESS $dir/out
+done
+grep "resuming from the checkpoint at index 45" $dir/out && { echo "FAILURE: GetAckedNumbers resumed from a checkpoint"; exit 1; }
+
+# Unbounded, with a stream's length and merkle proofs for a stream with a duration are refused.
+if $dir/client -unbounded -numMessages=10 > $dir/out; then
+	echo "FAILURE: an unbounded stream with a length was accepted"
+	exit 1
+fi
+if $dir/client -duration=1s -merkleChunkSize=4 > $dir/out; then
+	echo "FAILURE: merkle proofs were sent for a stream with a duration"
+	exit 1
+fi
+stop_server
+
+start_server -maxDuration=2s
+$dir/client -unbounded -intervalMs=10 -checkpointInterval=500ms > $dir/out || { cat $dir/out; exit 1; }
+grep -c "verified checkpoint" $dir/out
+grep success $dir/out
+stop_server
+
+# Without limits the stream runs until the client is stopped.
+start_server -maxNumbers=0
+timeout 3 $dir/client -unbounded -intervalMs=1 -checkpointMessages=500 > $dir/out
+if [ $? -ne 124 ]; then
+	cat $dir/out
+	echo "FAILURE: an unbounded stream ended"
+	exit 1
+fi
+grep -c "verified checkpoint" $dir/out
+if grep -q -E "FAILURE|MISMATCH" $dir/out; then
+	cat $dir/out
+	exit 1
+fi
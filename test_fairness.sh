@@ -0,0 +1,31 @@
+#!/bin/sh
+
+# Runs the client in standard operation, so that the server picks the seed, with a client seed mixed into it. The
+# client checks that the seed the server reveals with the checksum matches the commitment it sent with the first
+# number, and replays the sequence from it. The server also signs its final checksums, so the signed statement is
+# checked to use the same commitment.
+
+dir=$(mktemp -d)
+trap 'kill $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+$dir/server -signingKey=000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f &
+server=$!
+sleep 1
+
+public_key=$($dir/client -printPublicKey) || exit 1
+
+$dir/client -clientSeed=heads -serverPublicKey=$public_key -checksum=sha256 -intervalMs=10 -numMessages=100 > $dir/out || { cat $dir/out; exit 1; }
+grep "revealed server seed" $dir/out || { cat $dir/out; exit 1; }
+
+# The same again with acknowledgements and batching.
+$dir/client -clientSeed=tails -serverPublicKey=$public_key -acked=true -batchSize=7 -intervalMs=10 -numMessages=100 > $dir/out || { cat $dir/out; exit 1; }
+grep "revealed server seed" $dir/out || { cat $dir/out; exit 1; }
+
+# A client seed can't be combined with a seed of the client's own.
+if $dir/client -clientSeed=heads -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true; then
+	echo "FAILURE: a client seed was accepted alongside a seed"
+	exit 1
+fi
@@ -0,0 +1,34 @@
+#!/bin/sh
+
+# Runs the test mode's scenario with Merkle proofs, in batches that don't line up with the chunks, so the stream
+# breaks part way through both a batch and a chunk. The client checks each chunk as it completes and saves what it
+# received, which is then verified offline: once in full, and once cut down to what a client that joined and left
+# part way through would have had. A sequence longer than the server allows proofs for is then refused them.
+
+dir=$(mktemp -d)
+trap 'kill $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+go build -o $dir/merkle ./cmd/merkle/... || exit 1
+
+$dir/server &
+server=$!
+sleep 1
+
+$dir/client -merkleChunkSize=16 -batchSize=6 -intervalMs=10 -segmentFile=$dir/segment.json -numMessages=100 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=d0844b4b8f8861a94405f4f84efa5cad -testMode=true || exit 1
+
+$dir/merkle -segment=$dir/segment.json || exit 1
+$dir/merkle -segment=$dir/segment.json -from=41 -to=90
+kill $server
+wait $server 2>/dev/null
+
+$dir/server -maxMerkleNumbers=64 &
+server=$!
+sleep 1
+
+if $dir/client -merkleChunkSize=16 -numMessages=100 > $dir/out; then
+	echo "FAILURE: merkle proofs were sent for a sequence longer than -maxMerkleNumbers"
+	exit 1
+fi
+grep "at most 64 numbers" $dir/out || { cat $dir/out; exit 1; }
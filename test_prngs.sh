@@ -0,0 +1,61 @@
+#!/bin/sh
+
+# Runs the test mode's scenario, which resumes the stream part way through, with every generator that can be seeded
+# and with 32, 64 and 256 bit seeds. The expected checksums pin how each generator is seeded, so a change to any of
+# them fails here. chacha20 and crypto are then run in standard operation, where the server picks chacha20's seed and
+# crypto takes none.
+
+dir=$(mktemp -d)
+trap 'kill $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+$dir/server &
+server=$!
+sleep 1
+
+seed32=-testSeed=2596996162
+seed64=-testWideSeed=0123456789abcdef
+seed256=-testWideSeed=000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f
+
+while read prng seed checksum; do
+	eval seed=\$$seed
+	$dir/client -prng=$prng $seed -checksum=sha256 -numMessages=10 -testPause=100ms -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testChecksum=$checksum -testMode=true > $dir/out || { cat $dir/out; exit 1; }
+	grep "sequence is generated by" $dir/out || { cat $dir/out; exit 1; }
+done <<CHECKSUMS
+mt19937 seed32 b1d0d355537a5c5d7e3305ce8d0bec215eb5131cec51bfa8707d57b774f33b74
+mt19937 seed64 30f0e4cee63bb238ce5179d7fa8563fa7ba716b238cfc01b6be1788774fc8386
+mt19937 seed256 a4a0af08be19f5b4c59f2e9f0b47a9fece08d9ab2fde79ca7c1e4b1694dfff66
+mt19937-64 seed32 07164433ce10203d42e0ce916c273cbdeca75e29a480bf7f0ee8c9018f884865
+mt19937-64 seed64 6a6b4ebd0ffbfa39444ecd54e9a8a3397572faa1296384ded7bc54a36eae222e
+mt19937-64 seed256 8b1344c661d440c7d9f3e49a752965512b293f0e845626ee8e9451b0cd07a397
+xoshiro256+ seed32 1b1ae4fc064b841f94bf6a448462c1e8e736070ea3978064079ed18291ac5ac4
+xoshiro256+ seed64 50219204ce1ff91a2e712014656a39cb38ad360f3335239d2867ad0bccd76829
+xoshiro256+ seed256 a28faea668c7b6c51848e6892cd53b906910e78fdd23a3c5afaa73177b109f27
+xoshiro256++ seed32 67652c4aff9f471c834bfca2642d4470582aa477855bab09615274e006525bac
+xoshiro256++ seed64 333645bc738d9f88e7b8cd165bd4abd391e1d621c874e3e66b10b49827eddfa5
+xoshiro256++ seed256 4c6aa003f62f2e7f2882c307dd5b4c8924c318bd87487bcb93416a1d92a525ea
+xoshiro256** seed32 824f49d601e4c2ebb942cd24ee32766a78def9e24909c2c88bf101ff445cbe00
+xoshiro256** seed64 5dc5f57ecdb7ab5d98587f39ca910a2409d67106a18d7e1a7928c7e73f1a98e8
+xoshiro256** seed256 e9c77d300ba25a21278e7b59024443ddde8fd323ea38e400aefd4398fc08cc8f
+chacha20 seed32 d6a28f1255da9164f4eb0522b92d1d8d4638bd5f4c7f8472b4cd5bffc342cae9
+chacha20 seed64 35cace9e0e22106941a86480aa2aa90125213f45075baed19825120602dfd71f
+chacha20 seed256 5d7589f27ff9979505f0997fdce72ff533624208eb0226a9472c392f869d83fd
+CHECKSUMS
+
+# In standard operation the server picks a 256 bit seed for chacha20, and the client replays the sequence from it.
+$dir/client -prng=chacha20 -intervalMs=10 -numMessages=100 > $dir/out || { cat $dir/out; exit 1; }
+grep "revealed server seed" $dir/out || { cat $dir/out; exit 1; }
+
+# crypto takes no seed and can't be acknowledged.
+$dir/client -prng=crypto -intervalMs=10 -numMessages=100 > $dir/out || { cat $dir/out; exit 1; }
+grep "sequence is generated by crypto" $dir/out || { cat $dir/out; exit 1; }
+if $dir/client -prng=crypto -acked=true -numMessages=10 > $dir/out; then
+	echo "FAILURE: a crypto stream was acknowledged"
+	exit 1
+fi
+if $dir/client -prng=crypto -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc $seed32 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true > $dir/out; then
+	echo "FAILURE: a crypto stream was seeded"
+	exit 1
+fi
@@ -0,0 +1,23 @@
+#!/bin/sh
+
+# Runs two servers sharing client state through the redis storage, backed by the fake Redis protocol server the
+# storage tests use. The test mode's stream starts on the first server and is resumed on the second.
+
+dir=$(mktemp -d)
+trap 'kill $redis $server1 $server2 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+go build -o $dir/fakeredis ./cmd/fakeredis/... || exit 1
+
+$dir/fakeredis -addr=localhost:6390 &
+redis=$!
+
+$dir/server -port=50051 -storage=redis -redisAddr=localhost:6390 &
+server1=$!
+sleep 1
+$dir/server -port=50052 -storage=redis -redisAddr=localhost:6390 &
+server2=$!
+sleep 1
+
+$dir/client -port=50051 -resumePort=50052 -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true
@@ -0,0 +1,29 @@
+#!/bin/sh
+
+# Kills the server (with SIGKILL) part way through each half of the test mode's stream and restarts it on the same
+# file storage. The client reconnects, resumes where it left off, and checks the final checksum.
+
+dir=$(mktemp -d)
+trap 'kill -9 $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+$dir/server -storage=file -storageDir=$dir/state -fsync=always &
+server=$!
+sleep 1
+
+$dir/client -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true -reconnect=true &
+client=$!
+
+# The client receives one number per second, so these land in the first and second halves of the stream.
+for at in 3 8; do
+	sleep $at
+	echo "killing server"
+	kill -9 $server
+	wait $server 2>/dev/null
+	$dir/server -storage=file -storageDir=$dir/state -fsync=always &
+	server=$!
+done
+
+wait $client
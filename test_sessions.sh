@@ -0,0 +1,16 @@
+#!/bin/sh
+
+# Runs the test mode's scenario against a server that issues session IDs. The client leaves the ID of the stream
+# to the server, and resumes the second half of the stream with the ID the server sent back.
+
+dir=$(mktemp -d)
+trap 'kill $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+$dir/server -issueSessionIDs &
+server=$!
+sleep 1
+
+$dir/client -serverSessionID -numMessages=10 -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true
@@ -0,0 +1,25 @@
+#!/bin/sh
+
+# Runs the test mode's scenario against a server that signs its final checksums. The client is given the server's
+# public key, fetched with -printPublicKey, and checks the signature. It is then given the wrong key, which it must
+# fail with.
+
+dir=$(mktemp -d)
+trap 'kill $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+$dir/server -signingKey=000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f &
+server=$!
+sleep 1
+
+public_key=$($dir/client -printPublicKey) || exit 1
+wrong_key=3b6a27bcceb6a42d62a3a8d02a6f0d73653215771de243a63ac048a18b59da29
+
+$dir/client -serverPublicKey=$public_key -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true || exit 1
+
+if $dir/client -serverPublicKey=$wrong_key -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true; then
+	echo "FAILURE: the signature was accepted with the wrong key"
+	exit 1
+fi
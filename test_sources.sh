@@ -0,0 +1,70 @@
+#!/bin/sh
+
+# Runs the test mode's scenario, which resumes the stream part way through, taking the numbers from sources the
+# server is configured with rather than from a generator: a script, a recording and values drawn from a script, over
+# both the GetNumbers and the GetAckedNumbers RPCs. A recording is then resumed after a server restart, which has to
+# pick up from the stored cursor, and numbers are read from /dev/urandom in standard operation. A device that can no
+# longer be read ends its streams without taking the server down.
+
+dir=$(mktemp -d)
+trap 'kill -9 $server 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+seq 1000 1099 > $dir/recording
+# Three numbers' worth of bytes, after which the device can't be read.
+printf 'abcdefghijkl' > $dir/short
+
+start_server() {
+	$dir/server -storage=file -storageDir=$dir/state -source="dice=script:1 2 3 4 5 6" -source="recording=replay:$dir/recording" -source="urandom=device:/dev/urandom" -source="short=device:$dir/short" &
+	server=$!
+	sleep 1
+}
+start_server
+
+# Sources other than generators take no seed, so none is sent.
+while read source checksum distribution; do
+	for acked in false true; do
+		$dir/client -source=$source -distribution=$distribution -acked=$acked -testSeed=0 -numMessages=10 -testPause=100ms -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testChecksum=$checksum -testMode=true > $dir/out || { cat $dir/out; exit 1; }
+	done
+done <<CHECKSUMS
+dice 8d421e892a47dff539f46142eb09e56b
+recording 8e5cf34aeb696005fa4bb3b7c12586c9
+dice 7aa11092677dfcf9aee2213b04535cc6abd40920b822019a77c2d28d578e4d70 uniform:1:100
+CHECKSUMS
+
+# The server is killed in the second half of the stream, so the recording is restored from storage.
+$dir/client -source=recording -testSeed=0 -numMessages=10 -testPause=100ms -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abd -testChecksum=8e5cf34aeb696005fa4bb3b7c12586c9 -testMode=true -reconnect=true > $dir/out &
+client=$!
+sleep 7
+echo "killing server"
+kill -9 $server
+wait $server 2>/dev/null
+start_server
+wait $client || { cat $dir/out; exit 1; }
+grep SUCCESS $dir/out
+
+$dir/client -source=urandom -intervalMs=10 -numMessages=100 > $dir/out || { cat $dir/out; exit 1; }
+grep success $dir/out
+
+# A recording can't be played past its end, only a generator can be seeded, and numbers read from a device can't be
+# acknowledged as they can't be sent again.
+if $dir/client -source=recording -numMessages=101 > $dir/out; then
+	echo "FAILURE: a recording was played past its end"
+	exit 1
+fi
+if $dir/client -source=dice -clientSeed=dice -numMessages=10 > $dir/out; then
+	echo "FAILURE: a script was seeded"
+	exit 1
+fi
+if $dir/client -source=urandom -acked=true -numMessages=10 > $dir/out; then
+	echo "FAILURE: numbers read from a device were acknowledged"
+	exit 1
+fi
+if $dir/client -source=short -intervalMs=10 -numMessages=10 > $dir/out; then
+	echo "FAILURE: more numbers were sent than a device could be read for"
+	exit 1
+fi
+grep -q "code = Unavailable" $dir/out || { cat $dir/out; exit 1; }
+kill -0 $server || { echo "FAILURE: the server didn't survive a device failing"; exit 1; }
@@ -0,0 +1,25 @@
+#!/bin/sh
+
+# Opens a second stream for a client while its first is still running. With the default takeover policy the first
+# stream is aborted and the second one runs to completion. With -leasePolicy=reject the second stream would be
+# refused with ALREADY_EXISTS instead.
+
+dir=$(mktemp -d)
+trap 'kill $server $client 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+$dir/server -leasePolicy=takeover &
+server=$!
+sleep 1
+
+$dir/client -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true > $dir/first.log 2>&1 &
+client=$!
+sleep 2
+
+$dir/client -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true || exit 1
+
+wait $client
+echo "first stream: $(tail -n 1 $dir/first.log)"
+grep -q "code = Aborted" $dir/first.log
@@ -0,0 +1,25 @@
+#!/bin/sh
+
+# Runs two servers that keep no client state at all (-storage=none) and share a resume token key. The test mode's
+# stream starts on the first server and is resumed on the second from the resume token the first one issued. The
+# second server has rotated to a newer key, but still accepts tokens sealed with the old one.
+
+dir=$(mktemp -d)
+trap 'kill $server1 $server2 2>/dev/null; rm -rf $dir' EXIT
+
+go build -o $dir/server ./cmd/server/... || exit 1
+go build -o $dir/client ./cmd/client/... || exit 1
+
+old_key=key1:00112233445566778899aabbccddeeff
+new_key=key2:ffeeddccbbaa99887766554433221100
+
+$dir/server -port=50051 -storage=none -resumeKeys=$old_key &
+server1=$!
+$dir/server -port=50052 -storage=none -resumeKeys=$new_key,$old_key &
+server2=$!
+sleep 1
+
+$dir/client -port=50051 -resumePort=50052 -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true || exit 1
+
+# The same again with acknowledgements.
+$dir/client -acked=true -port=50051 -resumePort=50052 -numMessages=10 -testUUID=da615dcf\-c523-4802\-acb4\-8a15fc450abc -testSeed=2596996162 -testChecksum=6d5e187e2b5c76831b6affd8ff83bea4 -testMode=true